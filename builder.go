@@ -17,10 +17,12 @@ package eorm
 import (
 	"context"
 	"database/sql"
+	"reflect"
 
 	"github.com/ecodeclub/eorm/internal/errs"
 	"github.com/ecodeclub/eorm/internal/model"
 	"github.com/ecodeclub/eorm/internal/query"
+	"github.com/ecodeclub/eorm/internal/rows"
 	"github.com/valyala/bytebufferpool"
 )
 
@@ -71,6 +73,11 @@ func newQuerier[T any](sess Session, q Query, meta *model.TableMeta, typ string)
 
 // Exec 执行 SQL
 func (q Querier[T]) Exec(ctx context.Context) Result {
+	if q.qc.Type == RAW {
+		if err := q.checkShadowRaw(ctx); err != nil {
+			return Result{err: err}
+		}
+	}
 	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
 		res, err := q.Session.execContext(ctx, qc.q)
 		return &QueryResult{Result: res, Err: err}
@@ -106,6 +113,8 @@ type builder struct {
 	buffer *bytebufferpool.ByteBuffer
 	meta   *model.TableMeta
 	args   []interface{}
+	// shadow 为 true 的时候使用影子表
+	shadow bool
 	// aliases map[string]struct{}
 }
 
@@ -273,6 +282,36 @@ func (q Querier[T]) GetMulti(ctx context.Context) ([]*T, error) {
 	return res.Result.([]*T), nil
 }
 
+// Iter 执行查询并且返回游标，数据在遍历的时候才会逐行读取。
+// 经过 Middleware 的时候，QueryResult.Result 是还没有读取的 rows.Rows
+func (q Querier[T]) Iter(ctx context.Context) (*Iterator[T], error) {
+	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		rs, err := q.Session.queryContext(ctx, qc.q)
+		return &QueryResult{Result: rs, Err: err}
+	}
+	ms := q.ms
+	for i := len(ms) - 1; i >= 0; i-- {
+		handler = ms[i](handler)
+	}
+	qr := handler(ctx, q.qc)
+	if qr.Err != nil {
+		return nil, qr.Err
+	}
+	rs, ok := qr.Result.(rows.Rows)
+	if !ok {
+		return nil, errs.NewErrUnsupportedIterResult(qr.Result)
+	}
+	meta := q.qc.meta
+	if meta == nil {
+		t := new(T)
+		if reflect.TypeOf(t).Elem().Kind() == reflect.Struct {
+			// 和 GetMulti 一样，基本类型取值用不到 meta，所以忽略错误
+			meta, _ = q.metaRegistry.Get(t)
+		}
+	}
+	return newIterator[T](rs, q.core, meta), nil
+}
+
 func (b *builder) buildColumn(c Column) error {
 	switch table := c.table.(type) {
 	case nil:
@@ -314,6 +353,9 @@ func (b *builder) buildColumn(c Column) error {
 // buildSubquery 構建子查詢 SQL，
 // useAlias 決定是否顯示別名，即使有別名
 func (b *builder) buildSubquery(sub Subquery, useAlias bool) error {
+	if s, ok := sub.q.(shadowable); ok {
+		s.setShadow(b.shadow)
+	}
 	q, err := sub.q.Build()
 	if err != nil {
 		return err
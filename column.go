@@ -113,6 +113,24 @@ func (c Column) NotLike(val interface{}) Predicate {
 	}
 }
 
+// Between -> BETWEEN start AND end
+func (c Column) Between(start, end any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opBetween,
+		right: binaryExpr{left: valueOf(start), op: opAnd, right: valueOf(end)},
+	}
+}
+
+// NotBetween -> NOT BETWEEN start AND end
+func (c Column) NotBetween(start, end any) Predicate {
+	return Predicate{
+		left:  c,
+		op:    opNotBetween,
+		right: binaryExpr{left: valueOf(start), op: opAnd, right: valueOf(end)},
+	}
+}
+
 // Add generate an additive expression
 func (c Column) Add(val interface{}) MathExpr {
 	return MathExpr{
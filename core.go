@@ -21,6 +21,7 @@ import (
 	"github.com/ecodeclub/eorm/internal/dialect"
 	"github.com/ecodeclub/eorm/internal/errs"
 	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding/idgen"
 	"github.com/ecodeclub/eorm/internal/valuer"
 )
 
@@ -29,6 +30,14 @@ type core struct {
 	dialect      dialect.Dialect
 	valCreator   valuer.PrimitiveCreator
 	ms           []Middleware
+	// idGenerators 是标签 auto_id 可以使用的主键生成器
+	idGenerators map[string]idgen.Generator
+	// maxParallelism 是分库分表的时候同时执行的查询数量上限，小于等于 0 表示不限制
+	maxParallelism int
+	// shadowPrefix 是影子表的前缀
+	shadowPrefix string
+	// shadowGuard 为 true 的时候不允许压测流量写入线上的表
+	shadowGuard bool
 }
 
 func getHandler[T any](ctx context.Context, sess Session, c core, qc *QueryContext) *QueryResult {
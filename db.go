@@ -23,6 +23,8 @@ import (
 	"github.com/ecodeclub/eorm/internal/dialect"
 	"github.com/ecodeclub/eorm/internal/errs"
 	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/idgen"
 	"github.com/ecodeclub/eorm/internal/valuer"
 )
 
@@ -56,6 +58,25 @@ func DBWithMetaRegistry(r model.MetaRegistry) DBOption {
 	}
 }
 
+// DBWithMaxParallelism 限制分库分表的时候同时在多个目标表上执行的查询数量，
+// n 小于等于 0 表示不限制，也就是每个目标表一个 goroutine
+func DBWithMaxParallelism(n int) DBOption {
+	return func(db *DB) {
+		db.maxParallelism = n
+	}
+}
+
+// DBWithIDGenerator 注册名字为 name 的主键生成器，
+// 分库分表插入数据的时候，标签为 auto_id=name 的字段会使用它生成主键
+func DBWithIDGenerator(name string, gen idgen.Generator) DBOption {
+	return func(db *DB) {
+		if db.idGenerators == nil {
+			db.idGenerators = make(map[string]idgen.Generator, 4)
+		}
+		db.idGenerators[name] = gen
+	}
+}
+
 func UseReflection() DBOption {
 	return func(db *DB) {
 		db.valCreator = valuer.PrimitiveCreator{Creator: valuer.NewUnsafeValue}
@@ -87,6 +108,7 @@ func OpenDS(driver string, ds datasource.DataSource, opts ...DBOption) (*DB, err
 				valCreator: valuer.PrimitiveCreator{
 					Creator: valuer.NewUnsafeValue,
 				},
+				shadowPrefix: sharding.DefaultShadowPrefix,
 			},
 		},
 		ds: ds,
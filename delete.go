@@ -54,7 +54,7 @@ func (d *Deleter[T]) Build() (Query, error) {
 		return EmptyQuery, err
 	}
 
-	d.quote(d.meta.TableName)
+	d.quote(d.tableName(d.meta))
 	if len(d.where) > 0 {
 		d.writeString(" WHERE ")
 		err = d.buildPredicates(d.where)
@@ -80,9 +80,13 @@ func (d *Deleter[T]) Where(predicates ...Predicate) *Deleter[T] {
 
 // Exec sql
 func (d *Deleter[T]) Exec(ctx context.Context) Result {
+	d.useShadow(ctx)
 	query, err := d.Build()
 	if err != nil {
 		return Result{err: err}
 	}
+	if err = d.checkShadowTable(ctx); err != nil {
+		return Result{err: err}
+	}
 	return newQuerier[T](d.Session, query, d.meta, DELETE).Exec(ctx)
 }
@@ -66,7 +66,7 @@ func (i *Inserter[T]) Build() (Query, error) {
 	if err != nil {
 		return EmptyQuery, err
 	}
-	i.quote(i.meta.TableName)
+	i.quote(i.tableName(i.meta))
 	i.writeString("(")
 	fields, err := i.buildColumns()
 	if err != nil {
@@ -114,10 +114,14 @@ func (i *Inserter[T]) Values(values ...*T) *Inserter[T] {
 
 // Exec 发起查询
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
+	i.useShadow(ctx)
 	query, err := i.Build()
 	if err != nil {
 		return Result{err: err}
 	}
+	if err = i.checkShadowTable(ctx); err != nil {
+		return Result{err: err}
+	}
 	return newQuerier[T](i.db, query, i.meta, INSERT).Exec(ctx)
 }
 
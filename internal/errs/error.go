@@ -17,6 +17,8 @@ package errs
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"time"
 )
 
 var (
@@ -30,7 +32,6 @@ var (
 	// ErrCombinationIsNotStruct 不支持的组合类型，eorm 只支持结构体组合
 	ErrCombinationIsNotStruct            = errors.New("eorm: 不支持的组合类型，eorm 只支持结构体组合")
 	ErrMissingShardingKey                = errors.New("eorm: sharding key 未设置")
-	ErrOnlyResultOneQuery                = errors.New("eorm: 只能生成一个 SQL")
 	ErrUnsupportedTooComplexQuery        = errors.New("eorm: 暂未支持太复杂的查询")
 	ErrSlaveNotFound                     = errors.New("eorm: slave不存在")
 	ErrNotGenShardingQuery               = errors.New("eorm: 未生成 sharding query")
@@ -39,6 +40,18 @@ var (
 	ErrInsertFindingDst                  = errors.New("eorm: 一行数据只能插入一个表")
 	ErrUnsupportedAssignment             = errors.New("eorm: 不支持的 assignment")
 	ErrUnsupportedDistributedTransaction = errors.New("eorm: 不支持的分布式事务类型")
+	ErrAggregateMixedWithColumns         = errors.New("eorm: 跨分片的聚合查询在没有 GROUP BY 的时候不能查询普通列")
+	ErrIncompleteCompositeAlgorithm      = errors.New("eorm: 组合分片算法必须指定数据源、DB 和表三层的算法")
+	// ErrMoveRowsWithoutPrimaryKey 更新 sharding key 迁移数据的时候，需要通过主键删除原本的数据
+	ErrMoveRowsWithoutPrimaryKey = errors.New("eorm: 更新 sharding key 的模型必须有主键")
+	// ErrMoveRowsChanged 更新 sharding key 迁移数据的时候，删除的行数和读出来的行数不一致，说明数据被并发修改了
+	ErrMoveRowsChanged = errors.New("eorm: 迁移数据的过程中数据被并发修改")
+	// ErrMissingConflictColumns SQLite 的 upsert 需要指定判断冲突的列
+	ErrMissingConflictColumns = errors.New("eorm: SQLite 的 upsert 必须指定 ConflictColumns")
+	// ErrShadowRawWrite 开启了影子保护之后，无法确认原生 SQL 写入的是不是影子表
+	ErrShadowRawWrite = errors.New("eorm: 压测流量不能使用原生 SQL 写入数据")
+	// ErrMissingShardingAlgorithm 模型没有设置分库分表算法
+	ErrMissingShardingAlgorithm = errors.New("eorm: 模型未设置分库分表算法")
 )
 
 func NewErrDBNotEqual(oldDB, tgtDB string) error {
@@ -61,6 +74,91 @@ func NewErrUpdateShardingKeyUnsupported(field string) error {
 	return fmt.Errorf("eorm: ShardingKey `%s` 不支持更新", field)
 }
 
+// NewErrUnsupportedDistinctAggregate 跨分片的时候无法归并 DISTINCT 聚合函数
+func NewErrUnsupportedDistinctAggregate(fn string) error {
+	return fmt.Errorf("eorm: 跨分片查询不支持 %s(DISTINCT xxx)", fn)
+}
+
+// NewErrOrderByColumnNotSelected 跨分片的时候 ORDER BY 的列必须出现在 SELECT 中，才能在归并的时候排序
+func NewErrOrderByColumnNotSelected(field string) error {
+	return fmt.Errorf("eorm: 跨分片查询 ORDER BY 的列 %s 必须出现在 SELECT 中", field)
+}
+
+// NewErrGroupByColumnNotSelected 跨分片的时候 GROUP BY 的列必须出现在 SELECT 中
+func NewErrGroupByColumnNotSelected(field string) error {
+	return fmt.Errorf("eorm: 跨分片查询 GROUP BY 的列 %s 必须出现在 SELECT 中", field)
+}
+
+// NewErrInvalidHavingColumn HAVING 中使用的普通列必须出现在 GROUP BY 中
+func NewErrInvalidHavingColumn(field string) error {
+	return fmt.Errorf("eorm: HAVING 中的列 %s 必须出现在 GROUP BY 中", field)
+}
+
+// NewErrUnsupportedHavingValue 在内存中执行 HAVING 的时候无法比较的值
+func NewErrUnsupportedHavingValue(val any) error {
+	return fmt.Errorf("eorm: HAVING 不支持比较 %T 类型的值", val)
+}
+
+// NewUnsupportedAggregateError 不支持的聚合函数
+func NewUnsupportedAggregateError(fn string) error {
+	return fmt.Errorf("eorm: 不支持的聚合函数 %s", fn)
+}
+
+// NewErrUnsupportedShardingValue 分库分表算法无法处理的 sharding key 的值
+func NewErrUnsupportedShardingValue(val any) error {
+	return fmt.Errorf("eorm: sharding key 不支持 %T 类型的值 %v", val, val)
+}
+
+// NewErrInvalidShardingInterval 分片区间不合法，例如起点不小于终点，或者和其它区间重叠
+func NewErrInvalidShardingInterval(start, end any) error {
+	return fmt.Errorf("eorm: 不合法的分片区间 [%v, %v)", start, end)
+}
+
+// NewErrInvalidHintDst 通过 context 指定的目标表不在分库分表算法的范围内
+func NewErrInvalidHintDst(ds, db, tbl string) error {
+	return fmt.Errorf("eorm: 指定的目标表 %s.%s.%s 不存在", ds, db, tbl)
+}
+
+// NewErrUnboundJoinTable 分库分表的时候只有绑定表和广播表能够 JOIN
+func NewErrUnboundJoinTable(left, right string) error {
+	return fmt.Errorf("eorm: 表 %s 和表 %s 不是绑定表，不支持跨分片 JOIN", left, right)
+}
+
+// NewErrBindingTableMismatch 绑定表的分片结果无法和主表一一对应
+func NewErrBindingTableMismatch(table, db, tbl string) error {
+	return fmt.Errorf("eorm: 表 %s 没有和 %s.%s 对应的目标表", table, db, tbl)
+}
+
+// NewErrInvalidWorkerID 雪花算法的机器 ID 超出范围
+func NewErrInvalidWorkerID(id int64) error {
+	return fmt.Errorf("eorm: 不合法的机器 ID %d", id)
+}
+
+// NewErrInvalidGeneBits 基因算法的基因位数超出范围
+func NewErrInvalidGeneBits(bits int) error {
+	return fmt.Errorf("eorm: 不合法的基因位数 %d", bits)
+}
+
+// NewErrClockMovedBackwards 时钟回拨，继续生成主键可能会重复
+func NewErrClockMovedBackwards(d time.Duration) error {
+	return fmt.Errorf("eorm: 时钟回拨了 %s，拒绝生成主键", d)
+}
+
+// NewErrUnknownIDGenerator 标签 auto_id 指定的主键生成器没有注册
+func NewErrUnknownIDGenerator(name string) error {
+	return fmt.Errorf("eorm: 未知的主键生成器 %s", name)
+}
+
+// NewErrUnsupportedAutoIDType 只有整数类型的字段才能自动生成主键
+func NewErrUnsupportedAutoIDType(field string) error {
+	return fmt.Errorf("eorm: 字段 %s 不是整数类型，无法自动生成主键", field)
+}
+
+// NewErrExplainAutoIDShardingKey Explain 不会生成主键，自动生成的主键是 sharding key 的时候无法确定目标表
+func NewErrExplainAutoIDShardingKey(field string) error {
+	return fmt.Errorf("eorm: Explain 不会生成主键，无法按照 sharding key %s 查找目标表", field)
+}
+
 func NewFieldConflictError(field string) error {
 	return fmt.Errorf("eorm: `%s`列冲突", field)
 }
@@ -116,3 +214,46 @@ func NewFailedToGetSlavesFromDNS(err error) error {
 func NewErrScanWrongDestinationArguments(expect int, actual int) error {
 	return fmt.Errorf("eorm: Scan 方法收到过多或者过少的参数，预期 %d，实际 %d", expect, actual)
 }
+
+// NewErrUnsupportedIterResult Middleware 把查询结果替换成了游标无法遍历的类型
+func NewErrUnsupportedIterResult(res any) error {
+	return fmt.Errorf("eorm: 游标无法遍历 %T 类型的查询结果", res)
+}
+
+// NewErrRawSQLSyntax 无法解析的原生 SQL，near 是出错位置之后的一段 SQL
+func NewErrRawSQLSyntax(pos int, near string) error {
+	if len(near) > 20 {
+		near = near[:20]
+	}
+	return fmt.Errorf("eorm: SQL 语法错误，位置 %d 附近的 %q", pos, near)
+}
+
+// NewErrUnsupportedRawSQL 分库分表的时候原生 SQL 使用了不支持的语法
+func NewErrUnsupportedRawSQL(feature string) error {
+	return fmt.Errorf("eorm: 分库分表的原生 SQL 不支持 %s", feature)
+}
+
+// NewErrRawTableMismatch 原生 SQL 里面的表和模型对应的逻辑表不一致
+func NewErrRawTableMismatch(table, want string) error {
+	return fmt.Errorf("eorm: SQL 中的表 %s 不是模型对应的逻辑表 %s", table, want)
+}
+
+// NewErrRawArgsMismatch 原生 SQL 里面占位符的数量和参数的数量不一致
+func NewErrRawArgsMismatch(want, got int) error {
+	return fmt.Errorf("eorm: SQL 中有 %d 个占位符，但是传入了 %d 个参数", want, got)
+}
+
+// NewErrUnsupportedMoveAssignment 更新 sharding key 迁移数据的时候，只支持直接赋值
+func NewErrUnsupportedMoveAssignment(field string) error {
+	return fmt.Errorf("eorm: 更新 sharding key 的时候，%s 只能直接赋值，不能使用表达式", field)
+}
+
+// NewErrShadowWriteProd 开启了影子保护之后，压测流量写入了线上的数据源、库或者表
+func NewErrShadowWriteProd(name string) error {
+	return fmt.Errorf("eorm: 压测流量不能写入线上的 %s", name)
+}
+
+// NewErrUnsupportedColumnType 建表的时候无法把字段的类型转换成列类型
+func NewErrUnsupportedColumnType(field string, typ reflect.Type) error {
+	return fmt.Errorf("eorm: 无法为字段 %s 的类型 %s 生成列类型", field, typ)
+}
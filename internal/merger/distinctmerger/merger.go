@@ -0,0 +1,132 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distinctmerger
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/internal/filter"
+	"github.com/ecodeclub/eorm/internal/rows"
+)
+
+// Merger 在其它 merger 归并之后的结果上去重。
+// 每个分片上的 DISTINCT 只能保证分片内部没有重复的数据，
+// 同样的数据可能出现在多个分片上，所以需要在归并之后再去重一次。
+// 如果下层 merger 的结果已经按照所有的列排好序，那么重复的数据一定是相邻的，
+// 只需要和上一行比较即可；否则使用哈希表记录已经出现过的数据
+type Merger struct {
+	m      merger.Merger
+	sorted bool
+}
+
+// NewMerger 基于哈希表去重，内存占用和不重复的数据量成正比
+func NewMerger(m merger.Merger) *Merger {
+	return &Merger{
+		m: m,
+	}
+}
+
+// NewSortedMerger 要求 m 的结果已经按照所有的列排好序，
+// 只需要保存上一行数据即可
+func NewSortedMerger(m merger.Merger) *Merger {
+	return &Merger{
+		m:      m,
+		sorted: true,
+	}
+}
+
+func (m *Merger) Merge(ctx context.Context, results []rows.Rows) (rows.Rows, error) {
+	rs, err := m.m.Merge(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rs.Columns()
+	if err != nil {
+		_ = rs.Close()
+		return nil, err
+	}
+	d := &deduplicator{sorted: m.sorted}
+	if !m.sorted {
+		d.seen = make(map[string]struct{}, 16)
+	}
+	return filter.NewRows(rs, d.keep, len(columns), columns), nil
+}
+
+// deduplicator 记录已经出现过的数据
+type deduplicator struct {
+	sorted  bool
+	seen    map[string]struct{}
+	prevKey string
+	hasPrev bool
+}
+
+// keep 判断这一行是否第一次出现，第一次出现的话会记录下来
+func (d *deduplicator) keep(row []any) (bool, error) {
+	key := keyOf(row)
+	if d.sorted {
+		if d.hasPrev && d.prevKey == key {
+			return false, nil
+		}
+		d.prevKey, d.hasPrev = key, true
+		return true, nil
+	}
+	if _, ok := d.seen[key]; ok {
+		return false, nil
+	}
+	d.seen[key] = struct{}{}
+	return true, nil
+}
+
+// keyOf 将一行数据编码成字符串。
+// 每个值都带上类型和长度，避免不同的行编码之后相同
+func keyOf(row []any) string {
+	var sb strings.Builder
+	for _, val := range row {
+		str := valueString(val)
+		sb.WriteString(fmt.Sprintf("%T", val))
+		sb.WriteByte(':')
+		sb.WriteString(strconv.Itoa(len(str)))
+		sb.WriteByte(':')
+		sb.WriteString(str)
+	}
+	return sb.String()
+}
+
+func valueString(val any) string {
+	if valuer, ok := val.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err == nil {
+			val = v
+		}
+	}
+	switch v := val.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package distinctmerger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/batchmerger"
+	"github.com/ecodeclub/eorm/internal/merger/internal/errs"
+	"github.com/ecodeclub/eorm/internal/merger/sortmerger"
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MergerSuite struct {
+	suite.Suite
+	mockDB01 *sql.DB
+	mock01   sqlmock.Sqlmock
+	mockDB02 *sql.DB
+	mock02   sqlmock.Sqlmock
+}
+
+func (ms *MergerSuite) SetupTest() {
+	var err error
+	ms.mockDB01, ms.mock01, err = sqlmock.New()
+	require.NoError(ms.T(), err)
+	ms.mockDB02, ms.mock02, err = sqlmock.New()
+	require.NoError(ms.T(), err)
+}
+
+func (ms *MergerSuite) TearDownTest() {
+	_ = ms.mockDB01.Close()
+	_ = ms.mockDB02.Close()
+}
+
+func (ms *MergerSuite) queryRows(t *testing.T, rs01, rs02 *sqlmock.Rows) []rows.Rows {
+	query := "SELECT DISTINCT `id`,`name` FROM `t1`"
+	ms.mock01.ExpectQuery("SELECT DISTINCT .* FROM `t1`").WillReturnRows(rs01)
+	ms.mock02.ExpectQuery("SELECT DISTINCT .* FROM `t1`").WillReturnRows(rs02)
+	res := make([]rows.Rows, 0, 2)
+	for _, db := range []*sql.DB{ms.mockDB01, ms.mockDB02} {
+		r, err := db.QueryContext(context.Background(), query)
+		require.NoError(t, err)
+		res = append(res, r)
+	}
+	return res
+}
+
+func (ms *MergerSuite) TestMerger_Merge() {
+	cols := []string{"id", "name"}
+	testCases := []struct {
+		name     string
+		merger   func() merger.Merger
+		rs01     *sqlmock.Rows
+		rs02     *sqlmock.Rows
+		wantVals [][]any
+		wantErr  error
+	}{
+		{
+			name: "hash",
+			merger: func() merger.Merger {
+				return NewMerger(batchmerger.NewMerger())
+			},
+			rs01: sqlmock.NewRows(cols).AddRow(1, "a").AddRow(2, "b").AddRow(3, "c"),
+			rs02: sqlmock.NewRows(cols).AddRow(2, "b").AddRow(1, "b").AddRow(3, "c"),
+			wantVals: [][]any{
+				{int64(1), "a"}, {int64(2), "b"}, {int64(3), "c"}, {int64(1), "b"},
+			},
+		},
+		{
+			name: "hash with null",
+			merger: func() merger.Merger {
+				return NewMerger(batchmerger.NewMerger())
+			},
+			rs01: sqlmock.NewRows(cols).AddRow(1, nil).AddRow(1, ""),
+			rs02: sqlmock.NewRows(cols).AddRow(1, nil),
+			wantVals: [][]any{
+				{int64(1), nil}, {int64(1), ""},
+			},
+		},
+		{
+			name: "sorted",
+			merger: func() merger.Merger {
+				m, err := sortmerger.NewMerger(sortmerger.NewSortColumn("id", sortmerger.ASC),
+					sortmerger.NewSortColumn("name", sortmerger.ASC))
+				require.NoError(ms.T(), err)
+				return NewSortedMerger(m)
+			},
+			rs01: sqlmock.NewRows(cols).AddRow(1, "a").AddRow(2, "b").AddRow(3, "c"),
+			rs02: sqlmock.NewRows(cols).AddRow(1, "a").AddRow(1, "b").AddRow(3, "c"),
+			wantVals: [][]any{
+				{int64(1), "a"}, {int64(1), "b"}, {int64(2), "b"}, {int64(3), "c"},
+			},
+		},
+		{
+			name: "rows error",
+			merger: func() merger.Merger {
+				return NewMerger(batchmerger.NewMerger())
+			},
+			rs01:     sqlmock.NewRows(cols).AddRow(1, "a").AddRow(2, "b").RowError(1, errors.New("mock next error")),
+			rs02:     sqlmock.NewRows(cols).AddRow(1, "a"),
+			wantVals: [][]any{{int64(1), "a"}},
+			wantErr:  errors.New("mock next error"),
+		},
+	}
+	for _, tc := range testCases {
+		ms.T().Run(tc.name, func(t *testing.T) {
+			rs, err := tc.merger().Merge(context.Background(), ms.queryRows(t, tc.rs01, tc.rs02))
+			require.NoError(t, err)
+			vals := make([][]any, 0, len(tc.wantVals))
+			for rs.Next() {
+				var id int64
+				var name sql.NullString
+				require.NoError(t, rs.Scan(&id, &name))
+				if name.Valid {
+					vals = append(vals, []any{id, name.String})
+				} else {
+					vals = append(vals, []any{id, nil})
+				}
+			}
+			assert.Equal(t, tc.wantErr, rs.Err())
+			assert.Equal(t, tc.wantVals, vals)
+		})
+	}
+}
+
+func (ms *MergerSuite) TestRows_ScanAndClose() {
+	cols := []string{"id", "name"}
+	m := NewMerger(batchmerger.NewMerger())
+	rs, err := m.Merge(context.Background(), ms.queryRows(ms.T(),
+		sqlmock.NewRows(cols).AddRow(1, "a"), sqlmock.NewRows(cols).AddRow(1, "a")))
+	require.NoError(ms.T(), err)
+	var id int
+	var name string
+	assert.Equal(ms.T(), errs.ErrMergerScanNotNext, rs.Scan(&id, &name))
+	columns, err := rs.Columns()
+	require.NoError(ms.T(), err)
+	assert.Equal(ms.T(), cols, columns)
+	require.True(ms.T(), rs.Next())
+	require.NoError(ms.T(), rs.Scan(&id, &name))
+	assert.Equal(ms.T(), 1, id)
+	assert.Equal(ms.T(), "a", name)
+	assert.False(ms.T(), rs.Next())
+	assert.Equal(ms.T(), errs.ErrMergerRowsClosed, rs.Scan(&id, &name))
+	_, err = rs.Columns()
+	assert.Equal(ms.T(), errs.ErrMergerRowsClosed, err)
+}
+
+func TestMerger(t *testing.T) {
+	suite.Run(t, new(MergerSuite))
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package havingmerger
+
+import (
+	"context"
+
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/internal/filter"
+	"github.com/ecodeclub/eorm/internal/rows"
+)
+
+// Predicate 判断归并之后的一行数据是否满足 HAVING 条件
+type Predicate func(row []any) (bool, error)
+
+// Merger 在其它 merger 归并之后的结果上执行 HAVING 过滤。
+// 分库分表的时候，每个分片只能看到部分数据，所以 HAVING 只能在归并之后执行。
+// 为了计算 HAVING，查询里面可能会额外加入一些列，这些列放在最后面，
+// 过滤之后只暴露前 cols 列给用户
+type Merger struct {
+	m         merger.Merger
+	predicate Predicate
+	cols      int
+}
+
+func NewMerger(m merger.Merger, predicate Predicate, cols int) *Merger {
+	return &Merger{
+		m:         m,
+		predicate: predicate,
+		cols:      cols,
+	}
+}
+
+func (m *Merger) Merge(ctx context.Context, results []rows.Rows) (rows.Rows, error) {
+	rs, err := m.m.Merge(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := rs.Columns()
+	if err != nil {
+		_ = rs.Close()
+		return nil, err
+	}
+	cols := m.cols
+	if cols <= 0 || cols > len(columns) {
+		cols = len(columns)
+	}
+	return filter.NewRows(rs, filter.Func(m.predicate), len(columns), columns[:cols]), nil
+}
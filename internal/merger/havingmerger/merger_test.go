@@ -0,0 +1,161 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package havingmerger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/merger/batchmerger"
+	"github.com/ecodeclub/eorm/internal/merger/internal/errs"
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type MergerSuite struct {
+	suite.Suite
+	mockDB01 *sql.DB
+	mock01   sqlmock.Sqlmock
+	mockDB02 *sql.DB
+	mock02   sqlmock.Sqlmock
+}
+
+func (ms *MergerSuite) SetupTest() {
+	var err error
+	ms.mockDB01, ms.mock01, err = sqlmock.New()
+	require.NoError(ms.T(), err)
+	ms.mockDB02, ms.mock02, err = sqlmock.New()
+	require.NoError(ms.T(), err)
+}
+
+func (ms *MergerSuite) TearDownTest() {
+	_ = ms.mockDB01.Close()
+	_ = ms.mockDB02.Close()
+}
+
+func (ms *MergerSuite) queryRows(t *testing.T) []rows.Rows {
+	query := "SELECT * FROM `t1`"
+	cols := []string{"content", "total", "cnt"}
+	ms.mock01.ExpectQuery("SELECT .* FROM `t1`").WillReturnRows(sqlmock.NewRows(cols).
+		AddRow("a", 10, 1).AddRow("b", 20, 3))
+	ms.mock02.ExpectQuery("SELECT .* FROM `t1`").WillReturnRows(sqlmock.NewRows(cols).
+		AddRow("c", 30, 5))
+	res := make([]rows.Rows, 0, 2)
+	for _, db := range []*sql.DB{ms.mockDB01, ms.mockDB02} {
+		rs, err := db.QueryContext(context.Background(), query)
+		require.NoError(t, err)
+		res = append(res, rs)
+	}
+	return res
+}
+
+func (ms *MergerSuite) TestMerger_Merge() {
+	testCases := []struct {
+		name      string
+		predicate Predicate
+		cols      int
+		wantCols  []string
+		wantVals  [][]any
+		wantErr   error
+	}{
+		{
+			name: "filter",
+			predicate: func(row []any) (bool, error) {
+				return row[2].(int64) >= 3, nil
+			},
+			cols:     2,
+			wantCols: []string{"content", "total"},
+			wantVals: [][]any{{"b", int64(20)}, {"c", int64(30)}},
+		},
+		{
+			name: "all columns",
+			predicate: func(row []any) (bool, error) {
+				return row[0].(string) == "a", nil
+			},
+			wantCols: []string{"content", "total", "cnt"},
+			wantVals: [][]any{{"a", int64(10), int64(1)}},
+		},
+		{
+			name: "none",
+			predicate: func(row []any) (bool, error) {
+				return false, nil
+			},
+			cols:     2,
+			wantCols: []string{"content", "total"},
+			wantVals: [][]any{},
+		},
+		{
+			name: "predicate error",
+			predicate: func(row []any) (bool, error) {
+				return false, errors.New("mock predicate error")
+			},
+			cols:     2,
+			wantCols: []string{"content", "total"},
+			wantVals: [][]any{},
+			wantErr:  errors.New("mock predicate error"),
+		},
+	}
+	for _, tc := range testCases {
+		ms.T().Run(tc.name, func(t *testing.T) {
+			m := NewMerger(batchmerger.NewMerger(), tc.predicate, tc.cols)
+			rs, err := m.Merge(context.Background(), ms.queryRows(t))
+			require.NoError(t, err)
+			cols, err := rs.Columns()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantCols, cols)
+			vals := make([][]any, 0, len(tc.wantVals))
+			for rs.Next() {
+				row := make([]any, len(cols))
+				dest := make([]any, len(cols))
+				for i := range row {
+					dest[i] = &row[i]
+				}
+				require.NoError(t, rs.Scan(dest...))
+				vals = append(vals, row)
+			}
+			assert.Equal(t, tc.wantErr, rs.Err())
+			assert.Equal(t, tc.wantVals, vals)
+		})
+	}
+}
+
+func (ms *MergerSuite) TestRows_ScanAndClose() {
+	m := NewMerger(batchmerger.NewMerger(), func(row []any) (bool, error) {
+		return true, nil
+	}, 2)
+	rs, err := m.Merge(context.Background(), ms.queryRows(ms.T()))
+	require.NoError(ms.T(), err)
+	var content string
+	var total int
+	assert.Equal(ms.T(), errs.ErrMergerScanNotNext, rs.Scan(&content, &total))
+	require.True(ms.T(), rs.Next())
+	require.NoError(ms.T(), rs.Scan(&content, &total))
+	assert.Equal(ms.T(), "a", content)
+	assert.Equal(ms.T(), 10, total)
+	require.NoError(ms.T(), rs.Close())
+	assert.False(ms.T(), rs.Next())
+	assert.Equal(ms.T(), errs.ErrMergerRowsClosed, rs.Scan(&content, &total))
+	_, err = rs.Columns()
+	assert.Equal(ms.T(), errs.ErrMergerRowsClosed, err)
+}
+
+func TestMerger(t *testing.T) {
+	suite.Run(t, new(MergerSuite))
+}
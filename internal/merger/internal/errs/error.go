@@ -39,3 +39,7 @@ func NewRepeatSortColumn(column string) error {
 func NewInvalidSortColumn(column string) error {
 	return fmt.Errorf("merger: 数据库字段中没有这个排序列：%s", column)
 }
+
+func NewIncomparableValues(i, j any) error {
+	return fmt.Errorf("merger: 排序列的值无法比较：%T 和 %T", i, j)
+}
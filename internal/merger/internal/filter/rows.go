@@ -0,0 +1,143 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/ecodeclub/eorm/internal/merger/internal/errs"
+	"github.com/ecodeclub/eorm/internal/rows"
+)
+
+// Func 判断一行数据是否需要保留，row 包含下层结果集的全部列
+type Func func(row []any) (bool, error)
+
+// Rows 逐行读取下层的结果集，只返回 fn 判断需要保留的数据。
+// 下层结果集可能比暴露给用户的列多，多出来的列放在最后面，只在 fn 里面使用
+type Rows struct {
+	rows    rows.Rows
+	fn      Func
+	allCols int
+	columns []string
+	cur     []any
+	lastErr error
+	closed  bool
+	mu      *sync.RWMutex
+}
+
+// NewRows 创建 Rows，columns 是暴露给用户的列，allCols 是下层结果集的列数
+func NewRows(rs rows.Rows, fn Func, allCols int, columns []string) *Rows {
+	return &Rows{
+		rows:    rs,
+		fn:      fn,
+		allCols: allCols,
+		columns: columns,
+		mu:      &sync.RWMutex{},
+	}
+}
+
+func (*Rows) NextResultSet() bool {
+	return false
+}
+
+func (r *Rows) Next() bool {
+	r.mu.Lock()
+	if r.closed || r.lastErr != nil {
+		r.mu.Unlock()
+		return false
+	}
+	for r.rows.Next() {
+		row, err := r.scan()
+		if err == nil {
+			var ok bool
+			ok, err = r.fn(row)
+			if ok {
+				r.cur = row
+				r.mu.Unlock()
+				return true
+			}
+		}
+		if err != nil {
+			r.lastErr = err
+			r.mu.Unlock()
+			_ = r.Close()
+			return false
+		}
+	}
+	r.lastErr = r.rows.Err()
+	r.mu.Unlock()
+	_ = r.Close()
+	return false
+}
+
+func (r *Rows) scan() ([]any, error) {
+	row := make([]any, r.allCols)
+	dest := make([]any, r.allCols)
+	for i := range row {
+		dest[i] = &row[i]
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (r *Rows) Scan(dest ...any) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.lastErr != nil {
+		return r.lastErr
+	}
+	if r.closed {
+		return errs.ErrMergerRowsClosed
+	}
+	if r.cur == nil {
+		return errs.ErrMergerScanNotNext
+	}
+	for i := 0; i < len(dest); i++ {
+		err := rows.ConvertAssign(dest[i], r.cur[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rows) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return r.rows.Close()
+}
+
+func (r *Rows) ColumnTypes() ([]*sql.ColumnType, error) {
+	return r.rows.ColumnTypes()
+}
+
+func (r *Rows) Columns() ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return nil, errs.ErrMergerRowsClosed
+	}
+	return r.columns, nil
+}
+
+func (r *Rows) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lastErr
+}
@@ -0,0 +1,76 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/merger/internal/errs"
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRows(t *testing.T) {
+	testCases := []struct {
+		name     string
+		fn       Func
+		wantData [][]any
+		wantErr  error
+	}{
+		{
+			name: "keep part",
+			fn: func(row []any) (bool, error) {
+				return row[1].(int64) > 1, nil
+			},
+			wantData: [][]any{{"b"}, {"c"}},
+		},
+		{
+			name: "keep none",
+			fn: func(row []any) (bool, error) {
+				return false, nil
+			},
+		},
+		{
+			name: "filter error",
+			fn: func(row []any) (bool, error) {
+				return false, errors.New("filter error")
+			},
+			wantErr: errors.New("filter error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rs := NewRows(rows.NewDataRows([][]any{
+				{"a", int64(1)}, {"b", int64(2)}, {"c", int64(3)},
+			}, []string{"content", "cnt"}, nil), tc.fn, 2, []string{"content"})
+			cols, err := rs.Columns()
+			require.NoError(t, err)
+			assert.Equal(t, []string{"content"}, cols)
+			var data [][]any
+			for rs.Next() {
+				var content string
+				require.NoError(t, rs.Scan(&content))
+				data = append(data, []any{content})
+			}
+			assert.Equal(t, tc.wantErr, rs.Err())
+			assert.Equal(t, tc.wantData, data)
+			assert.False(t, rs.Next())
+			_, err = rs.Columns()
+			assert.Equal(t, errs.ErrMergerRowsClosed, err)
+		})
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sortmerger
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/internal/errs"
+	"github.com/ecodeclub/eorm/internal/rows"
+)
+
+var _ merger.Merger = &MemoryMerger{}
+
+// MemoryMerger 读取 Merger 归并之后的全部数据，在内存里面排序。
+// Merger 要求每个分片的结果已经有序，而 GROUP BY 归并之后的结果是无序的，所以需要用它重新排序
+type MemoryMerger struct {
+	sortColumns
+	m merger.Merger
+}
+
+func NewMemoryMerger(m merger.Merger, sortCols ...SortColumn) (*MemoryMerger, error) {
+	scs, err := newSortColumns(sortCols...)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryMerger{sortColumns: scs, m: m}, nil
+}
+
+func (m *MemoryMerger) Merge(ctx context.Context, results []rows.Rows) (rows.Rows, error) {
+	rs, err := m.m.Merge(ctx, results)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rs.Close()
+	}()
+	cols, err := rs.Columns()
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]int, 0, m.Len())
+	for _, sc := range m.columns {
+		idx := slicesIndex(cols, sc.name)
+		if idx < 0 {
+			return nil, errs.NewInvalidSortColumn(sc.name)
+		}
+		indexes = append(indexes, idx)
+	}
+	data := make([][]any, 0, 16)
+	for rs.Next() {
+		row := make([]any, len(cols))
+		dest := make([]any, len(cols))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err = rs.Scan(dest...); err != nil {
+			return nil, err
+		}
+		data = append(data, row)
+	}
+	if err = rs.Err(); err != nil {
+		return nil, err
+	}
+	var cmpErr error
+	sort.SliceStable(data, func(i, j int) bool {
+		for k, idx := range indexes {
+			res, err := compareValue(data[i][idx], data[j][idx])
+			if err != nil {
+				if cmpErr == nil {
+					cmpErr = err
+				}
+				return false
+			}
+			if res == 0 {
+				continue
+			}
+			if m.columns[k].order == DESC {
+				res = -res
+			}
+			return res < 0
+		}
+		return false
+	})
+	if cmpErr != nil {
+		return nil, cmpErr
+	}
+	// 聚合之后的列和分片结果的列并不是一一对应的，所以没有列类型
+	return rows.NewDataRows(data, cols, nil), nil
+}
+
+func slicesIndex(cols []string, name string) int {
+	for i, c := range cols {
+		if c == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// compareValue 比较两个值，NULL 永远是最小值
+func compareValue(i, j any) (int, error) {
+	var err error
+	if v, ok := i.(driver.Valuer); ok {
+		if i, err = v.Value(); err != nil {
+			return 0, err
+		}
+	}
+	if v, ok := j.(driver.Valuer); ok {
+		if j, err = v.Value(); err != nil {
+			return 0, err
+		}
+	}
+	switch {
+	case i == nil && j == nil:
+		return 0, nil
+	case i == nil:
+		return -1, nil
+	case j == nil:
+		return 1, nil
+	}
+	if ti, ok := i.(time.Time); ok {
+		if tj, ok := j.(time.Time); ok {
+			return ti.Compare(tj), nil
+		}
+	}
+	if bi, ok := i.([]byte); ok {
+		if bj, ok := j.([]byte); ok {
+			return bytes.Compare(bi, bj), nil
+		}
+	}
+	vi, vj := reflect.ValueOf(i), reflect.ValueOf(j)
+	switch {
+	case vi.CanInt() && vj.CanInt():
+		return cmpOrdered(vi.Int(), vj.Int()), nil
+	case vi.CanUint() && vj.CanUint():
+		return cmpOrdered(vi.Uint(), vj.Uint()), nil
+	case isNumber(vi) && isNumber(vj):
+		return cmpOrdered(toFloat(vi), toFloat(vj)), nil
+	case vi.Kind() == reflect.String && vj.Kind() == reflect.String:
+		return cmpOrdered(vi.String(), vj.String()), nil
+	case vi.Kind() == reflect.Bool && vj.Kind() == reflect.Bool:
+		return cmpOrdered(boolToInt(vi.Bool()), boolToInt(vj.Bool())), nil
+	}
+	return 0, errs.NewIncomparableValues(i, j)
+}
+
+func cmpOrdered[T Ordered](i, j T) int {
+	if i < j {
+		return -1
+	}
+	if i > j {
+		return 1
+	}
+	return 0
+}
+
+func isNumber(v reflect.Value) bool {
+	return v.CanInt() || v.CanUint() || v.CanFloat()
+}
+
+func toFloat(v reflect.Value) float64 {
+	switch {
+	case v.CanInt():
+		return float64(v.Int())
+	case v.CanUint():
+		return float64(v.Uint())
+	default:
+		return v.Float()
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sortmerger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/merger/internal/errs"
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dataMerger struct {
+	rs  rows.Rows
+	err error
+}
+
+func (m dataMerger) Merge(ctx context.Context, results []rows.Rows) (rows.Rows, error) {
+	return m.rs, m.err
+}
+
+func TestMemoryMerger_Merge(t *testing.T) {
+	cols := []string{"content", "cnt"}
+	testCases := []struct {
+		name     string
+		merger   dataMerger
+		sortCols []SortColumn
+		wantData [][]any
+		wantErr  error
+	}{
+		{
+			name: "desc",
+			merger: dataMerger{rs: rows.NewDataRows([][]any{
+				{"b", int64(1)}, {"z", int64(2)}, {"a", int64(3)},
+			}, cols, nil)},
+			sortCols: []SortColumn{NewSortColumn("content", DESC)},
+			wantData: [][]any{{"z", int64(2)}, {"b", int64(1)}, {"a", int64(3)}},
+		},
+		{
+			name: "multiple columns with null",
+			merger: dataMerger{rs: rows.NewDataRows([][]any{
+				{"b", int64(1)}, {"a", int64(2)}, {"b", nil}, {"a", 1.5},
+			}, cols, nil)},
+			sortCols: []SortColumn{NewSortColumn("content", ASC), NewSortColumn("cnt", DESC)},
+			wantData: [][]any{{"a", int64(2)}, {"a", 1.5}, {"b", int64(1)}, {"b", nil}},
+		},
+		{
+			name: "nullable",
+			merger: dataMerger{rs: rows.NewDataRows([][]any{
+				{sql.NullString{String: "b", Valid: true}, int64(1)},
+				{sql.NullString{}, int64(2)},
+			}, cols, nil)},
+			sortCols: []SortColumn{NewSortColumn("content", ASC)},
+			wantData: [][]any{{nil, int64(2)}, {"b", int64(1)}},
+		},
+		{
+			name: "invalid sort column",
+			merger: dataMerger{rs: rows.NewDataRows([][]any{
+				{"b", int64(1)},
+			}, cols, nil)},
+			sortCols: []SortColumn{NewSortColumn("id", ASC)},
+			wantErr:  errs.NewInvalidSortColumn("id"),
+		},
+		{
+			name: "incomparable",
+			merger: dataMerger{rs: rows.NewDataRows([][]any{
+				{"b", int64(1)}, {int64(1), int64(1)},
+			}, cols, nil)},
+			sortCols: []SortColumn{NewSortColumn("content", ASC)},
+			wantErr:  errs.NewIncomparableValues(int64(1), "b"),
+		},
+		{
+			name:     "merge error",
+			merger:   dataMerger{err: errors.New("merge error")},
+			sortCols: []SortColumn{NewSortColumn("content", ASC)},
+			wantErr:  errors.New("merge error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := NewMemoryMerger(tc.merger, tc.sortCols...)
+			require.NoError(t, err)
+			rs, err := m.Merge(context.Background(), nil)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			var data [][]any
+			for rs.Next() {
+				row := make([]any, len(cols))
+				dest := make([]any, len(cols))
+				for i := range row {
+					dest[i] = &row[i]
+				}
+				require.NoError(t, rs.Scan(dest...))
+				data = append(data, row)
+			}
+			assert.Equal(t, tc.wantData, data)
+		})
+	}
+}
@@ -38,6 +38,14 @@ type TableMeta struct {
 	Typ       reflect.Type
 
 	ShardingAlgorithm sharding.Algorithm
+	// BroadcastTable 表示这是一张广播表，也叫全局表。
+	// 广播表在 ShardingAlgorithm.Broadcast 返回的每一个目标上都有一份完整的数据，
+	// 写操作会同时写入全部目标，读操作只需要读其中一个
+	BroadcastTable bool
+	// BindingGroup 绑定表分组。
+	// 同一个分组里面的表使用相同的 sharding key 和分片规则，
+	// 它们的分片结果一一对应，所以可以在每个分片上直接 JOIN
+	BindingGroup string
 }
 
 // ColumnMeta represents model's field, or column
@@ -59,6 +67,8 @@ type ColumnMeta struct {
 	Offset uintptr
 	// FieldIndexes 用于表达从最外层结构体找到当前ColumnMeta对应的Field所需要的索引集
 	FieldIndexes []int
+	// AutoID 是自动生成主键使用的生成器的名字，通过标签 auto_id=xxx 指定
+	AutoID string
 }
 
 // TableMetaOption represents options of TableMeta, this options will cover default cover.
@@ -70,6 +80,21 @@ func WithTableShardingAlgorithm(algorithm sharding.Algorithm) TableMetaOption {
 	}
 }
 
+// WithBroadcastTable 将表标记为广播表，需要和 WithTableShardingAlgorithm 一起使用
+func WithBroadcastTable() TableMetaOption {
+	return func(meta *TableMeta) {
+		meta.BroadcastTable = true
+	}
+}
+
+// WithBindingGroup 将表加入绑定表分组 group，
+// 例如 order 和 order_detail 都按照 OrderId 分片，就可以放在同一个分组里面
+func WithBindingGroup(group string) TableMetaOption {
+	return func(meta *TableMeta) {
+		meta.BindingGroup = group
+	}
+}
+
 // MetaRegistry stores table metadata
 type MetaRegistry interface {
 	Get(table interface{}) (*TableMeta, error)
@@ -143,12 +168,15 @@ func (t *tagMetaRegistry) parseFields(v reflect.Type, fieldIndexes []int,
 		structField := v.Field(i)
 		tag := structField.Tag.Get("eorm")
 		var isKey, isIgnore bool
+		var autoID string
 		for _, t := range strings.Split(tag, ",") {
-			switch t {
-			case "primary_key":
+			switch {
+			case t == "primary_key":
 				isKey = true
-			case "-":
+			case t == "-":
 				isIgnore = true
+			case strings.HasPrefix(t, "auto_id="):
+				autoID = strings.TrimPrefix(t, "auto_id=")
 			}
 		}
 		if isIgnore {
@@ -181,6 +209,7 @@ func (t *tagMetaRegistry) parseFields(v reflect.Type, fieldIndexes []int,
 			IsPrimaryKey: isKey,
 			Offset:       structField.Offset + pOffset,
 			FieldIndexes: append(fieldIndexes, i),
+			AutoID:       autoID,
 		}
 		*columnMetas = append(*columnMetas, columnMeta)
 		fieldMap[columnMeta.FieldName] = columnMeta
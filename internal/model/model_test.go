@@ -71,6 +71,31 @@ func TestTagMetaRegistry(t *testing.T) {
 			}.build(),
 			input: &TestModel{},
 		},
+		{
+			name: "auto id",
+			wantMeta: tableMetaBuilder{
+				TableName: "auto_id_model",
+				Columns: []*ColumnMeta{
+					{
+						ColumnName:   "id",
+						FieldName:    "Id",
+						Typ:          reflect.TypeOf(int64(0)),
+						IsPrimaryKey: true,
+						FieldIndexes: []int{0},
+						AutoID:       "snowflake",
+					},
+					{
+						ColumnName:   "name",
+						FieldName:    "Name",
+						Typ:          reflect.TypeOf(""),
+						Offset:       8,
+						FieldIndexes: []int{1},
+					},
+				},
+				Typ: reflect.TypeOf(&AutoIdModel{}),
+			}.build(),
+			input: &AutoIdModel{},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -502,6 +527,11 @@ type TestModel struct {
 	LastName  *string
 }
 
+type AutoIdModel struct {
+	Id   int64 `eorm:"primary_key,auto_id=snowflake"`
+	Name string
+}
+
 type BaseEntity struct {
 	CreateTime uint64
 	UpdateTime uint64
@@ -44,6 +44,9 @@ var (
 	OpLike    = Op{Symbol: "LIKE", Text: " LIKE "}
 	OpNotLike = Op{Symbol: "NOT LIKE", Text: " NOT LIKE "}
 	OpExist   = Op{Symbol: "EXIST", Text: "EXIST "}
+	// OpBetween 的右边是 AND 连接起来的两个端点
+	OpBetween    = Op{Symbol: "BETWEEN", Text: " BETWEEN "}
+	OpNotBetween = Op{Symbol: "NOT BETWEEN", Text: " NOT BETWEEN "}
 )
 
 func NegateOp(op Op) (Op, error) {
@@ -64,6 +67,14 @@ func NegateOp(op Op) (Op, error) {
 		return OpLT, nil
 	case OpLTEQ:
 		return OpGT, nil
+	case OpBetween:
+		return OpNotBetween, nil
+	case OpNotBetween:
+		return OpBetween, nil
+	case OpLike:
+		return OpNotLike, nil
+	case OpNotLike:
+		return OpLike, nil
 	default:
 		return emptyOp, errs.NewUnsupportedOperatorError(op.Text)
 	}
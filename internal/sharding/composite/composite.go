@@ -0,0 +1,136 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+import (
+	"context"
+
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// Composite 在数据源、DB 和表三个层级上分别使用不同的 sharding key 和算法。
+// 例如按照 UserId 分库，按照 OrderId 分表。
+// 每一层的算法只取结果中对应层级的部分：Datasource 只取 Dst.Name，
+// DB 只取 Dst.DB，Table 只取 Dst.Table，最终的结果是三者的笛卡尔积
+type Composite struct {
+	Datasource sharding.Algorithm
+	DB         sharding.Algorithm
+	Table      sharding.Algorithm
+}
+
+func (c *Composite) Broadcast(ctx context.Context) []sharding.Dst {
+	if c.Datasource == nil || c.DB == nil || c.Table == nil {
+		return nil
+	}
+	return product(
+		names(c.Datasource.Broadcast(ctx), dsName),
+		names(c.DB.Broadcast(ctx), dbName),
+		names(c.Table.Broadcast(ctx), tableName))
+}
+
+// Sharding 将请求交给每一层的算法。
+// 请求中没有某一层的 sharding key 的时候，那一层的算法会返回它所有的目标，
+// 所以多个查询条件 AND 起来的时候，求交集就能得到准确的结果
+func (c *Composite) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
+	if c.Datasource == nil || c.DB == nil || c.Table == nil {
+		return sharding.EmptyResp, errs.ErrIncompleteCompositeAlgorithm
+	}
+	ds, err := c.Datasource.Sharding(ctx, req)
+	if err != nil {
+		return sharding.EmptyResp, err
+	}
+	db, err := c.DB.Sharding(ctx, req)
+	if err != nil {
+		return sharding.EmptyResp, err
+	}
+	tbl, err := c.Table.Sharding(ctx, req)
+	if err != nil {
+		return sharding.EmptyResp, err
+	}
+	return sharding.Response{
+		Dsts: product(names(ds.Dsts, dsName), names(db.Dsts, dbName), names(tbl.Dsts, tableName)),
+	}, nil
+}
+
+// ShardingKeys 返回所有层级的 sharding key，去除了重复的部分
+func (c *Composite) ShardingKeys() []string {
+	var res []string
+	for _, algo := range []sharding.Algorithm{c.Datasource, c.DB, c.Table} {
+		if algo == nil {
+			continue
+		}
+		for _, sk := range algo.ShardingKeys() {
+			if !slice.Contains[string](res, sk) {
+				res = append(res, sk)
+			}
+		}
+	}
+	return res
+}
+
+// Fixed 表示某一层不分片，总是返回同一个目标
+type Fixed struct {
+	Dst sharding.Dst
+}
+
+func (f Fixed) Broadcast(ctx context.Context) []sharding.Dst {
+	return []sharding.Dst{f.Dst}
+}
+
+func (f Fixed) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
+	return sharding.Response{Dsts: []sharding.Dst{f.Dst}}, nil
+}
+
+func (Fixed) ShardingKeys() []string {
+	return nil
+}
+
+func dsName(dst sharding.Dst) string {
+	return dst.Name
+}
+
+func dbName(dst sharding.Dst) string {
+	return dst.DB
+}
+
+func tableName(dst sharding.Dst) string {
+	return dst.Table
+}
+
+// names 取出某一层级的名字，保持原本的顺序并且去重
+func names(dsts []sharding.Dst, name func(dst sharding.Dst) string) []string {
+	res := make([]string, 0, len(dsts))
+	for _, dst := range dsts {
+		n := name(dst)
+		if !slice.Contains[string](res, n) {
+			res = append(res, n)
+		}
+	}
+	return res
+}
+
+func product(dss, dbs, tbls []string) []sharding.Dst {
+	res := make([]sharding.Dst, 0, len(dss)*len(dbs)*len(tbls))
+	for _, ds := range dss {
+		for _, db := range dbs {
+			for _, tbl := range tbls {
+				res = append(res, sharding.Dst{Name: ds, DB: db, Table: tbl})
+			}
+		}
+	}
+	return res
+}
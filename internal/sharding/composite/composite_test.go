@@ -0,0 +1,102 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposite(t *testing.T) {
+	ds := "0.db.cluster.company.com:3306"
+	c := &Composite{
+		Datasource: Fixed{Dst: sharding.Dst{Name: ds}},
+		DB: &hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{NotSharding: true},
+			DsPattern:    &hash.Pattern{NotSharding: true},
+		},
+		Table: &hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{NotSharding: true},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{NotSharding: true},
+		},
+	}
+	dst := func(db, tbl string) sharding.Dst {
+		return sharding.Dst{Name: ds, DB: db, Table: tbl}
+	}
+	assert.Equal(t, []string{"UserId", "OrderId"}, c.ShardingKeys())
+	assert.Equal(t, []sharding.Dst{
+		dst("order_db_0", "order_tab_0"), dst("order_db_0", "order_tab_1"), dst("order_db_0", "order_tab_2"),
+		dst("order_db_1", "order_tab_0"), dst("order_db_1", "order_tab_1"), dst("order_db_1", "order_tab_2"),
+	}, c.Broadcast(context.Background()))
+
+	testCases := []struct {
+		name    string
+		algo    sharding.Algorithm
+		req     sharding.Request
+		wantRes []sharding.Dst
+		wantErr error
+	}{
+		{
+			name:    "db key only",
+			algo:    c,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"UserId": 3}},
+			wantRes: []sharding.Dst{dst("order_db_1", "order_tab_0"), dst("order_db_1", "order_tab_1"), dst("order_db_1", "order_tab_2")},
+		},
+		{
+			name:    "table key only",
+			algo:    c,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": 5}},
+			wantRes: []sharding.Dst{dst("order_db_0", "order_tab_2"), dst("order_db_1", "order_tab_2")},
+		},
+		{
+			name:    "all keys",
+			algo:    c,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"UserId": 3, "OrderId": 5}},
+			wantRes: []sharding.Dst{dst("order_db_1", "order_tab_2")},
+		},
+		{
+			name:    "error",
+			algo:    c,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": 1.2}},
+			wantErr: errs.NewErrUnsupportedShardingValue(1.2),
+		},
+		{
+			name:    "incomplete",
+			algo:    &Composite{DB: c.DB},
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"UserId": 3}},
+			wantErr: errs.ErrIncompleteCompositeAlgorithm,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.algo.Sharding(context.Background(), tc.req)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res.Dsts)
+		})
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datetime
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// Granularity 分片的时间粒度
+type Granularity int
+
+const (
+	Day Granularity = iota + 1
+	// Week 每周一个分片，一周从周一开始
+	Week
+	Month
+	Year
+)
+
+// Pattern 使用 time.Time 的 Layout 生成名字。
+// 例如 Name 为 order_%s，Layout 为 200601，那么 2024 年 10 月的数据存储在 order_202410 上
+type Pattern struct {
+	Name   string
+	Layout string
+	// NotSharding 为 true 的时候直接使用 Name
+	NotSharding bool
+}
+
+func (p *Pattern) format(t time.Time) string {
+	if p == nil {
+		return ""
+	}
+	if p.NotSharding {
+		return p.Name
+	}
+	return fmt.Sprintf(p.Name, t.Format(p.Layout))
+}
+
+// DateTime 按照时间分库分表。
+// sharding key 可以是 time.Time，也可以是以秒为单位的 unix 时间戳。
+// 如果 DB 的粒度比表粗，例如按年分库、按月分表，只需要让 DBPattern 的 Layout 只包含年份即可
+type DateTime struct {
+	ShardingKey  string
+	Granularity  Granularity
+	DsPattern    *Pattern
+	DBPattern    *Pattern
+	TablePattern *Pattern
+	// Start 和 End 限定了已经创建的分片的范围 [Start, End)，Start 必须早于 End。
+	// 所有的查询都只会落在这个范围内，范围之外的值找不到任何目标表
+	Start time.Time
+	End   time.Time
+	// Location 计算分片时使用的时区，默认是 time.Local
+	Location *time.Location
+}
+
+// NewDateTime 检查 sharding key 和 [start, end) 是否合法。
+// DsPattern、DBPattern、TablePattern 和 Location 在返回之后设置
+func NewDateTime(shardingKey string, granularity Granularity, start, end time.Time) (*DateTime, error) {
+	d := &DateTime{
+		ShardingKey: shardingKey,
+		Granularity: granularity,
+		Start:       start,
+		End:         end,
+	}
+	if err := d.check(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *DateTime) check() error {
+	if d.ShardingKey == "" {
+		return errs.ErrMissingShardingKey
+	}
+	if !d.Start.Before(d.End) {
+		return errs.NewErrInvalidShardingInterval(d.Start, d.End)
+	}
+	return nil
+}
+
+// Broadcast 返回 [Start, End) 内的全部分片，Start 不早于 End 的时候没有任何分片
+func (d *DateTime) Broadcast(ctx context.Context) []sharding.Dst {
+	return d.findDsts(d.Start, d.End.Add(-time.Nanosecond))
+}
+
+func (d *DateTime) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
+	if err := d.check(); err != nil {
+		return sharding.EmptyResp, err
+	}
+	skVal, ok := req.SkValues[d.ShardingKey]
+	if !ok {
+		return sharding.Response{Dsts: d.Broadcast(ctx)}, nil
+	}
+	switch req.Op {
+	case operator.OpNEQ, operator.OpNotIN:
+		return sharding.Response{Dsts: d.Broadcast(ctx)}, nil
+	case operator.OpIn:
+		return d.shardingIn(skVal)
+	case operator.OpBetween:
+		return d.shardingRange(ctx, skVal)
+	case operator.OpEQ:
+		t, err := toTime(skVal)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		return sharding.Response{Dsts: d.findDsts(t, t)}, nil
+	case operator.OpGT, operator.OpLT, operator.OpGTEQ, operator.OpLTEQ:
+		t, err := toTime(skVal)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		lo, hi := d.bounds(req.Op, t)
+		return sharding.Response{Dsts: d.findDsts(lo, hi)}, nil
+	default:
+		return sharding.EmptyResp, errs.NewUnsupportedOperatorError(req.Op.Text)
+	}
+}
+
+// shardingIn 的 skVal 是一个切片，结果是每个值对应的目标表的并集
+func (d *DateTime) shardingIn(skVal any) (sharding.Response, error) {
+	vals := reflect.ValueOf(skVal)
+	if vals.Kind() != reflect.Slice && vals.Kind() != reflect.Array {
+		return sharding.EmptyResp, errs.NewErrUnsupportedShardingValue(skVal)
+	}
+	var dsts []sharding.Dst
+	for i := 0; i < vals.Len(); i++ {
+		t, err := toTime(vals.Index(i).Interface())
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		for _, dst := range d.findDsts(t, t) {
+			dsts = appendDst(dsts, dst)
+		}
+	}
+	return sharding.Response{Dsts: dsts}, nil
+}
+
+// shardingRange 的 skVal 是 sharding.Range，结果限定在 [Start, End) 之内。
+// 字符串的范围来自于前缀 LIKE，无法转化为时间，所以只能广播
+func (d *DateTime) shardingRange(ctx context.Context, skVal any) (sharding.Response, error) {
+	rg, ok := skVal.(sharding.Range)
+	if !ok {
+		return sharding.EmptyResp, errs.NewErrUnsupportedShardingValue(skVal)
+	}
+	if isString(rg.Start) || isString(rg.End) {
+		return sharding.Response{Dsts: d.Broadcast(ctx)}, nil
+	}
+	lo, hi := d.Start, d.End.Add(-time.Nanosecond)
+	if rg.Start != nil {
+		t, err := toTime(rg.Start)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		lo = maxTime(lo, t)
+	}
+	if rg.End != nil {
+		t, err := toTime(rg.End)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		if rg.ExclusiveEnd {
+			t = t.Add(-time.Nanosecond)
+		}
+		hi = minTime(hi, t)
+	}
+	return sharding.Response{Dsts: d.findDsts(lo, hi)}, nil
+}
+
+// bounds 将比较运算转化为闭区间 [lo, hi]，并且限定在 [Start, End) 之内
+func (d *DateTime) bounds(op operator.Op, t time.Time) (time.Time, time.Time) {
+	lo, hi := d.Start, d.End.Add(-time.Nanosecond)
+	switch op {
+	case operator.OpGT:
+		lo = maxTime(lo, t.Add(time.Nanosecond))
+	case operator.OpGTEQ:
+		lo = maxTime(lo, t)
+	case operator.OpLT:
+		hi = minTime(hi, t.Add(-time.Nanosecond))
+	case operator.OpLTEQ:
+		hi = minTime(hi, t)
+	}
+	return lo, hi
+}
+
+// findDsts 找到闭区间 [lo, hi] 和 [Start, End) 的交集内的所有分片
+func (d *DateTime) findDsts(lo, hi time.Time) []sharding.Dst {
+	lo, hi = maxTime(lo, d.Start), minTime(hi, d.End.Add(-time.Nanosecond))
+	if lo.After(hi) {
+		return nil
+	}
+	var res []sharding.Dst
+	for t := d.truncate(lo); !t.After(hi); t = d.next(t) {
+		res = appendDst(res, d.dstOf(t))
+	}
+	return res
+}
+
+func (d *DateTime) dstOf(t time.Time) sharding.Dst {
+	t = t.In(d.location())
+	return sharding.Dst{
+		Name:  d.DsPattern.format(t),
+		DB:    d.DBPattern.format(t),
+		Table: d.TablePattern.format(t),
+	}
+}
+
+// truncate 返回 t 所在分片的起始时间
+func (d *DateTime) truncate(t time.Time) time.Time {
+	t = t.In(d.location())
+	y, m, day := t.Date()
+	switch d.Granularity {
+	case Year:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, t.Location())
+	case Month:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case Week:
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, day-offset, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, day, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// next 返回下一个分片的起始时间
+func (d *DateTime) next(t time.Time) time.Time {
+	switch d.Granularity {
+	case Year:
+		return t.AddDate(1, 0, 0)
+	case Month:
+		return t.AddDate(0, 1, 0)
+	case Week:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+func (d *DateTime) location() *time.Location {
+	if d.Location == nil {
+		return time.Local
+	}
+	return d.Location
+}
+
+func (d *DateTime) ShardingKeys() []string {
+	return []string{d.ShardingKey}
+}
+
+// appendDst 相邻的分片可能落在同一个 DB 和表上，例如按天遍历，按月分表
+func appendDst(dsts []sharding.Dst, dst sharding.Dst) []sharding.Dst {
+	for _, d := range dsts {
+		if d.Equals(dst) {
+			return dsts
+		}
+	}
+	return append(dsts, dst)
+}
+
+func isString(val any) bool {
+	return val != nil && reflect.TypeOf(val).Kind() == reflect.String
+}
+
+func toTime(val any) (time.Time, error) {
+	if t, ok := val.(time.Time); ok {
+		return t, nil
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return time.Unix(v.Int(), 0), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return time.Time{}, errs.NewErrUnsupportedShardingValue(val)
+		}
+		return time.Unix(int64(v.Uint()), 0), nil
+	default:
+		return time.Time{}, errs.NewErrUnsupportedShardingValue(val)
+	}
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
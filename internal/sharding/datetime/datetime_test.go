@@ -0,0 +1,260 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datetime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDateTime_Sharding(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	monthly := &DateTime{
+		ShardingKey:  "CreateTime",
+		Granularity:  Month,
+		DsPattern:    &Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		DBPattern:    &Pattern{Name: "order_db_%s", Layout: "2006"},
+		TablePattern: &Pattern{Name: "order_%s", Layout: "200601"},
+		Start:        date(2024, 11, 1),
+		End:          date(2025, 3, 1),
+		Location:     time.UTC,
+	}
+	dst := func(db, tbl string) sharding.Dst {
+		return sharding.Dst{Name: "0.db.cluster.company.com:3306", DB: db, Table: tbl}
+	}
+	weekly := &DateTime{
+		ShardingKey:  "CreateTime",
+		Granularity:  Week,
+		DsPattern:    &Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		DBPattern:    &Pattern{Name: "log_db", NotSharding: true},
+		TablePattern: &Pattern{Name: "log_%s", Layout: "20060102"},
+		Start:        date(2024, 10, 7),
+		End:          date(2024, 10, 28),
+		Location:     time.UTC,
+	}
+	daily := &DateTime{
+		ShardingKey:  "CreateTime",
+		Granularity:  Day,
+		DsPattern:    &Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		DBPattern:    &Pattern{Name: "log_db", NotSharding: true},
+		TablePattern: &Pattern{Name: "log_%s", Layout: "20060102"},
+		Location:     time.UTC,
+	}
+	testCases := []struct {
+		name    string
+		algo    *DateTime
+		req     sharding.Request
+		wantRes []sharding.Dst
+		wantErr error
+	}{
+		{
+			name:    "broadcast",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"Id": 12}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202411"), dst("order_db_2024", "order_202412"), dst("order_db_2025", "order_202501"), dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "without window",
+			algo:    daily,
+			req:     sharding.Request{Op: operator.OpNEQ, SkValues: map[string]any{"CreateTime": date(2024, 10, 1)}},
+			wantErr: errs.NewErrInvalidShardingInterval(time.Time{}, time.Time{}),
+		},
+		{
+			name:    "eq",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"CreateTime": time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202412")},
+		},
+		{
+			name:    "eq unix timestamp",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"CreateTime": date(2025, 1, 1).Unix()}},
+			wantRes: []sharding.Dst{dst("order_db_2025", "order_202501")},
+		},
+		{
+			name:    "eq out of window",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"CreateTime": date(2023, 1, 1)}},
+			wantRes: nil,
+		},
+		{
+			name:    "eq end of window",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"CreateTime": date(2025, 3, 1)}},
+			wantRes: nil,
+		},
+		{
+			name:    "gteq",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpGTEQ, SkValues: map[string]any{"CreateTime": date(2025, 1, 1)}},
+			wantRes: []sharding.Dst{dst("order_db_2025", "order_202501"), dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "gt",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpGT, SkValues: map[string]any{"CreateTime": date(2025, 2, 1).Add(-time.Nanosecond)}},
+			wantRes: []sharding.Dst{dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "lt",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpLT, SkValues: map[string]any{"CreateTime": date(2025, 1, 1)}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202411"), dst("order_db_2024", "order_202412")},
+		},
+		{
+			name:    "lteq",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpLTEQ, SkValues: map[string]any{"CreateTime": date(2024, 12, 1)}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202411"), dst("order_db_2024", "order_202412")},
+		},
+		{
+			name:    "lt before window",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpLT, SkValues: map[string]any{"CreateTime": date(2024, 11, 1)}},
+			wantRes: nil,
+		},
+		{
+			name:    "in",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpIn, SkValues: map[string]any{"CreateTime": []any{date(2024, 11, 3), date(2024, 11, 30), date(2025, 2, 1)}}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202411"), dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "in out of window",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpIn, SkValues: map[string]any{"CreateTime": []any{date(2024, 1, 3), date(2025, 2, 1), date(2025, 5, 1)}}},
+			wantRes: []sharding.Dst{dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "between",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"CreateTime": sharding.Range{Start: date(2024, 12, 31), End: date(2025, 1, 1)}}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202412"), dst("order_db_2025", "order_202501")},
+		},
+		{
+			name:    "between exclusive end",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"CreateTime": sharding.Range{Start: date(2024, 12, 31), End: date(2025, 1, 1), ExclusiveEnd: true}}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202412")},
+		},
+		{
+			name:    "between without end",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"CreateTime": sharding.Range{Start: date(2025, 2, 10).Unix()}}},
+			wantRes: []sharding.Dst{dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "between string",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"CreateTime": sharding.Range{Start: "2024", End: "2025", ExclusiveEnd: true}}},
+			wantRes: []sharding.Dst{dst("order_db_2024", "order_202411"), dst("order_db_2024", "order_202412"), dst("order_db_2025", "order_202501"), dst("order_db_2025", "order_202502")},
+		},
+		{
+			name:    "week",
+			algo:    weekly,
+			req:     sharding.Request{Op: operator.OpGTEQ, SkValues: map[string]any{"CreateTime": date(2024, 10, 20)}},
+			wantRes: []sharding.Dst{dst("log_db", "log_20241014"), dst("log_db", "log_20241021")},
+		},
+		{
+			name:    "invalid value",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"CreateTime": "2024-10-01"}},
+			wantErr: errs.NewErrUnsupportedShardingValue("2024-10-01"),
+		},
+		{
+			name:    "unsupported operator",
+			algo:    monthly,
+			req:     sharding.Request{Op: operator.OpLike, SkValues: map[string]any{"CreateTime": date(2024, 10, 1)}},
+			wantErr: errs.NewUnsupportedOperatorError(operator.OpLike.Text),
+		},
+		{
+			name:    "missing sharding key",
+			algo:    &DateTime{},
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"CreateTime": date(2024, 10, 1)}},
+			wantErr: errs.ErrMissingShardingKey,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.algo.Sharding(context.Background(), tc.req)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res.Dsts)
+		})
+	}
+}
+
+func TestNewDateTime(t *testing.T) {
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	testCases := []struct {
+		name        string
+		shardingKey string
+		start       time.Time
+		end         time.Time
+		wantErr     error
+	}{
+		{
+			name:        "valid",
+			shardingKey: "CreateTime",
+			start:       date(2024, 11, 1),
+			end:         date(2025, 3, 1),
+		},
+		{
+			name:    "missing sharding key",
+			start:   date(2024, 11, 1),
+			end:     date(2025, 3, 1),
+			wantErr: errs.ErrMissingShardingKey,
+		},
+		{
+			name:        "zero window",
+			shardingKey: "CreateTime",
+			wantErr:     errs.NewErrInvalidShardingInterval(time.Time{}, time.Time{}),
+		},
+		{
+			name:        "start after end",
+			shardingKey: "CreateTime",
+			start:       date(2025, 3, 1),
+			end:         date(2024, 11, 1),
+			wantErr:     errs.NewErrInvalidShardingInterval(date(2025, 3, 1), date(2024, 11, 1)),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := NewDateTime(tc.shardingKey, Month, tc.start, tc.end)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, &DateTime{
+				ShardingKey: tc.shardingKey,
+				Granularity: Month,
+				Start:       tc.start,
+				End:         tc.end,
+			}, d)
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DstError 是某个目标表上执行失败的原因
+type DstError struct {
+	Dst Dst
+	Err error
+}
+
+func (e DstError) Error() string {
+	return fmt.Sprintf("eorm: %s.%s.%s 执行失败: %s", e.Dst.Name, e.Dst.DB, e.Dst.Table, e.Err)
+}
+
+func (e DstError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError 表示 best effort 模式下只有部分目标表执行成功，
+// Errs 是每一个失败的目标表以及失败的原因
+type PartialError struct {
+	Errs []DstError
+}
+
+func (e *PartialError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("eorm: %d 个目标表执行失败", len(e.Errs)))
+	for _, de := range e.Errs {
+		sb.WriteString("; ")
+		sb.WriteString(de.Error())
+	}
+	return sb.String()
+}
+
+func (e *PartialError) Unwrap() []error {
+	res := make([]error, 0, len(e.Errs))
+	for _, de := range e.Errs {
+		res = append(res, de)
+	}
+	return res
+}
@@ -0,0 +1,244 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// ConsistentHash 一致性哈希。
+// 每个 Dst 在哈希环上有 virtualNodes 个虚拟节点，
+// sharding key 的哈希值顺时针找到的第一个虚拟节点就是它的目标表。
+// 增加或者删除 Dst 的时候，只有相邻虚拟节点之间的数据需要迁移。
+// 哈希环在构造的时候就已经确定了，所以必须使用 NewConsistentHash 创建
+type ConsistentHash struct {
+	ShardingKey  string
+	hashFunc     Func
+	virtualNodes int
+	dsts         []sharding.Dst
+	ring         []virtualNode
+}
+
+type virtualNode struct {
+	hash uint64
+	dst  sharding.Dst
+}
+
+// NewConsistentHash 构造哈希环。
+// 同样的参数总是得到同样的哈希环，和 dsts 的顺序无关。
+// virtualNodes 小于等于 0 的时候，每个 Dst 只有一个节点；fn 为 nil 的时候使用 CRC32
+func NewConsistentHash(shardingKey string, virtualNodes int, fn Func, dsts ...sharding.Dst) *ConsistentHash {
+	if virtualNodes <= 0 {
+		virtualNodes = 1
+	}
+	if fn == nil {
+		fn = CRC32
+	}
+	c := &ConsistentHash{
+		ShardingKey:  shardingKey,
+		hashFunc:     fn,
+		virtualNodes: virtualNodes,
+		dsts:         make([]sharding.Dst, 0, len(dsts)),
+		ring:         make([]virtualNode, 0, len(dsts)*virtualNodes),
+	}
+	for _, dst := range dsts {
+		if c.contains(dst) {
+			continue
+		}
+		c.dsts = append(c.dsts, dst)
+		for i := 0; i < virtualNodes; i++ {
+			c.ring = append(c.ring, virtualNode{hash: fn(virtualNodeKey(dst, i)), dst: dst})
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool {
+		if c.ring[i].hash != c.ring[j].hash {
+			return c.ring[i].hash < c.ring[j].hash
+		}
+		// 哈希冲突的时候按照名字排序，保证结果和 dsts 的顺序无关
+		return sharding.CompareDSDBTab(c.ring[i].dst, c.ring[j].dst) < 0
+	})
+	sort.Slice(c.dsts, func(i, j int) bool {
+		return sharding.CompareDSDBTab(c.dsts[i], c.dsts[j]) < 0
+	})
+	return c
+}
+
+func virtualNodeKey(dst sharding.Dst, i int) []byte {
+	return []byte(dst.Name + "#" + dst.DB + "#" + dst.Table + "#" + strconv.Itoa(i))
+}
+
+func (c *ConsistentHash) contains(dst sharding.Dst) bool {
+	for _, d := range c.dsts {
+		if d.Equals(dst) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ConsistentHash) Broadcast(ctx context.Context) []sharding.Dst {
+	res := make([]sharding.Dst, len(c.dsts))
+	copy(res, c.dsts)
+	return res
+}
+
+func (c *ConsistentHash) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
+	if c.ShardingKey == "" {
+		return sharding.EmptyResp, errs.ErrMissingShardingKey
+	}
+	skVal, ok := req.SkValues[c.ShardingKey]
+	if !ok {
+		return sharding.Response{Dsts: c.Broadcast(ctx)}, nil
+	}
+	switch req.Op {
+	case operator.OpEQ:
+		h, err := c.HashOf(skVal)
+		if err != nil || len(c.ring) == 0 {
+			return sharding.EmptyResp, err
+		}
+		return sharding.Response{Dsts: []sharding.Dst{c.locate(h)}}, nil
+	case operator.OpGT, operator.OpLT, operator.OpGTEQ,
+		operator.OpLTEQ, operator.OpNEQ, operator.OpNotIN, operator.OpBetween:
+		return sharding.Response{Dsts: c.Broadcast(ctx)}, nil
+	default:
+		return sharding.EmptyResp, errs.NewUnsupportedOperatorError(req.Op.Text)
+	}
+}
+
+func (c *ConsistentHash) ShardingKeys() []string {
+	return []string{c.ShardingKey}
+}
+
+// HashOf 计算 sharding key 在哈希环上的位置。
+// 整数会被编码成 8 个字节之后再计算哈希值，保证在哈希环上均匀分布
+func (c *ConsistentHash) HashOf(val any) (uint64, error) {
+	fn := c.hashFunc
+	// 没有使用 NewConsistentHash 创建的时候哈希环是空的，这里只是避免 panic
+	if fn == nil {
+		fn = CRC32
+	}
+	v := reflect.ValueOf(val)
+	var data [8]byte
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		binary.BigEndian.PutUint64(data[:], uint64(v.Int()))
+		return fn(data[:]), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		binary.BigEndian.PutUint64(data[:], v.Uint())
+		return fn(data[:]), nil
+	default:
+		return ShardingValue(val, fn)
+	}
+}
+
+// locate 找到哈希值为 h 的数据所在的 Dst
+func (c *ConsistentHash) locate(h uint64) sharding.Dst {
+	idx := sort.Search(len(c.ring), func(i int) bool {
+		return c.ring[i].hash >= h
+	})
+	if idx == len(c.ring) {
+		idx = 0
+	}
+	return c.ring[idx].dst
+}
+
+// Add 返回增加了 dst 之后的哈希环，以及需要迁移的数据
+func (c *ConsistentHash) Add(dst sharding.Dst) (*ConsistentHash, []Movement) {
+	dsts := append(c.Broadcast(context.Background()), dst)
+	res := NewConsistentHash(c.ShardingKey, c.virtualNodes, c.hashFunc, dsts...)
+	return res, Diff(c, res)
+}
+
+// Remove 返回删除了 dst 之后的哈希环，以及需要迁移的数据
+func (c *ConsistentHash) Remove(dst sharding.Dst) (*ConsistentHash, []Movement) {
+	dsts := make([]sharding.Dst, 0, len(c.dsts))
+	for _, d := range c.dsts {
+		if d.NotEquals(dst) {
+			dsts = append(dsts, d)
+		}
+	}
+	res := NewConsistentHash(c.ShardingKey, c.virtualNodes, c.hashFunc, dsts...)
+	return res, Diff(c, res)
+}
+
+// Movement 表示哈希值落在 (Start, End] 之间的数据需要从 From 迁移到 To。
+// Start 大于等于 End 的时候，说明这个区间跨过了哈希环的起点
+type Movement struct {
+	Start uint64
+	End   uint64
+	From  sharding.Dst
+	To    sharding.Dst
+}
+
+// Contains 判断哈希值 h 是否落在这个区间里面，可以配合 ConsistentHash.HashOf 使用
+func (m Movement) Contains(h uint64) bool {
+	if m.Start < m.End {
+		return h > m.Start && h <= m.End
+	}
+	return h > m.Start || h <= m.End
+}
+
+// Diff 计算从哈希环 from 变成哈希环 to 的时候需要迁移的数据。
+// 两个哈希环必须使用同样的哈希函数
+func Diff(from, to *ConsistentHash) []Movement {
+	if len(from.ring) == 0 || len(to.ring) == 0 {
+		return nil
+	}
+	points := make([]uint64, 0, len(from.ring)+len(to.ring))
+	for _, n := range from.ring {
+		points = append(points, n.hash)
+	}
+	for _, n := range to.ring {
+		points = append(points, n.hash)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i] < points[j]
+	})
+	// 去重
+	uniq := points[:1]
+	for _, p := range points[1:] {
+		if p != uniq[len(uniq)-1] {
+			uniq = append(uniq, p)
+		}
+	}
+	// 相邻两个点之间的数据都落在同一个虚拟节点上，
+	// 第一个区间是从最后一个点跨过起点到第一个点
+	var res []Movement
+	for i, end := range uniq {
+		start := uniq[len(uniq)-1]
+		if i > 0 {
+			start = uniq[i-1]
+		}
+		src, dst := from.locate(end), to.locate(end)
+		if src.Equals(dst) {
+			continue
+		}
+		if n := len(res); n > 0 && res[n-1].End == start &&
+			res[n-1].From.Equals(src) && res[n-1].To.Equals(dst) {
+			res[n-1].End = end
+			continue
+		}
+		res = append(res, Movement{Start: start, End: end, From: src, To: dst})
+	}
+	return res
+}
@@ -0,0 +1,182 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newConsistentHashDsts(n int) []sharding.Dst {
+	res := make([]sharding.Dst, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, sharding.Dst{
+			Name:  "0.db.cluster.company.com:3306",
+			DB:    fmt.Sprintf("order_db_%d", i),
+			Table: "order_tab",
+		})
+	}
+	return res
+}
+
+func TestConsistentHash_Sharding(t *testing.T) {
+	dsts := newConsistentHashDsts(4)
+	c := NewConsistentHash("UserId", 16, nil, dsts...)
+	// 构造的结果和 Dst 的顺序无关
+	reversed := NewConsistentHash("UserId", 16, nil, dsts[3], dsts[2], dsts[1], dsts[0], dsts[0])
+	assert.Equal(t, c.ring, reversed.ring)
+	assert.Equal(t, dsts, reversed.Broadcast(context.Background()))
+
+	testCases := []struct {
+		name    string
+		req     sharding.Request
+		wantRes []sharding.Dst
+		wantErr error
+	}{
+		{
+			name:    "broadcast",
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": 12}},
+			wantRes: dsts,
+		},
+		{
+			name:    "range",
+			req:     sharding.Request{Op: operator.OpGT, SkValues: map[string]any{"UserId": 12}},
+			wantRes: dsts,
+		},
+		{
+			name:    "invalid value",
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"UserId": 1.2}},
+			wantErr: errs.NewErrUnsupportedShardingValue(1.2),
+		},
+		{
+			name:    "unsupported operator",
+			req:     sharding.Request{Op: operator.OpLike, SkValues: map[string]any{"UserId": 12}},
+			wantErr: errs.NewUnsupportedOperatorError(operator.OpLike.Text),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := c.Sharding(context.Background(), tc.req)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res.Dsts)
+		})
+	}
+
+	// 相等的时候只命中一个目标表，并且所有的目标表都会被用到
+	hit := make(map[sharding.Dst]struct{}, len(dsts))
+	for i := 0; i < 1000; i++ {
+		for _, val := range []any{i, int64(i), uint16(i), fmt.Sprintf("user_%d", i)} {
+			res, err := c.Sharding(context.Background(), sharding.Request{
+				Op: operator.OpEQ, SkValues: map[string]any{"UserId": val},
+			})
+			require.NoError(t, err)
+			require.Len(t, res.Dsts, 1)
+			hit[res.Dsts[0]] = struct{}{}
+		}
+	}
+	assert.Len(t, hit, len(dsts))
+}
+
+func TestConsistentHash_ZeroValue(t *testing.T) {
+	// 没有通过 NewConsistentHash 创建的哈希环是空的，不会命中任何目标表
+	c := &ConsistentHash{ShardingKey: "UserId"}
+	res, err := c.Sharding(context.Background(), sharding.Request{
+		Op: operator.OpEQ, SkValues: map[string]any{"UserId": 12},
+	})
+	require.NoError(t, err)
+	assert.Empty(t, res.Dsts)
+	assert.Empty(t, c.Broadcast(context.Background()))
+	h, err := c.HashOf(12)
+	require.NoError(t, err)
+	expected, err := NewConsistentHash("UserId", 1, nil).HashOf(12)
+	require.NoError(t, err)
+	assert.Equal(t, expected, h)
+}
+
+func TestConsistentHash_AddAndRemove(t *testing.T) {
+	dsts := newConsistentHashDsts(5)
+	c := NewConsistentHash("UserId", 32, Murmur3, dsts[:4]...)
+
+	added, moves := c.Add(dsts[4])
+	require.NotEmpty(t, moves)
+	for _, m := range moves {
+		// 增加节点的时候，只有数据迁移到新的节点上
+		assert.Equal(t, dsts[4], m.To)
+	}
+	assertMovements(t, c, added, moves)
+
+	removed, moves := added.Remove(dsts[1])
+	require.NotEmpty(t, moves)
+	for _, m := range moves {
+		// 删除节点的时候，只有被删除的节点上的数据需要迁移
+		assert.Equal(t, dsts[1], m.From)
+	}
+	assertMovements(t, added, removed, moves)
+
+	same, moves := removed.Add(dsts[0])
+	assert.Empty(t, moves)
+	assert.Equal(t, removed.ring, same.ring)
+}
+
+// assertMovements 检查所有的数据，目标表发生变化的数据必须落在某个 Movement 里面
+func assertMovements(t *testing.T, from, to *ConsistentHash, moves []Movement) {
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		h, err := from.HashOf(i)
+		require.NoError(t, err)
+		src, dst := from.locate(h), to.locate(h)
+		var hit []Movement
+		for _, m := range moves {
+			if m.Contains(h) {
+				hit = append(hit, m)
+			}
+		}
+		if src.Equals(dst) {
+			assert.Empty(t, hit)
+			continue
+		}
+		moved++
+		require.Len(t, hit, 1)
+		assert.Equal(t, src, hit[0].From)
+		assert.Equal(t, dst, hit[0].To)
+	}
+	// 只有一小部分数据需要迁移
+	assert.Greater(t, moved, 0)
+	assert.Less(t, moved, 5000)
+}
+
+func TestMovement_Contains(t *testing.T) {
+	m := Movement{Start: 10, End: 20}
+	assert.False(t, m.Contains(10))
+	assert.True(t, m.Contains(11))
+	assert.True(t, m.Contains(20))
+	assert.False(t, m.Contains(21))
+
+	wrap := Movement{Start: 20, End: 10}
+	assert.True(t, wrap.Contains(21))
+	assert.True(t, wrap.Contains(0))
+	assert.True(t, wrap.Contains(10))
+	assert.False(t, wrap.Contains(15))
+}
@@ -0,0 +1,115 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"hash/fnv"
+	"math/bits"
+	"reflect"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+)
+
+// Func 将字符串或者字节切片类型的 sharding key 转化为整数，再用于取模
+type Func func(data []byte) uint64
+
+var (
+	CRC32 Func = func(data []byte) uint64 {
+		return uint64(crc32.ChecksumIEEE(data))
+	}
+	FNV Func = func(data []byte) uint64 {
+		h := fnv.New64a()
+		_, _ = h.Write(data)
+		return h.Sum64()
+	}
+	Murmur3 Func = func(data []byte) uint64 {
+		return uint64(murmur3Sum32(data))
+	}
+)
+
+// ShardingValue 将 sharding key 的值转化为非负整数。
+// 整数直接使用它本身，负数取绝对值；字符串和字节切片使用 fn 计算哈希值
+func ShardingValue(val any, fn Func) (uint64, error) {
+	if fn == nil {
+		fn = CRC32
+	}
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		if i < 0 {
+			return uint64(-(i + 1)) + 1, nil
+		}
+		return uint64(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint(), nil
+	case reflect.String:
+		return fn([]byte(v.String())), nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return 0, errs.NewErrUnsupportedShardingValue(val)
+		}
+		// 兼容 [16]byte 之类的 UUID
+		data := make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(data), v)
+		return fn(data), nil
+	default:
+		return 0, errs.NewErrUnsupportedShardingValue(val)
+	}
+}
+
+// murmur3Sum32 是 seed 为 0 的 MurmurHash3 x86_32
+func murmur3Sum32(data []byte) uint32 {
+	const (
+		c1 uint32 = 0xcc9e2d51
+		c2 uint32 = 0x1b873593
+	)
+	var h uint32
+	n := len(data) / 4
+	for i := 0; i < n; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	tail := data[n*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -31,6 +31,9 @@ type Hash struct {
 	TablePattern *Pattern
 	// Datasource Pattern
 	DsPattern *Pattern
+	// HashFunc 用于字符串或者字节切片类型的 sharding key，默认是 CRC32。
+	// 整数类型的 sharding key 直接取模
+	HashFunc Func
 }
 
 func (h *Hash) Broadcast(ctx context.Context) []sharding.Dst {
@@ -125,23 +128,27 @@ func (h *Hash) Sharding(ctx context.Context, req sharding.Request) (sharding.Res
 	}
 	switch req.Op {
 	case operator.OpEQ:
+		val, err := ShardingValue(skVal, h.HashFunc)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
 		dbName := h.DBPattern.Name
 		if !h.DBPattern.NotSharding {
-			dbName = fmt.Sprintf(dbName, skVal.(int)%h.DBPattern.Base)
+			dbName = fmt.Sprintf(dbName, val%uint64(h.DBPattern.Base))
 		}
 		tbName := h.TablePattern.Name
 		if !h.TablePattern.NotSharding {
-			tbName = fmt.Sprintf(tbName, skVal.(int)%h.TablePattern.Base)
+			tbName = fmt.Sprintf(tbName, val%uint64(h.TablePattern.Base))
 		}
 		dsName := h.DsPattern.Name
 		if !h.DsPattern.NotSharding {
-			dsName = fmt.Sprintf(dsName, skVal.(int)%h.DsPattern.Base)
+			dsName = fmt.Sprintf(dsName, val%uint64(h.DsPattern.Base))
 		}
 		return sharding.Response{
 			Dsts: []sharding.Dst{{Name: dsName, DB: dbName, Table: tbName}},
 		}, nil
 	case operator.OpGT, operator.OpLT, operator.OpGTEQ,
-		operator.OpLTEQ, operator.OpNEQ, operator.OpNotIN:
+		operator.OpLTEQ, operator.OpNEQ, operator.OpNotIN, operator.OpBetween:
 		return sharding.Response{Dsts: h.Broadcast(ctx)}, nil
 	default:
 		return sharding.EmptyResp, errs.NewUnsupportedOperatorError(req.Op.Text)
@@ -0,0 +1,151 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hash
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash_Sharding(t *testing.T) {
+	newHash := func(fn Func) *Hash {
+		return &Hash{
+			ShardingKey:  "Id",
+			DBPattern:    &Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+			HashFunc:     fn,
+		}
+	}
+	dst := func(db, tbl uint64) sharding.Dst {
+		return sharding.Dst{
+			Name:  "0.db.cluster.company.com:3306",
+			DB:    fmt.Sprintf("order_db_%d", db),
+			Table: fmt.Sprintf("order_tab_%d", tbl),
+		}
+	}
+	crc := uint64(crc32.ChecksumIEEE([]byte("abc")))
+	uuid := [4]byte{1, 2, 3, 4}
+	testCases := []struct {
+		name    string
+		hash    *Hash
+		val     any
+		wantRes sharding.Dst
+		wantErr error
+	}{
+		{
+			name:    "int",
+			hash:    newHash(nil),
+			val:     123,
+			wantRes: dst(1, 0),
+		},
+		{
+			name:    "int64",
+			hash:    newHash(nil),
+			val:     int64(125),
+			wantRes: dst(1, 2),
+		},
+		{
+			name:    "negative int",
+			hash:    newHash(nil),
+			val:     int8(-5),
+			wantRes: dst(1, 2),
+		},
+		{
+			name:    "min int64",
+			hash:    newHash(nil),
+			val:     int64(math.MinInt64),
+			wantRes: dst(0, 2),
+		},
+		{
+			name:    "uint64",
+			hash:    newHash(nil),
+			val:     uint64(math.MaxUint64),
+			wantRes: dst(1, 0),
+		},
+		{
+			name:    "string crc32",
+			hash:    newHash(nil),
+			val:     "abc",
+			wantRes: dst(crc%2, crc%3),
+		},
+		{
+			name:    "bytes crc32",
+			hash:    newHash(CRC32),
+			val:     []byte("abc"),
+			wantRes: dst(crc%2, crc%3),
+		},
+		{
+			name:    "string fnv",
+			hash:    newHash(FNV),
+			val:     "abc",
+			wantRes: dst(FNV([]byte("abc"))%2, FNV([]byte("abc"))%3),
+		},
+		{
+			name:    "byte array murmur3",
+			hash:    newHash(Murmur3),
+			val:     uuid,
+			wantRes: dst(Murmur3(uuid[:])%2, Murmur3(uuid[:])%3),
+		},
+		{
+			name:    "float",
+			hash:    newHash(nil),
+			val:     12.3,
+			wantErr: errs.NewErrUnsupportedShardingValue(12.3),
+		},
+		{
+			name:    "int slice",
+			hash:    newHash(nil),
+			val:     []int{1},
+			wantErr: errs.NewErrUnsupportedShardingValue([]int{1}),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.hash.Sharding(context.Background(), sharding.Request{
+				Op: operator.OpEQ, SkValues: map[string]any{"Id": tc.val},
+			})
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, []sharding.Dst{tc.wantRes}, res.Dsts)
+		})
+	}
+}
+
+func TestMurmur3(t *testing.T) {
+	testCases := []struct {
+		data string
+		want uint32
+	}{
+		{data: "", want: 0},
+		{data: "hello", want: 0x248bfa47},
+		{data: "The quick brown fox jumps over the lazy dog", want: 0x2e4ff723},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.data, func(t *testing.T) {
+			assert.Equal(t, uint64(tc.want), Murmur3([]byte(tc.data)))
+		})
+	}
+}
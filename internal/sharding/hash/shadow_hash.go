@@ -16,96 +16,41 @@ package hash
 
 import (
 	"context"
-	"fmt"
-	"strings"
 
-	"github.com/ecodeclub/eorm/internal/errs"
 	"github.com/ecodeclub/eorm/internal/sharding"
 )
 
-// ShadowHash TODO experiemntal
+// ShadowHash 是支持压测流量的 Hash。
+// 压测流量的 Sharding 和 Broadcast 结果都会按照 ctx 上的标记加上 Prefix，
+// 效果和 sharding.ShadowAlgorithm 包装 Hash 一样
 type ShadowHash struct {
 	*Hash
 	Prefix string
 }
 
 func (h *ShadowHash) Broadcast(ctx context.Context) []sharding.Dst {
-	res := make([]sharding.Dst, 0, 8)
-	for i := 0; i < h.DBPattern.Base; i++ {
-		dbName := fmt.Sprintf(h.Prefix+h.DBPattern.Name, i)
-		for j := 0; j < h.TablePattern.Base; j++ {
-			res = append(res, sharding.Dst{
-				Name:  h.DsPattern.Name,
-				DB:    dbName,
-				Table: fmt.Sprintf(h.Prefix+h.TablePattern.Name, j),
-			})
-		}
-	}
-	return res
+	return h.shadow().Broadcast(ctx)
 }
 
 func (h *ShadowHash) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
-	if h.ShardingKey == "" {
-		return sharding.EmptyResp, errs.ErrMissingShardingKey
-	}
-	skVal, ok := req.SkValues[h.ShardingKey]
-	if !ok {
-		return sharding.Response{Dsts: h.Broadcast(ctx)}, nil
-	}
-	dbName := h.DBPattern.Name
-	if !h.DBPattern.NotSharding && strings.Contains(dbName, "%d") {
-		dbName = fmt.Sprintf(dbName, skVal.(int)%h.DBPattern.Base)
-	}
-	tbName := h.TablePattern.Name
-	if !h.TablePattern.NotSharding && strings.Contains(tbName, "%d") {
-		tbName = fmt.Sprintf(tbName, skVal.(int)%h.TablePattern.Base)
-	}
-	dsName := h.DsPattern.Name
-	if !h.DsPattern.NotSharding && strings.Contains(dsName, "%d") {
-		dsName = fmt.Sprintf(dsName, skVal.(int)%h.DsPattern.Base)
-	}
-	if isSourceKey(ctx) {
-		dsName = h.Prefix + dsName
-	}
-	if isDBKey(ctx) {
-		dbName = h.Prefix + dbName
-	}
-
-	if isTableKey(ctx) {
-		tbName = h.Prefix + tbName
-	}
-
-	return sharding.Response{
-		Dsts: []sharding.Dst{{Name: dsName, DB: dbName, Table: tbName}},
-	}, nil
+	return h.shadow().Sharding(ctx, req)
 }
 
-type sourceKey struct{}
-
-type dbKey struct{}
-
-type tableKey struct{}
+func (h *ShadowHash) shadow() *sharding.ShadowAlgorithm {
+	return &sharding.ShadowAlgorithm{Algorithm: h.Hash, Prefix: h.Prefix}
+}
 
+// CtxWithTableKey 标记为压测流量并且使用影子表，等价于 sharding.CtxWithShadow(ctx, sharding.ShadowTable)
 func CtxWithTableKey(ctx context.Context) context.Context {
-	return context.WithValue(ctx, tableKey{}, true)
+	return sharding.CtxWithShadow(ctx, sharding.ShadowTable)
 }
 
+// CtxWithDBKey 标记为压测流量并且使用影子库，等价于 sharding.CtxWithShadow(ctx, sharding.ShadowDB)
 func CtxWithDBKey(ctx context.Context) context.Context {
-	return context.WithValue(ctx, dbKey{}, true)
+	return sharding.CtxWithShadow(ctx, sharding.ShadowDB)
 }
 
+// CtxWithSourceKey 标记为压测流量并且使用影子数据源，等价于 sharding.CtxWithShadow(ctx, sharding.ShadowDatasource)
 func CtxWithSourceKey(ctx context.Context) context.Context {
-	return context.WithValue(ctx, sourceKey{}, true)
-}
-
-func isSourceKey(ctx context.Context) bool {
-	return ctx.Value(sourceKey{}) != nil
-}
-
-func isDBKey(ctx context.Context) bool {
-	return ctx.Value(dbKey{}) != nil
-}
-
-func isTableKey(ctx context.Context) bool {
-	return ctx.Value(tableKey{}) != nil
+	return sharding.CtxWithShadow(ctx, sharding.ShadowDatasource)
 }
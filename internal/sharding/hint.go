@@ -0,0 +1,31 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import "context"
+
+type dstKey struct{}
+
+// CtxWithDst 强制将查询路由到 dsts 上，不再根据查询条件计算目标表。
+// 一般用于运维脚本或者管理后台直接查询某个分片
+func CtxWithDst(ctx context.Context, dsts ...Dst) context.Context {
+	return context.WithValue(ctx, dstKey{}, dsts)
+}
+
+// DstFromCtx 返回 CtxWithDst 设置的目标表
+func DstFromCtx(ctx context.Context) ([]Dst, bool) {
+	dsts, ok := ctx.Value(dstKey{}).([]Dst)
+	return dsts, ok
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"context"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+)
+
+// MaxGeneBits 是基因的最大位数，序列号至少要保留 2 位
+const MaxGeneBits = sequenceBits - 2
+
+var _ Generator = &Gene{}
+
+// Gene 基因算法。
+// 在雪花算法的基础上，把 sharding key 的低 geneBits 位嵌入到主键的最低位，序列号相应地变短。
+// 使用 hash.Hash 分库分表并且 Base 是不超过 1<<geneBits 的 2 的幂时，
+// 主键和 sharding key 会落在同一个分片上，所以按照主键查询也能准确地找到目标表
+type Gene struct {
+	ShardingKey string
+	// HashFunc 用于计算字符串类型的 sharding key，需要和分库分表算法保持一致
+	HashFunc  hash.Func
+	geneBits  uint
+	snowflake *Snowflake
+}
+
+// NewGene 创建基因算法，geneBits 的取值范围是 [1, MaxGeneBits]
+func NewGene(shardingKey string, geneBits int, workerID int64) (*Gene, error) {
+	if geneBits < 1 || geneBits > MaxGeneBits {
+		return nil, errs.NewErrInvalidGeneBits(geneBits)
+	}
+	s, err := newSnowflake(workerID, uint(sequenceBits-geneBits))
+	if err != nil {
+		return nil, err
+	}
+	return &Gene{
+		ShardingKey: shardingKey,
+		geneBits:    uint(geneBits),
+		snowflake:   s,
+	}, nil
+}
+
+func (g *Gene) Generate(ctx context.Context, fields map[string]any) (int64, error) {
+	skVal, ok := fields[g.ShardingKey]
+	if !ok {
+		return 0, errs.ErrMissingShardingKey
+	}
+	val, err := hash.ShardingValue(skVal, g.HashFunc)
+	if err != nil {
+		return 0, err
+	}
+	ts, seq, err := g.snowflake.next()
+	if err != nil {
+		return 0, err
+	}
+	return g.snowflake.compose(ts, seq) | int64(val&(1<<g.geneBits-1)), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"context"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGene(t *testing.T) {
+	_, err := NewGene("UserId", 0, 1)
+	assert.Equal(t, errs.NewErrInvalidGeneBits(0), err)
+	_, err = NewGene("UserId", MaxGeneBits+1, 1)
+	assert.Equal(t, errs.NewErrInvalidGeneBits(MaxGeneBits+1), err)
+	_, err = NewGene("UserId", 4, MaxWorkerID+1)
+	assert.Equal(t, errs.NewErrInvalidWorkerID(MaxWorkerID+1), err)
+}
+
+func TestGene_Generate(t *testing.T) {
+	g, err := NewGene("UserId", 4, 3)
+	require.NoError(t, err)
+	g.snowflake.now = func() time.Time {
+		return Epoch.Add(time.Second)
+	}
+	testCases := []struct {
+		name    string
+		fields  map[string]any
+		wantID  int64
+		wantErr error
+	}{
+		{
+			name:   "int",
+			fields: map[string]any{"UserId": 123},
+			wantID: int64(1000)<<22 | 3<<12 | 123&0xf,
+		},
+		{
+			name:   "string",
+			fields: map[string]any{"UserId": "abc"},
+			wantID: int64(1000)<<22 | 3<<12 | 1<<4 | int64(crc32.ChecksumIEEE([]byte("abc"))&0xf),
+		},
+		{
+			name:    "missing sharding key",
+			fields:  map[string]any{"OrderId": 123},
+			wantErr: errs.ErrMissingShardingKey,
+		},
+		{
+			name:    "unsupported value",
+			fields:  map[string]any{"UserId": 1.2},
+			wantErr: errs.NewErrUnsupportedShardingValue(1.2),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			id, err := g.Generate(context.Background(), tc.fields)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantID, id)
+		})
+	}
+}
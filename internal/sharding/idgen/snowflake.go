@@ -0,0 +1,111 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+)
+
+// Generator 生成分库分表之后全局唯一的主键
+type Generator interface {
+	// Generate 生成一个主键。
+	// fields 是这一行数据的字段名到字段值的映射，基因算法会用到其中的 sharding key
+	Generate(ctx context.Context, fields map[string]any) (int64, error)
+}
+
+const (
+	workerIDBits = 10
+	sequenceBits = 12
+	// MaxWorkerID 是机器 ID 的最大值
+	MaxWorkerID = 1<<workerIDBits - 1
+)
+
+// Epoch 是雪花算法的起始时间，41 位的时间戳可以使用大约 69 年
+var Epoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var _ Generator = &Snowflake{}
+
+// Snowflake 雪花算法。
+// 主键从高位到低位依次是 1 位符号位、41 位毫秒时间戳、10 位机器 ID 和 12 位序列号，
+// 同一台机器上生成的主键是递增的
+type Snowflake struct {
+	workerID int64
+	// seqBits 是序列号的位数，基因算法会占用序列号的低位
+	seqBits uint
+	epoch   int64
+	now     func() time.Time
+
+	mu     sync.Mutex
+	lastTs int64
+	seq    int64
+}
+
+// NewSnowflake 创建雪花算法，同一个集群里面每个实例的 workerID 必须不同
+func NewSnowflake(workerID int64) (*Snowflake, error) {
+	return newSnowflake(workerID, sequenceBits)
+}
+
+func newSnowflake(workerID int64, seqBits uint) (*Snowflake, error) {
+	if workerID < 0 || workerID > MaxWorkerID {
+		return nil, errs.NewErrInvalidWorkerID(workerID)
+	}
+	return &Snowflake{
+		workerID: workerID,
+		seqBits:  seqBits,
+		epoch:    Epoch.UnixMilli(),
+		now:      time.Now,
+		lastTs:   -1,
+	}, nil
+}
+
+func (s *Snowflake) Generate(ctx context.Context, fields map[string]any) (int64, error) {
+	ts, seq, err := s.next()
+	if err != nil {
+		return 0, err
+	}
+	return s.compose(ts, seq), nil
+}
+
+// compose 拼接时间戳、机器 ID 和序列号
+func (s *Snowflake) compose(ts, seq int64) int64 {
+	return ts<<(workerIDBits+sequenceBits) | s.workerID<<sequenceBits | seq<<(sequenceBits-s.seqBits)
+}
+
+// next 返回当前的时间戳和序列号。
+// 同一毫秒内的序列号用完之后，会等到下一毫秒；时钟回拨的时候返回错误
+func (s *Snowflake) next() (int64, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts := s.now().UnixMilli() - s.epoch
+	if ts < s.lastTs {
+		return 0, 0, errs.NewErrClockMovedBackwards(time.Duration(s.lastTs-ts) * time.Millisecond)
+	}
+	if ts == s.lastTs {
+		s.seq = (s.seq + 1) & (1<<s.seqBits - 1)
+		if s.seq == 0 {
+			for ts <= s.lastTs {
+				ts = s.now().UnixMilli() - s.epoch
+			}
+		}
+	} else {
+		s.seq = 0
+	}
+	s.lastTs = ts
+	return ts, s.seq, nil
+}
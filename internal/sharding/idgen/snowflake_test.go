@@ -0,0 +1,103 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idgen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSnowflake(t *testing.T) {
+	_, err := NewSnowflake(-1)
+	assert.Equal(t, errs.NewErrInvalidWorkerID(-1), err)
+	_, err = NewSnowflake(MaxWorkerID + 1)
+	assert.Equal(t, errs.NewErrInvalidWorkerID(MaxWorkerID+1), err)
+	_, err = NewSnowflake(MaxWorkerID)
+	assert.NoError(t, err)
+}
+
+func TestSnowflake_Generate(t *testing.T) {
+	s, err := NewSnowflake(3)
+	require.NoError(t, err)
+	ts := Epoch.Add(time.Second)
+	s.now = func() time.Time {
+		return ts
+	}
+	id, err := s.Generate(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000)<<22|3<<12, id)
+	id, err = s.Generate(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000)<<22|3<<12|1, id)
+
+	// 时钟回拨
+	ts = ts.Add(-time.Millisecond)
+	_, err = s.Generate(context.Background(), nil)
+	assert.Equal(t, errs.NewErrClockMovedBackwards(time.Millisecond), err)
+}
+
+func TestSnowflake_SequenceOverflow(t *testing.T) {
+	s, err := NewSnowflake(0)
+	require.NoError(t, err)
+	calls := 0
+	s.now = func() time.Time {
+		calls++
+		// 序列号用完之后，时间才会前进
+		if calls <= 1<<sequenceBits+2 {
+			return Epoch
+		}
+		return Epoch.Add(time.Millisecond)
+	}
+	var last int64 = -1
+	for i := 0; i < 1<<sequenceBits+1; i++ {
+		id, err := s.Generate(context.Background(), nil)
+		require.NoError(t, err)
+		require.Greater(t, id, last)
+		last = id
+	}
+	assert.Equal(t, int64(1)<<22, last)
+}
+
+func TestSnowflake_Concurrent(t *testing.T) {
+	s, err := NewSnowflake(1)
+	require.NoError(t, err)
+	const n = 10000
+	ids := make(chan int64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < n/10; j++ {
+				id, err := s.Generate(context.Background(), nil)
+				assert.NoError(t, err)
+				ids <- id
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[int64]struct{}, n)
+	for id := range ids {
+		seen[id] = struct{}{}
+	}
+	assert.Len(t, seen, n)
+}
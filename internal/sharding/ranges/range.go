@@ -0,0 +1,223 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ranges
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"sort"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// Interval 表示 sharding key 落在 [Start, End) 之间的数据都存储在 Dst 上
+type Interval struct {
+	Start int64
+	End   int64
+	Dst   sharding.Dst
+}
+
+// Range 按照 sharding key 的区间分库分表。
+// 适合 sharding key 随着时间递增的场景，例如自增主键、雪花算法生成的 ID，
+// 这种时候范围查询只需要查询少数几个分片
+type Range struct {
+	ShardingKey string
+	// Intervals 之间不能重叠，但是可以有空隙。
+	// 落在空隙里面的数据找不到任何目标表
+	Intervals []Interval
+}
+
+// NewRange 会将区间按照起点排序，并且检查区间是否合法
+func NewRange(shardingKey string, intervals ...Interval) (*Range, error) {
+	if shardingKey == "" {
+		return nil, errs.ErrMissingShardingKey
+	}
+	res := make([]Interval, len(intervals))
+	copy(res, intervals)
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start < res[j].Start
+	})
+	for i, itv := range res {
+		if itv.Start >= itv.End || i > 0 && res[i-1].End > itv.Start {
+			return nil, errs.NewErrInvalidShardingInterval(itv.Start, itv.End)
+		}
+	}
+	return &Range{
+		ShardingKey: shardingKey,
+		Intervals:   res,
+	}, nil
+}
+
+func (r *Range) Broadcast(ctx context.Context) []sharding.Dst {
+	return r.findDsts(math.MinInt64, math.MaxInt64)
+}
+
+func (r *Range) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
+	if r.ShardingKey == "" {
+		return sharding.EmptyResp, errs.ErrMissingShardingKey
+	}
+	skVal, ok := req.SkValues[r.ShardingKey]
+	if !ok {
+		return sharding.Response{Dsts: r.Broadcast(ctx)}, nil
+	}
+	switch req.Op {
+	case operator.OpNEQ, operator.OpNotIN:
+		return sharding.Response{Dsts: r.Broadcast(ctx)}, nil
+	case operator.OpIn:
+		return r.shardingIn(skVal)
+	case operator.OpBetween:
+		return r.shardingRange(ctx, skVal)
+	case operator.OpEQ, operator.OpGT, operator.OpLT, operator.OpGTEQ, operator.OpLTEQ:
+		val, err := toInt64(skVal)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		lo, hi := bounds(req.Op, val)
+		if lo > hi {
+			return sharding.EmptyResp, nil
+		}
+		return sharding.Response{Dsts: r.findDsts(lo, hi)}, nil
+	default:
+		return sharding.EmptyResp, errs.NewUnsupportedOperatorError(req.Op.Text)
+	}
+}
+
+// shardingIn 的 skVal 是一个切片，结果是每个值对应的目标表的并集
+func (r *Range) shardingIn(skVal any) (sharding.Response, error) {
+	vals := reflect.ValueOf(skVal)
+	if vals.Kind() != reflect.Slice && vals.Kind() != reflect.Array {
+		return sharding.EmptyResp, errs.NewErrUnsupportedShardingValue(skVal)
+	}
+	var dsts []sharding.Dst
+	for i := 0; i < vals.Len(); i++ {
+		val, err := toInt64(vals.Index(i).Interface())
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		dsts = appendDsts(dsts, r.findDsts(val, val)...)
+	}
+	return sharding.Response{Dsts: dsts}, nil
+}
+
+// shardingRange 的 skVal 是 sharding.Range。
+// 字符串的范围来自于前缀 LIKE，它和整数的大小关系无关，所以只能广播
+func (r *Range) shardingRange(ctx context.Context, skVal any) (sharding.Response, error) {
+	rg, ok := skVal.(sharding.Range)
+	if !ok {
+		return sharding.EmptyResp, errs.NewErrUnsupportedShardingValue(skVal)
+	}
+	if isString(rg.Start) || isString(rg.End) {
+		return sharding.Response{Dsts: r.Broadcast(ctx)}, nil
+	}
+	lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+	var err error
+	if rg.Start != nil {
+		if lo, err = toInt64(rg.Start); err != nil {
+			return sharding.EmptyResp, err
+		}
+	}
+	if rg.End != nil {
+		if hi, err = toInt64(rg.End); err != nil {
+			return sharding.EmptyResp, err
+		}
+		if rg.ExclusiveEnd {
+			if hi == math.MinInt64 {
+				return sharding.EmptyResp, nil
+			}
+			hi--
+		}
+	}
+	if lo > hi {
+		return sharding.EmptyResp, nil
+	}
+	return sharding.Response{Dsts: r.findDsts(lo, hi)}, nil
+}
+
+// findDsts 找到和闭区间 [lo, hi] 有交集的所有目标表
+func (r *Range) findDsts(lo, hi int64) []sharding.Dst {
+	var res []sharding.Dst
+	for _, itv := range r.Intervals {
+		if itv.Start <= hi && itv.End > lo {
+			res = appendDsts(res, itv.Dst)
+		}
+	}
+	return res
+}
+
+func (r *Range) ShardingKeys() []string {
+	return []string{r.ShardingKey}
+}
+
+// bounds 将比较运算转化为闭区间 [lo, hi]
+// 如果 lo > hi，说明没有任何数据满足条件
+func bounds(op operator.Op, val int64) (int64, int64) {
+	switch op {
+	case operator.OpGT:
+		if val == math.MaxInt64 {
+			return 1, 0
+		}
+		return val + 1, math.MaxInt64
+	case operator.OpGTEQ:
+		return val, math.MaxInt64
+	case operator.OpLT:
+		if val == math.MinInt64 {
+			return 1, 0
+		}
+		return math.MinInt64, val - 1
+	case operator.OpLTEQ:
+		return math.MinInt64, val
+	default:
+		return val, val
+	}
+}
+
+// appendDsts 追加目标表，同时去除重复的目标表
+func appendDsts(dsts []sharding.Dst, vals ...sharding.Dst) []sharding.Dst {
+	for _, val := range vals {
+		exist := false
+		for _, dst := range dsts {
+			if dst.Equals(val) {
+				exist = true
+				break
+			}
+		}
+		if !exist {
+			dsts = append(dsts, val)
+		}
+	}
+	return dsts
+}
+
+func isString(val any) bool {
+	return val != nil && reflect.TypeOf(val).Kind() == reflect.String
+}
+
+func toInt64(val any) (int64, error) {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, errs.NewErrUnsupportedShardingValue(val)
+		}
+		return int64(v.Uint()), nil
+	default:
+		return 0, errs.NewErrUnsupportedShardingValue(val)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ranges
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRange(t *testing.T) {
+	testCases := []struct {
+		name      string
+		key       string
+		intervals []Interval
+		wantRes   []Interval
+		wantErr   error
+	}{
+		{
+			name:    "missing sharding key",
+			wantErr: errs.ErrMissingShardingKey,
+		},
+		{
+			name: "empty interval",
+			key:  "Id",
+			intervals: []Interval{
+				{Start: 10, End: 10},
+			},
+			wantErr: errs.NewErrInvalidShardingInterval(int64(10), int64(10)),
+		},
+		{
+			name: "overlapping",
+			key:  "Id",
+			intervals: []Interval{
+				{Start: 0, End: 100},
+				{Start: 50, End: 200},
+			},
+			wantErr: errs.NewErrInvalidShardingInterval(int64(50), int64(200)),
+		},
+		{
+			name: "sorted",
+			key:  "Id",
+			intervals: []Interval{
+				{Start: 100, End: 200, Dst: sharding.Dst{Table: "t_1"}},
+				{Start: 0, End: 100, Dst: sharding.Dst{Table: "t_0"}},
+			},
+			wantRes: []Interval{
+				{Start: 0, End: 100, Dst: sharding.Dst{Table: "t_0"}},
+				{Start: 100, End: 200, Dst: sharding.Dst{Table: "t_1"}},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := NewRange(tc.key, tc.intervals...)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, r.Intervals)
+		})
+	}
+}
+
+func TestRange_Sharding(t *testing.T) {
+	dst0 := sharding.Dst{Name: "ds", DB: "order_db_0", Table: "order_tab_0"}
+	dst1 := sharding.Dst{Name: "ds", DB: "order_db_0", Table: "order_tab_1"}
+	dst2 := sharding.Dst{Name: "ds", DB: "order_db_1", Table: "order_tab_0"}
+	r, err := NewRange("OrderId",
+		Interval{Start: math.MinInt64, End: 1000, Dst: dst0},
+		Interval{Start: 1000, End: 2000, Dst: dst1},
+		// 3000 之后的数据还没有规划分片
+		Interval{Start: 2000, End: 3000, Dst: dst2},
+	)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		req     sharding.Request
+		wantRes []sharding.Dst
+		wantErr error
+	}{
+		{
+			name:    "missing sharding key",
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"UserId": 12}},
+			wantRes: []sharding.Dst{dst0, dst1, dst2},
+		},
+		{
+			name:    "eq",
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": 1000}},
+			wantRes: []sharding.Dst{dst1},
+		},
+		{
+			name: "eq in gap",
+			req:  sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": int64(5000)}},
+		},
+		{
+			name:    "gt",
+			req:     sharding.Request{Op: operator.OpGT, SkValues: map[string]any{"OrderId": 1999}},
+			wantRes: []sharding.Dst{dst2},
+		},
+		{
+			name:    "gteq",
+			req:     sharding.Request{Op: operator.OpGTEQ, SkValues: map[string]any{"OrderId": uint32(1999)}},
+			wantRes: []sharding.Dst{dst1, dst2},
+		},
+		{
+			name:    "lt",
+			req:     sharding.Request{Op: operator.OpLT, SkValues: map[string]any{"OrderId": 1000}},
+			wantRes: []sharding.Dst{dst0},
+		},
+		{
+			name:    "lteq",
+			req:     sharding.Request{Op: operator.OpLTEQ, SkValues: map[string]any{"OrderId": int8(-1)}},
+			wantRes: []sharding.Dst{dst0},
+		},
+		{
+			name: "gt max",
+			req:  sharding.Request{Op: operator.OpGT, SkValues: map[string]any{"OrderId": int64(math.MaxInt64)}},
+		},
+		{
+			name:    "in",
+			req:     sharding.Request{Op: operator.OpIn, SkValues: map[string]any{"OrderId": []any{12, 2500, 30}}},
+			wantRes: []sharding.Dst{dst0, dst2},
+		},
+		{
+			name:    "in not slice",
+			req:     sharding.Request{Op: operator.OpIn, SkValues: map[string]any{"OrderId": 12}},
+			wantErr: errs.NewErrUnsupportedShardingValue(12),
+		},
+		{
+			name:    "between",
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"OrderId": sharding.Range{Start: 999, End: 1999}}},
+			wantRes: []sharding.Dst{dst0, dst1},
+		},
+		{
+			name:    "between exclusive end",
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"OrderId": sharding.Range{Start: 1500, End: 2000, ExclusiveEnd: true}}},
+			wantRes: []sharding.Dst{dst1},
+		},
+		{
+			name:    "between without start",
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"OrderId": sharding.Range{End: int64(999)}}},
+			wantRes: []sharding.Dst{dst0},
+		},
+		{
+			name: "between empty",
+			req:  sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"OrderId": sharding.Range{Start: 1999, End: 1000}}},
+		},
+		{
+			name:    "between string",
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"OrderId": sharding.Range{Start: "12", End: "13", ExclusiveEnd: true}}},
+			wantRes: []sharding.Dst{dst0, dst1, dst2},
+		},
+		{
+			name:    "between not range",
+			req:     sharding.Request{Op: operator.OpBetween, SkValues: map[string]any{"OrderId": 12}},
+			wantErr: errs.NewErrUnsupportedShardingValue(12),
+		},
+		{
+			name:    "neq",
+			req:     sharding.Request{Op: operator.OpNEQ, SkValues: map[string]any{"OrderId": 12}},
+			wantRes: []sharding.Dst{dst0, dst1, dst2},
+		},
+		{
+			name:    "invalid value",
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": "12"}},
+			wantErr: errs.NewErrUnsupportedShardingValue("12"),
+		},
+		{
+			name:    "uint overflow",
+			req:     sharding.Request{Op: operator.OpEQ, SkValues: map[string]any{"OrderId": uint64(math.MaxUint64)}},
+			wantErr: errs.NewErrUnsupportedShardingValue(uint64(math.MaxUint64)),
+		},
+		{
+			name:    "unsupported operator",
+			req:     sharding.Request{Op: operator.OpLike, SkValues: map[string]any{"OrderId": 12}},
+			wantErr: errs.NewUnsupportedOperatorError(operator.OpLike.Text),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := r.Sharding(context.Background(), tc.req)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res.Dsts)
+		})
+	}
+}
@@ -14,38 +14,83 @@
 
 package sharding
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+)
 
+// Result 是在多个目标表上执行的结果。
+// best effort 模式下 Err 返回 *PartialError，
+// 这个时候 LastInsertId 和 RowsAffected 只统计执行成功的目标表，同时也会返回这个错误
 type Result struct {
-	err error
-	res []sql.Result
+	err   error
+	res   []sql.Result
+	moves []Move
+}
+
+// Move 是更新 sharding key 的时候，一行数据从 From 迁移到了 To
+type Move struct {
+	From Dst
+	To   Dst
+	// Row 是迁移之后的数据
+	Row any
 }
 
 func (r Result) Err() error {
 	return r.err
 }
 
+// Moves 返回更新 sharding key 的时候迁移了目标表的数据
+func (r Result) Moves() []Move {
+	return r.moves
+}
+
 func (r Result) LastInsertId() (int64, error) {
-	if r.err != nil {
+	if r.err != nil && !r.partial() {
 		return 0, r.err
 	}
-	return r.res[len(r.res)-1].LastInsertId()
+	for i := len(r.res) - 1; i >= 0; i-- {
+		if r.res[i] == nil {
+			continue
+		}
+		id, err := r.res[i].LastInsertId()
+		if err != nil {
+			return 0, err
+		}
+		return id, r.err
+	}
+	return 0, r.err
 }
+
 func (r Result) RowsAffected() (int64, error) {
-	if r.err != nil {
+	if r.err != nil && !r.partial() {
 		return 0, r.err
 	}
 	var sum int64
 	for _, i := range r.res {
+		if i == nil {
+			continue
+		}
 		n, err := i.RowsAffected()
 		if err != nil {
 			return 0, err
 		}
 		sum += n
 	}
-	return sum, nil
+	return sum, r.err
+}
+
+// partial 表示只有部分目标表执行失败
+func (r Result) partial() bool {
+	var pe *PartialError
+	return errors.As(r.err, &pe)
 }
 
 func NewResult(res []sql.Result, err error) Result {
 	return Result{res: res, err: err}
 }
+
+// NewMoveResult 创建更新 sharding key 的结果，res 是插入迁移之后的数据的结果
+func NewMoveResult(res []sql.Result, moves []Move, err error) Result {
+	return Result{res: res, moves: moves, err: err}
+}
@@ -0,0 +1,132 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharding
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultShadowPrefix 是影子数据源、影子库和影子表的默认前缀
+const DefaultShadowPrefix = "shadow_"
+
+// ShadowTarget 表示压测流量需要改写成影子的部分，可以组合使用
+type ShadowTarget uint8
+
+const (
+	// ShadowTable 使用影子表
+	ShadowTable ShadowTarget = 1 << iota
+	// ShadowDB 使用影子库
+	ShadowDB
+	// ShadowDatasource 使用影子数据源
+	ShadowDatasource
+)
+
+// Has 判断是否包含 target
+func (t ShadowTarget) Has(target ShadowTarget) bool {
+	return t&target == target
+}
+
+type shadowKey struct{}
+
+// CtxWithShadow 把 ctx 标记为压测流量，压测流量只会访问影子数据源、影子库或者影子表。
+// targets 为空的时候只使用影子表；ctx 上已经有的标记会保留下来。
+// 一般在接收请求的入口，例如 HTTP 或者 RPC 的拦截器里面识别压测标记之后调用
+func CtxWithShadow(ctx context.Context, targets ...ShadowTarget) context.Context {
+	target, _ := ShadowFromCtx(ctx)
+	if len(targets) == 0 {
+		target |= ShadowTable
+	}
+	for _, t := range targets {
+		target |= t
+	}
+	return context.WithValue(ctx, shadowKey{}, target)
+}
+
+// ShadowFromCtx 返回 CtxWithShadow 设置的标记，第二个返回值表示是否是压测流量
+func ShadowFromCtx(ctx context.Context) (ShadowTarget, bool) {
+	target, ok := ctx.Value(shadowKey{}).(ShadowTarget)
+	return target, ok
+}
+
+// ShadowDst 按照 ctx 上的压测标记给 dst 加上前缀，不是压测流量的时候原样返回
+func ShadowDst(ctx context.Context, prefix string, dst Dst) Dst {
+	target, ok := ShadowFromCtx(ctx)
+	if !ok {
+		return dst
+	}
+	if target.Has(ShadowDatasource) {
+		dst.Name = prefix + dst.Name
+	}
+	if target.Has(ShadowDB) {
+		dst.DB = prefix + dst.DB
+	}
+	if target.Has(ShadowTable) {
+		dst.Table = prefix + dst.Table
+	}
+	return dst
+}
+
+// IsShadowDst 判断 dst 是不是影子，也就是数据源、库和表里面至少有一个带有前缀
+func IsShadowDst(prefix string, dst Dst) bool {
+	return strings.HasPrefix(dst.Name, prefix) ||
+		strings.HasPrefix(dst.DB, prefix) ||
+		strings.HasPrefix(dst.Table, prefix)
+}
+
+var _ Algorithm = &ShadowAlgorithm{}
+
+// ShadowAlgorithm 让任意的分库分表算法支持压测流量。
+// 压测流量的 Sharding 和 Broadcast 结果都会按照 ctx 上的标记加上 Prefix，
+// 正常流量的结果和 Algorithm 一样
+type ShadowAlgorithm struct {
+	Algorithm
+	// Prefix 为空的时候使用 DefaultShadowPrefix
+	Prefix string
+}
+
+func (s *ShadowAlgorithm) Sharding(ctx context.Context, req Request) (Response, error) {
+	resp, err := s.Algorithm.Sharding(ctx, req)
+	if err != nil {
+		return EmptyResp, err
+	}
+	if _, ok := ShadowFromCtx(ctx); !ok {
+		return resp, nil
+	}
+	dsts := make([]Dst, 0, len(resp.Dsts))
+	for _, dst := range resp.Dsts {
+		dsts = append(dsts, ShadowDst(ctx, s.prefix(), dst))
+	}
+	return Response{Dsts: dsts}, nil
+}
+
+func (s *ShadowAlgorithm) Broadcast(ctx context.Context) []Dst {
+	dsts := s.Algorithm.Broadcast(ctx)
+	if _, ok := ShadowFromCtx(ctx); !ok {
+		return dsts
+	}
+	res := make([]Dst, 0, len(dsts))
+	for _, dst := range dsts {
+		res = append(res, ShadowDst(ctx, s.prefix(), dst))
+	}
+	return res
+}
+
+func (s *ShadowAlgorithm) prefix() string {
+	if s.Prefix == "" {
+		return DefaultShadowPrefix
+	}
+	return s.Prefix
+}
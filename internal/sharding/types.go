@@ -62,11 +62,22 @@ func (r Dst) NotEquals(l Dst) bool {
 	return r.Name != l.Name || r.DB != l.DB || r.Table != l.Table
 }
 
+// Request 是查找目标表的请求。
+// Op 是 operator.OpBetween 的时候，SkValues 里面的值是 Range
 type Request struct {
 	Op       operator.Op
 	SkValues map[string]any
 }
 
+// Range 是范围查询中 sharding key 的取值范围，默认是闭区间 [Start, End]。
+// Start 或者 End 为 nil 表示没有下界或者上界
+type Range struct {
+	Start any
+	End   any
+	// ExclusiveEnd 为 true 的时候是左闭右开区间 [Start, End)
+	ExclusiveEnd bool
+}
+
 type Response struct {
 	Dsts []Dst
 }
@@ -0,0 +1,168 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlparser
+
+import (
+	operator "github.com/ecodeclub/eorm/internal/operator"
+)
+
+// StmtType 是语句的类型
+type StmtType int
+
+const (
+	Select StmtType = iota + 1
+	Insert
+	Update
+	Delete
+)
+
+// Statement 是解析之后的单表语句
+type Statement struct {
+	Type StmtType
+	// Table 是语句操作的逻辑表
+	Table TableName
+	Alias string
+	// Where 是 WHERE 子句，没有 WHERE 的时候是 nil
+	Where Expr
+
+	// 下面的字段只有 SELECT 语句才有
+	Distinct bool
+	// Aggregate 表示查询的列里面有聚合函数
+	Aggregate bool
+	GroupBy   bool
+	Having    bool
+	OrderBy   []OrderByItem
+	// ComplexOrderBy 表示 ORDER BY 里面有列以外的表达式
+	ComplexOrderBy bool
+	// Limit 是 LIMIT 子句，没有 LIMIT 的时候是 nil
+	Limit *Limit
+
+	// Columns 是 INSERT 语句的列
+	Columns []string
+	// Rows 是 INSERT 语句的每一行数据
+	Rows []Row
+	// Assignments 是 UPDATE 语句 SET 的列
+	Assignments []string
+
+	sql string
+	// params 是每一个占位符在 SQL 里面的位置
+	params []int
+	// tableSpans 是需要替换成物理表的位置，包括 FROM 里面的表和 `表名.列名` 里面的表名
+	tableSpans []tableSpan
+	// valuesSpan 是 INSERT 语句全部行的位置
+	valuesSpan span
+}
+
+// ParamCount 返回占位符的数量
+func (s *Statement) ParamCount() int {
+	return len(s.params)
+}
+
+type TableName struct {
+	Schema string
+	Name   string
+}
+
+// OrderByItem 是 ORDER BY 里面的一列
+type OrderByItem struct {
+	Column string
+	Desc   bool
+}
+
+// Limit 是 LIMIT 子句
+type Limit struct {
+	Offset Value
+	Count  Value
+	span   span
+}
+
+// Row 是 INSERT 语句里面的一行数据，和 Columns 一一对应
+type Row struct {
+	Values []Expr
+	span   span
+}
+
+type span struct {
+	pos int
+	end int
+}
+
+func (s span) contains(pos int) bool {
+	return pos >= s.pos && pos < s.end
+}
+
+type tableSpan struct {
+	span
+	// full 表示这个位置需要替换成 db.table，否则只替换成 table
+	full bool
+}
+
+// Expr 是 WHERE 子句里面的表达式
+type Expr interface {
+	expr()
+}
+
+type AndExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+type OrExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+type NotExpr struct {
+	Expr Expr
+}
+
+// CompareExpr 是列和值的比较，值在左边的时候会被调整到右边
+type CompareExpr struct {
+	Column string
+	Op     operator.Op
+	Value  Value
+}
+
+// InExpr 是 IN 或者 NOT IN
+type InExpr struct {
+	Column string
+	Not    bool
+	Values []Value
+}
+
+// LikeExpr 是列 LIKE 值，NOT LIKE 和带有 ESCAPE 的 LIKE 是 UnknownExpr
+type LikeExpr struct {
+	Column  string
+	Pattern Value
+}
+
+// UnknownExpr 是无法用于查找目标表的表达式，例如函数调用、BETWEEN 或者子查询
+type UnknownExpr struct{}
+
+// Value 是 SQL 里面的字面量或者占位符
+type Value struct {
+	// Param 是占位符的下标，字面量的时候是 -1
+	Param int
+	Val   any
+}
+
+func (AndExpr) expr()     {}
+func (OrExpr) expr()      {}
+func (NotExpr) expr()     {}
+func (CompareExpr) expr() {}
+func (InExpr) expr()      {}
+func (LikeExpr) expr()    {}
+func (UnknownExpr) expr() {}
+func (Value) expr()       {}
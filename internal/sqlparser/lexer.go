@@ -0,0 +1,209 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlparser
+
+import (
+	"strings"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+)
+
+type tokenType int
+
+const (
+	tokEOF tokenType = iota
+	// tokIdent 标识符和关键字，用反引号括起来的标识符 quoted 为 true
+	tokIdent
+	tokNumber
+	tokString
+	// tokParam 占位符 ?
+	tokParam
+	// tokOp 运算符，包括比较运算符和算术运算符
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+	tokDot
+	tokSemicolon
+)
+
+type token struct {
+	typ    tokenType
+	val    string
+	quoted bool
+	// pos 和 end 是 token 在 SQL 里面的起止位置
+	pos int
+	end int
+}
+
+// isKeyword 判断 token 是不是 kws 中的某个关键字，不区分大小写
+func (t token) isKeyword(kws ...string) bool {
+	if t.typ != tokIdent || t.quoted {
+		return false
+	}
+	for _, kw := range kws {
+		if strings.EqualFold(t.val, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+// lex 把 SQL 切分成 token，注释会被忽略
+func lex(sql string) ([]token, error) {
+	res := make([]token, 0, 32)
+	i := 0
+	for i < len(sql) {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '#' || (c == '-' && strings.HasPrefix(sql[i:], "-- ")):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return nil, errs.NewErrRawSQLSyntax(i, sql[i:])
+			}
+			i += end + 4
+		case c == '`':
+			end := i + 1
+			var sb strings.Builder
+			for {
+				if end >= len(sql) {
+					return nil, errs.NewErrRawSQLSyntax(i, sql[i:])
+				}
+				if sql[end] == '`' {
+					// 两个反引号表示反引号本身
+					if end+1 < len(sql) && sql[end+1] == '`' {
+						sb.WriteByte('`')
+						end += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(sql[end])
+				end++
+			}
+			res = append(res, token{typ: tokIdent, val: sb.String(), quoted: true, pos: i, end: end + 1})
+			i = end + 1
+		case c == '\'' || c == '"':
+			end := i + 1
+			var sb strings.Builder
+			for {
+				if end >= len(sql) {
+					return nil, errs.NewErrRawSQLSyntax(i, sql[i:])
+				}
+				if sql[end] == '\\' && end+1 < len(sql) {
+					sb.WriteByte(unescape(sql[end+1]))
+					end += 2
+					continue
+				}
+				if sql[end] == c {
+					if end+1 < len(sql) && sql[end+1] == c {
+						sb.WriteByte(c)
+						end += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(sql[end])
+				end++
+			}
+			res = append(res, token{typ: tokString, val: sb.String(), pos: i, end: end + 1})
+			i = end + 1
+		case isDigit(c) || (c == '.' && i+1 < len(sql) && isDigit(sql[i+1])):
+			end := i
+			for end < len(sql) && (isDigit(sql[end]) || sql[end] == '.') {
+				end++
+			}
+			res = append(res, token{typ: tokNumber, val: sql[i:end], pos: i, end: end})
+			i = end
+		case isIdentChar(c):
+			end := i
+			for end < len(sql) && isIdentChar(sql[end]) {
+				end++
+			}
+			res = append(res, token{typ: tokIdent, val: sql[i:end], pos: i, end: end})
+			i = end
+		case c == '?':
+			res = append(res, token{typ: tokParam, val: "?", pos: i, end: i + 1})
+			i++
+		case c == '(':
+			res = append(res, token{typ: tokLParen, val: "(", pos: i, end: i + 1})
+			i++
+		case c == ')':
+			res = append(res, token{typ: tokRParen, val: ")", pos: i, end: i + 1})
+			i++
+		case c == ',':
+			res = append(res, token{typ: tokComma, val: ",", pos: i, end: i + 1})
+			i++
+		case c == '.':
+			res = append(res, token{typ: tokDot, val: ".", pos: i, end: i + 1})
+			i++
+		case c == ';':
+			res = append(res, token{typ: tokSemicolon, val: ";", pos: i, end: i + 1})
+			i++
+		default:
+			op := matchOp(sql[i:])
+			if op == "" {
+				return nil, errs.NewErrRawSQLSyntax(i, sql[i:])
+			}
+			res = append(res, token{typ: tokOp, val: op, pos: i, end: i + len(op)})
+			i += len(op)
+		}
+	}
+	res = append(res, token{typ: tokEOF, pos: len(sql), end: len(sql)})
+	return res, nil
+}
+
+// ops 按照长度从长到短排列，保证优先匹配最长的运算符
+var ops = []string{"<=>", "<=", ">=", "<>", "!=", "<<", ">>", "||", "&&", ":=",
+	"=", "<", ">", "+", "-", "*", "/", "%", "&", "|", "^", "~", "!"}
+
+func matchOp(s string) string {
+	for _, op := range ops {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func unescape(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	case '0':
+		return 0
+	default:
+		return c
+	}
+}
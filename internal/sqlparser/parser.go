@@ -0,0 +1,1021 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlparser
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+)
+
+// Parse 解析 MySQL 的单表 SELECT、INSERT、UPDATE 和 DELETE 语句。
+// 只解析查找目标表和归并结果需要的部分，其余部分原样保留。
+// 不支持 JOIN、子查询和 UNION
+func Parse(sql string) (*Statement, error) {
+	toks, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, stmt: &Statement{sql: sql}}
+	selectCnt := 0
+	for _, t := range toks {
+		switch {
+		case t.typ == tokParam:
+			p.stmt.params = append(p.stmt.params, t.pos)
+		case t.isKeyword("SELECT"):
+			selectCnt++
+		case t.isKeyword("UNION"):
+			return nil, errs.NewErrUnsupportedRawSQL("UNION")
+		}
+	}
+	if selectCnt > 1 || (selectCnt == 1 && !toks[0].isKeyword("SELECT")) {
+		return nil, errs.NewErrUnsupportedRawSQL("子查询")
+	}
+	if err = p.parse(); err != nil {
+		return nil, err
+	}
+	p.findQualifiers()
+	return p.stmt, nil
+}
+
+type operandKind int
+
+const (
+	operandOther operandKind = iota
+	operandColumn
+	operandValue
+)
+
+// operand 是比较运算的一边
+type operand struct {
+	kind operandKind
+	col  string
+	val  Value
+}
+
+type parser struct {
+	toks []token
+	i    int
+	stmt *Statement
+	// tableFrom 和 tableTo 是逻辑表的 token 下标范围
+	tableFrom int
+	tableTo   int
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) peekN(n int) token {
+	if p.i+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.i+n]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.typ != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) unexpected() error {
+	t := p.peek()
+	return errs.NewErrRawSQLSyntax(t.pos, p.stmt.sql[t.pos:])
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.peek().isKeyword(kw) {
+		return p.unexpected()
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) parse() error {
+	var err error
+	t := p.peek()
+	switch {
+	case t.isKeyword("SELECT"):
+		err = p.parseSelect()
+	case t.isKeyword("INSERT"):
+		err = p.parseInsert()
+	case t.isKeyword("UPDATE"):
+		err = p.parseUpdate()
+	case t.isKeyword("DELETE"):
+		err = p.parseDelete()
+	default:
+		return errs.NewErrUnsupportedRawSQL(t.val)
+	}
+	if err != nil {
+		return err
+	}
+	if p.peek().typ == tokSemicolon {
+		p.next()
+	}
+	if p.peek().typ != tokEOF {
+		return p.unexpected()
+	}
+	return nil
+}
+
+var aggregateFuncs = []string{"COUNT", "SUM", "AVG", "MIN", "MAX",
+	"GROUP_CONCAT", "STD", "STDDEV", "VARIANCE", "BIT_AND", "BIT_OR", "BIT_XOR"}
+
+func (p *parser) parseSelect() error {
+	p.stmt.Type = Select
+	p.next()
+	for p.peek().isKeyword("ALL", "DISTINCT", "DISTINCTROW", "HIGH_PRIORITY",
+		"SQL_NO_CACHE", "SQL_CALC_FOUND_ROWS", "SQL_SMALL_RESULT", "SQL_BIG_RESULT") {
+		if p.peek().isKeyword("DISTINCT", "DISTINCTROW") {
+			p.stmt.Distinct = true
+		}
+		p.next()
+	}
+	// 查询的列原样保留，只需要知道有没有聚合函数
+	depth := 0
+	for depth > 0 || !p.peek().isKeyword("FROM") {
+		t := p.peek()
+		switch {
+		case t.typ == tokEOF:
+			return p.unexpected()
+		case t.typ == tokLParen:
+			depth++
+		case t.typ == tokRParen:
+			depth--
+		case t.isKeyword(aggregateFuncs...) && p.peekN(1).typ == tokLParen:
+			p.stmt.Aggregate = true
+		}
+		p.next()
+	}
+	p.next()
+	if err := p.parseTableRef(true); err != nil {
+		return err
+	}
+	if err := p.parseWhere(); err != nil {
+		return err
+	}
+	if p.peek().isKeyword("GROUP") {
+		p.next()
+		if err := p.expectKeyword("BY"); err != nil {
+			return err
+		}
+		p.stmt.GroupBy = true
+		if err := p.skipList("HAVING", "ORDER", "LIMIT", "FOR", "LOCK"); err != nil {
+			return err
+		}
+	}
+	if p.peek().isKeyword("HAVING") {
+		p.next()
+		p.stmt.Having = true
+		if err := p.skipList("ORDER", "LIMIT", "FOR", "LOCK"); err != nil {
+			return err
+		}
+	}
+	if err := p.parseOrderBy(); err != nil {
+		return err
+	}
+	if err := p.parseLimit(); err != nil {
+		return err
+	}
+	// FOR UPDATE 之类的锁定子句原样保留
+	return p.skipUntil()
+}
+
+func (p *parser) parseInsert() error {
+	p.stmt.Type = Insert
+	p.next()
+	for p.peek().isKeyword("LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY", "IGNORE") {
+		p.next()
+	}
+	if p.peek().isKeyword("INTO") {
+		p.next()
+	}
+	if err := p.parseTableRef(false); err != nil {
+		return err
+	}
+	if p.peek().typ != tokLParen {
+		return errs.NewErrUnsupportedRawSQL("没有指定列的 INSERT")
+	}
+	p.next()
+	for {
+		t := p.next()
+		if t.typ != tokIdent {
+			p.i--
+			return p.unexpected()
+		}
+		p.stmt.Columns = append(p.stmt.Columns, t.val)
+		if p.peek().typ == tokComma {
+			p.next()
+			continue
+		}
+		if p.peek().typ != tokRParen {
+			return p.unexpected()
+		}
+		p.next()
+		break
+	}
+	if !p.peek().isKeyword("VALUES", "VALUE") {
+		return p.unexpected()
+	}
+	p.next()
+	for {
+		if p.peek().typ != tokLParen {
+			return p.unexpected()
+		}
+		row := Row{span: span{pos: p.next().pos}}
+		for {
+			o, err := p.parseOperand()
+			if err != nil {
+				return err
+			}
+			if o.kind == operandValue {
+				row.Values = append(row.Values, o.val)
+			} else {
+				row.Values = append(row.Values, UnknownExpr{})
+			}
+			if p.peek().typ == tokComma {
+				p.next()
+				continue
+			}
+			if p.peek().typ != tokRParen {
+				return p.unexpected()
+			}
+			row.span.end = p.next().end
+			break
+		}
+		if len(row.Values) != len(p.stmt.Columns) {
+			return errs.NewErrRawSQLSyntax(row.span.pos, p.stmt.sql[row.span.pos:])
+		}
+		p.stmt.Rows = append(p.stmt.Rows, row)
+		if p.peek().typ != tokComma {
+			break
+		}
+		p.next()
+	}
+	p.stmt.valuesSpan = span{pos: p.stmt.Rows[0].span.pos, end: p.stmt.Rows[len(p.stmt.Rows)-1].span.end}
+	// ON DUPLICATE KEY UPDATE 原样保留
+	return p.skipUntil()
+}
+
+func (p *parser) parseUpdate() error {
+	p.stmt.Type = Update
+	p.next()
+	for p.peek().isKeyword("LOW_PRIORITY", "IGNORE") {
+		p.next()
+	}
+	if err := p.parseTableRef(true); err != nil {
+		return err
+	}
+	if err := p.expectKeyword("SET"); err != nil {
+		return err
+	}
+	for {
+		o, err := p.parsePrimary()
+		if err != nil {
+			return err
+		}
+		if o.kind != operandColumn {
+			return p.unexpected()
+		}
+		if t := p.peek(); t.typ != tokOp || t.val != "=" {
+			return p.unexpected()
+		}
+		p.next()
+		p.stmt.Assignments = append(p.stmt.Assignments, o.col)
+		if err = p.skipUntil("WHERE", "ORDER", "LIMIT"); err != nil {
+			return err
+		}
+		if p.peek().typ != tokComma {
+			break
+		}
+		p.next()
+	}
+	return p.parseTail()
+}
+
+func (p *parser) parseDelete() error {
+	p.stmt.Type = Delete
+	p.next()
+	for p.peek().isKeyword("LOW_PRIORITY", "QUICK", "IGNORE") {
+		p.next()
+	}
+	if err := p.expectKeyword("FROM"); err != nil {
+		return err
+	}
+	if err := p.parseTableRef(true); err != nil {
+		return err
+	}
+	if p.peek().isKeyword("USING") {
+		return errs.NewErrUnsupportedRawSQL("多表 DELETE")
+	}
+	return p.parseTail()
+}
+
+// parseTail 解析 UPDATE 和 DELETE 的 WHERE、ORDER BY 和 LIMIT
+func (p *parser) parseTail() error {
+	if err := p.parseWhere(); err != nil {
+		return err
+	}
+	if err := p.parseOrderBy(); err != nil {
+		return err
+	}
+	return p.parseLimit()
+}
+
+// reserved 是不能作为别名的关键字
+var reserved = []string{"WHERE", "GROUP", "HAVING", "ORDER", "LIMIT", "FOR", "LOCK",
+	"JOIN", "INNER", "LEFT", "RIGHT", "CROSS", "NATURAL", "STRAIGHT_JOIN", "SET", "VALUES",
+	"VALUE", "ON", "USING", "UNION", "FORCE", "USE", "IGNORE", "PARTITION", "SELECT", "FROM",
+	"AS", "WINDOW", "AND", "OR", "NOT", "IN", "IS", "LIKE", "BETWEEN", "ASC", "DESC", "OFFSET"}
+
+func (p *parser) parseTableRef(allowAlias bool) error {
+	t := p.peek()
+	if t.typ == tokLParen {
+		return errs.NewErrUnsupportedRawSQL("子查询")
+	}
+	if t.typ != tokIdent || t.isKeyword(reserved...) {
+		return p.unexpected()
+	}
+	p.tableFrom = p.i
+	p.next()
+	ts := tableSpan{span: span{pos: t.pos, end: t.end}, full: true}
+	name := t.val
+	if p.peek().typ == tokDot {
+		p.next()
+		nt := p.next()
+		if nt.typ != tokIdent {
+			p.i--
+			return p.unexpected()
+		}
+		p.stmt.Table.Schema = name
+		name = nt.val
+		ts.end = nt.end
+	}
+	p.tableTo = p.i
+	p.stmt.Table.Name = name
+	p.stmt.tableSpans = append(p.stmt.tableSpans, ts)
+	if allowAlias {
+		if p.peek().isKeyword("AS") {
+			p.next()
+			if p.peek().typ != tokIdent {
+				return p.unexpected()
+			}
+			p.stmt.Alias = p.next().val
+		} else if at := p.peek(); at.typ == tokIdent && !at.isKeyword(reserved...) {
+			p.stmt.Alias = p.next().val
+		}
+	}
+	// 索引提示原样保留
+	for p.peek().isKeyword("USE", "FORCE", "IGNORE") {
+		for p.peek().typ != tokLParen {
+			if p.peek().typ == tokEOF {
+				return p.unexpected()
+			}
+			p.next()
+		}
+		if err := p.skipParens(); err != nil {
+			return err
+		}
+	}
+	if p.peek().typ == tokComma || p.peek().isKeyword("JOIN", "INNER", "LEFT", "RIGHT",
+		"CROSS", "NATURAL", "STRAIGHT_JOIN") {
+		return errs.NewErrUnsupportedRawSQL("JOIN")
+	}
+	return nil
+}
+
+func (p *parser) parseWhere() error {
+	if !p.peek().isKeyword("WHERE") {
+		return nil
+	}
+	p.next()
+	e, err := p.parseOr()
+	if err != nil {
+		return err
+	}
+	p.stmt.Where = e
+	return nil
+}
+
+func (p *parser) parseOrderBy() error {
+	if !p.peek().isKeyword("ORDER") {
+		return nil
+	}
+	p.next()
+	if err := p.expectKeyword("BY"); err != nil {
+		return err
+	}
+	for {
+		o, err := p.parseOperand()
+		if err != nil {
+			return err
+		}
+		desc := false
+		if p.peek().isKeyword("ASC") {
+			p.next()
+		} else if p.peek().isKeyword("DESC") {
+			p.next()
+			desc = true
+		}
+		if o.kind == operandColumn {
+			p.stmt.OrderBy = append(p.stmt.OrderBy, OrderByItem{Column: o.col, Desc: desc})
+		} else {
+			p.stmt.ComplexOrderBy = true
+		}
+		if p.peek().typ != tokComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parseLimit() error {
+	if !p.peek().isKeyword("LIMIT") {
+		return nil
+	}
+	start := p.next().pos
+	first, err := p.parseLimitValue()
+	if err != nil {
+		return err
+	}
+	limit := &Limit{Offset: Value{Param: -1, Val: int64(0)}, Count: first}
+	if p.peek().typ == tokComma {
+		p.next()
+		limit.Offset = first
+		if limit.Count, err = p.parseLimitValue(); err != nil {
+			return err
+		}
+	} else if p.peek().isKeyword("OFFSET") {
+		p.next()
+		if limit.Offset, err = p.parseLimitValue(); err != nil {
+			return err
+		}
+	}
+	limit.span = span{pos: start, end: p.toks[p.i-1].end}
+	p.stmt.Limit = limit
+	return nil
+}
+
+func (p *parser) parseLimitValue() (Value, error) {
+	t := p.peek()
+	switch t.typ {
+	case tokParam:
+		p.next()
+		return Value{Param: p.paramIndex(t)}, nil
+	case tokNumber:
+		n, err := strconv.ParseInt(t.val, 10, 64)
+		if err != nil {
+			return Value{}, p.unexpected()
+		}
+		p.next()
+		return Value{Param: -1, Val: n}, nil
+	default:
+		return Value{}, p.unexpected()
+	}
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		isOr := t.isKeyword("OR") || (t.typ == tokOp && t.val == "||")
+		if !isOr && !t.isKeyword("XOR") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if isOr {
+			left = OrExpr{Left: left, Right: right}
+		} else {
+			left = UnknownExpr{}
+		}
+	}
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.isKeyword("AND") && (t.typ != tokOp || t.val != "&&") {
+			return left, nil
+		}
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = AndExpr{Left: left, Right: right}
+	}
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	t := p.peek()
+	if t.isKeyword("NOT") || (t.typ == tokOp && t.val == "!") {
+		p.next()
+		e, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return NotExpr{Expr: e}, nil
+	}
+	return p.parsePredicate()
+}
+
+func (p *parser) parsePredicate() (Expr, error) {
+	if p.peek().typ == tokLParen {
+		// 可能是括号括起来的条件，也可能是 (a + b) 这样的运算数
+		save := p.i
+		p.next()
+		e, err := p.parseOr()
+		if err == nil && p.peek().typ == tokRParen {
+			p.next()
+			if !p.isPredicateTail() {
+				return e, nil
+			}
+		}
+		p.i = save
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	switch {
+	case t.typ == tokOp && isCompareOp(t.val):
+		p.next()
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return compare(left, t.val, right), nil
+	case t.isKeyword("IN"):
+		return p.parseIn(left, false)
+	case t.isKeyword("NOT") && p.peekN(1).isKeyword("IN"):
+		p.next()
+		return p.parseIn(left, true)
+	case t.isKeyword("NOT") && p.peekN(1).isKeyword("LIKE", "REGEXP", "RLIKE", "BETWEEN"):
+		p.next()
+		return p.parseUnknownTail()
+	case t.isKeyword("LIKE"):
+		return p.parseLike(left)
+	case t.isKeyword("REGEXP", "RLIKE", "BETWEEN", "IS"):
+		return p.parseUnknownTail()
+	default:
+		// 单独的一个运算数，例如 WHERE deleted
+		return UnknownExpr{}, nil
+	}
+}
+
+// parseLike 解析 LIKE，指定了 ESCAPE 的时候无法确定前缀，所以是 UnknownExpr
+func (p *parser) parseLike(left operand) (Expr, error) {
+	p.next()
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().isKeyword("ESCAPE") {
+		p.next()
+		if _, err = p.parseOperand(); err != nil {
+			return nil, err
+		}
+		return UnknownExpr{}, nil
+	}
+	if left.kind != operandColumn || right.kind != operandValue {
+		return UnknownExpr{}, nil
+	}
+	return LikeExpr{Column: left.col, Pattern: right.val}, nil
+}
+
+// parseUnknownTail 解析不能用于查找目标表的 NOT LIKE、BETWEEN 和 IS
+func (p *parser) parseUnknownTail() (Expr, error) {
+	t := p.next()
+	switch {
+	case t.isKeyword("IS"):
+		if p.peek().isKeyword("NOT") {
+			p.next()
+		}
+		if !p.peek().isKeyword("NULL", "TRUE", "FALSE", "UNKNOWN") {
+			return nil, p.unexpected()
+		}
+		p.next()
+	case t.isKeyword("BETWEEN"):
+		if _, err := p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if err := p.expectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		if _, err := p.parseOperand(); err != nil {
+			return nil, err
+		}
+	default:
+		if _, err := p.parseOperand(); err != nil {
+			return nil, err
+		}
+		if p.peek().isKeyword("ESCAPE") {
+			p.next()
+			if _, err := p.parseOperand(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return UnknownExpr{}, nil
+}
+
+func (p *parser) parseIn(left operand, not bool) (Expr, error) {
+	p.next()
+	if p.peek().typ != tokLParen {
+		return nil, p.unexpected()
+	}
+	p.next()
+	known := left.kind == operandColumn
+	var vals []Value
+	for {
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		if o.kind == operandValue {
+			vals = append(vals, o.val)
+		} else {
+			known = false
+		}
+		if p.peek().typ == tokComma {
+			p.next()
+			continue
+		}
+		if p.peek().typ != tokRParen {
+			return nil, p.unexpected()
+		}
+		p.next()
+		break
+	}
+	if !known {
+		return UnknownExpr{}, nil
+	}
+	return InExpr{Column: left.col, Not: not, Values: vals}, nil
+}
+
+// isPredicateTail 判断括号后面是不是还有运算符，也就是括号里面是不是一个运算数
+func (p *parser) isPredicateTail() bool {
+	t := p.peek()
+	if t.typ == tokOp {
+		return t.val != "||" && t.val != "&&"
+	}
+	return t.isKeyword("IN", "NOT", "LIKE", "REGEXP", "RLIKE", "BETWEEN", "IS", "DIV", "MOD")
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	res, err := p.parsePrimary()
+	if err != nil {
+		return operand{}, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.typ == tokOp && isArithmeticOp(t.val), t.isKeyword("DIV", "MOD"):
+			p.next()
+			if _, err = p.parsePrimary(); err != nil {
+				return operand{}, err
+			}
+			res = operand{}
+		case t.isKeyword("COLLATE"):
+			p.next()
+			p.next()
+			res = operand{}
+		default:
+			return res, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (operand, error) {
+	t := p.peek()
+	switch t.typ {
+	case tokParam:
+		p.next()
+		return operand{kind: operandValue, val: Value{Param: p.paramIndex(t)}}, nil
+	case tokNumber:
+		p.next()
+		if n, err := strconv.ParseInt(t.val, 10, 64); err == nil {
+			return operand{kind: operandValue, val: Value{Param: -1, Val: n}}, nil
+		}
+		f, err := strconv.ParseFloat(t.val, 64)
+		if err != nil {
+			return operand{}, errs.NewErrRawSQLSyntax(t.pos, p.stmt.sql[t.pos:])
+		}
+		return operand{kind: operandValue, val: Value{Param: -1, Val: f}}, nil
+	case tokString:
+		p.next()
+		return operand{kind: operandValue, val: Value{Param: -1, Val: t.val}}, nil
+	case tokOp:
+		if t.val != "-" && t.val != "+" && t.val != "~" && t.val != "!" {
+			return operand{}, p.unexpected()
+		}
+		p.next()
+		o, err := p.parsePrimary()
+		if err != nil {
+			return operand{}, err
+		}
+		if t.val == "-" && o.kind == operandValue && o.val.Param < 0 {
+			switch v := o.val.Val.(type) {
+			case int64:
+				return operand{kind: operandValue, val: Value{Param: -1, Val: -v}}, nil
+			case float64:
+				return operand{kind: operandValue, val: Value{Param: -1, Val: -v}}, nil
+			}
+		}
+		return operand{}, nil
+	case tokLParen:
+		return operand{}, p.skipParens()
+	case tokIdent:
+		return p.parseIdentOperand()
+	default:
+		return operand{}, p.unexpected()
+	}
+}
+
+func (p *parser) parseIdentOperand() (operand, error) {
+	t := p.next()
+	if !t.quoted {
+		switch {
+		case t.isKeyword("NULL", "TRUE", "FALSE", "DEFAULT"):
+			return operand{}, nil
+		case t.isKeyword("CASE"):
+			return operand{}, p.skipCase()
+		case t.isKeyword("INTERVAL"):
+			if _, err := p.parseOperand(); err != nil {
+				return operand{}, err
+			}
+			p.next()
+			return operand{}, nil
+		case t.isKeyword("BINARY"):
+			_, err := p.parsePrimary()
+			return operand{}, err
+		case t.isKeyword(reserved...):
+			p.i--
+			return operand{}, p.unexpected()
+		}
+	}
+	// 函数调用
+	if p.peek().typ == tokLParen {
+		return operand{}, p.skipParens()
+	}
+	// 列名，前面可能有表名和库名
+	name := t.val
+	for p.peek().typ == tokDot {
+		p.next()
+		nt := p.next()
+		if nt.typ != tokIdent && (nt.typ != tokOp || nt.val != "*") {
+			p.i--
+			return operand{}, p.unexpected()
+		}
+		name = nt.val
+	}
+	return operand{kind: operandColumn, col: name}, nil
+}
+
+// skipCase 跳过 CASE ... END，CASE 已经被读取了
+func (p *parser) skipCase() error {
+	depth := 1
+	for depth > 0 {
+		t := p.next()
+		switch {
+		case t.typ == tokEOF:
+			return p.unexpected()
+		case t.isKeyword("CASE"):
+			depth++
+		case t.isKeyword("END"):
+			depth--
+		}
+	}
+	return nil
+}
+
+// skipParens 跳过一对括号以及括号里面的全部内容
+func (p *parser) skipParens() error {
+	depth := 0
+	for {
+		t := p.next()
+		switch t.typ {
+		case tokEOF:
+			return p.unexpected()
+		case tokLParen:
+			depth++
+		case tokRParen:
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// skipUntil 跳过 token，直到遇到 kws 中的关键字、逗号、分号或者结尾
+func (p *parser) skipUntil(kws ...string) error {
+	for {
+		t := p.peek()
+		switch {
+		case t.typ == tokEOF, t.typ == tokSemicolon, t.typ == tokComma && len(kws) > 0,
+			t.isKeyword(kws...):
+			return nil
+		case t.typ == tokLParen:
+			if err := p.skipParens(); err != nil {
+				return err
+			}
+		case t.typ == tokRParen:
+			return p.unexpected()
+		default:
+			p.next()
+		}
+	}
+}
+
+// skipList 和 skipUntil 一样，但是不会停在逗号上
+func (p *parser) skipList(kws ...string) error {
+	for {
+		if err := p.skipUntil(kws...); err != nil {
+			return err
+		}
+		if p.peek().typ != tokComma {
+			return nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) paramIndex(t token) int {
+	return sort.SearchInts(p.stmt.params, t.pos)
+}
+
+// findQualifiers 找到 `表名.列名` 里面的表名，改写的时候也要替换成物理表
+func (p *parser) findQualifiers() {
+	for k := 0; k+1 < len(p.toks); k++ {
+		if k >= p.tableFrom && k < p.tableTo {
+			continue
+		}
+		t := p.toks[k]
+		if t.typ != tokIdent || t.val != p.stmt.Table.Name || p.toks[k+1].typ != tokDot {
+			continue
+		}
+		ts := tableSpan{span: span{pos: t.pos, end: t.end}}
+		if k >= 2 && p.toks[k-1].typ == tokDot && p.toks[k-2].typ == tokIdent {
+			ts.pos = p.toks[k-2].pos
+			ts.full = true
+		}
+		p.stmt.tableSpans = append(p.stmt.tableSpans, ts)
+	}
+}
+
+func isCompareOp(op string) bool {
+	switch op {
+	case "=", "!=", "<>", "<", "<=", ">", ">=", "<=>":
+		return true
+	default:
+		return false
+	}
+}
+
+func isArithmeticOp(op string) bool {
+	switch op {
+	case "+", "-", "*", "/", "%", "&", "|", "^", "<<", ">>":
+		return true
+	default:
+		return false
+	}
+}
+
+// compare 构造比较表达式，值在左边的时候把列换到左边
+func compare(left operand, op string, right operand) Expr {
+	var o operator.Op
+	switch op {
+	case "=", "<=>":
+		o = operator.OpEQ
+	case "!=", "<>":
+		o = operator.OpNEQ
+	case "<":
+		o = operator.OpLT
+	case "<=":
+		o = operator.OpLTEQ
+	case ">":
+		o = operator.OpGT
+	case ">=":
+		o = operator.OpGTEQ
+	}
+	if left.kind == operandValue && right.kind == operandColumn {
+		left, right = right, left
+		switch o {
+		case operator.OpLT:
+			o = operator.OpGT
+		case operator.OpLTEQ:
+			o = operator.OpGTEQ
+		case operator.OpGT:
+			o = operator.OpLT
+		case operator.OpGTEQ:
+			o = operator.OpLTEQ
+		}
+	}
+	if left.kind != operandColumn || right.kind != operandValue {
+		return UnknownExpr{}
+	}
+	return CompareExpr{Column: left.col, Op: o, Value: right.val}
+}
+
+// Rewrite 把逻辑表替换成物理表 db.table，返回改写之后的 SQL 以及用到的占位符的下标。
+// rows 不为 nil 的时候，INSERT 语句只保留下标在 rows 里面的行；
+// limit 大于 0 的时候，LIMIT 子句被改写成 LIMIT limit，原本 LIMIT 里面的占位符会被去掉
+func (s *Statement) Rewrite(db, table string, rows []int, limit int) (string, []int) {
+	type edit struct {
+		span
+		text string
+	}
+	edits := make([]edit, 0, len(s.tableSpans)+2)
+	var dropped []span
+	for _, ts := range s.tableSpans {
+		// 保留下来的行原样复制
+		if rows != nil && s.valuesSpan.contains(ts.pos) {
+			continue
+		}
+		text := quote(table)
+		if ts.full {
+			text = quote(db) + "." + text
+		}
+		edits = append(edits, edit{span: ts.span, text: text})
+	}
+	if rows != nil {
+		kept := make(map[int]struct{}, len(rows))
+		for _, r := range rows {
+			kept[r] = struct{}{}
+		}
+		texts := make([]string, 0, len(rows))
+		for i, r := range s.Rows {
+			if _, ok := kept[i]; ok {
+				texts = append(texts, s.sql[r.span.pos:r.span.end])
+			} else {
+				dropped = append(dropped, r.span)
+			}
+		}
+		edits = append(edits, edit{span: s.valuesSpan, text: strings.Join(texts, ",")})
+	}
+	if limit > 0 && s.Limit != nil {
+		edits = append(edits, edit{span: s.Limit.span, text: "LIMIT " + strconv.Itoa(limit)})
+		dropped = append(dropped, s.Limit.span)
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].pos < edits[j].pos
+	})
+	var sb strings.Builder
+	last := 0
+	for _, e := range edits {
+		sb.WriteString(s.sql[last:e.pos])
+		sb.WriteString(e.text)
+		last = e.end
+	}
+	sb.WriteString(s.sql[last:])
+
+	params := make([]int, 0, len(s.params))
+	for i, pos := range s.params {
+		isDropped := false
+		for _, d := range dropped {
+			if d.contains(pos) {
+				isDropped = true
+				break
+			}
+		}
+		if !isDropped {
+			params = append(params, i)
+		}
+	}
+	return sb.String(), params
+}
+
+func quote(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
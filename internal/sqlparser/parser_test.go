@@ -0,0 +1,257 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	param := func(i int) Value {
+		return Value{Param: i}
+	}
+	literal := func(val any) Value {
+		return Value{Param: -1, Val: val}
+	}
+	testCases := []struct {
+		name     string
+		sql      string
+		wantStmt *Statement
+		wantErr  error
+	}{
+		{
+			name: "select",
+			sql:  "SELECT `id`, `name` FROM `order` WHERE `user_id` = ? AND (`status` IN (1, 2) OR 10 < amount);",
+			wantStmt: &Statement{
+				Type:  Select,
+				Table: TableName{Name: "order"},
+				Where: AndExpr{
+					Left: CompareExpr{Column: "user_id", Op: operator.OpEQ, Value: param(0)},
+					Right: OrExpr{
+						Left:  InExpr{Column: "status", Values: []Value{literal(int64(1)), literal(int64(2))}},
+						Right: CompareExpr{Column: "amount", Op: operator.OpGT, Value: literal(int64(10))},
+					},
+				},
+			},
+		},
+		{
+			name: "select like",
+			sql:  "SELECT `id` FROM `order` WHERE `name` LIKE 'ab%' AND `id` NOT LIKE ? AND `code` LIKE 'x!%' ESCAPE '!' AND 'ab' LIKE `name`",
+			wantStmt: &Statement{
+				Type:  Select,
+				Table: TableName{Name: "order"},
+				Where: AndExpr{
+					Left: AndExpr{
+						Left: AndExpr{
+							Left:  LikeExpr{Column: "name", Pattern: literal("ab%")},
+							Right: UnknownExpr{},
+						},
+						Right: UnknownExpr{},
+					},
+					Right: UnknownExpr{},
+				},
+			},
+		},
+		{
+			name: "select unknown",
+			sql: "select count(*) from db.`order` as o where o.name like ? and not o.user_id not in (?, ?) " +
+				"and (o.a + 1) = 2 and o.b between 1 and 2 and o.c is not null and f(o.d) = 'x' " +
+				"group by o.e, o.f having count(*) > 1 order by o.e desc, 1 limit ?, ? for update",
+			wantStmt: &Statement{
+				Type:      Select,
+				Table:     TableName{Schema: "db", Name: "order"},
+				Alias:     "o",
+				Aggregate: true,
+				GroupBy:   true,
+				Having:    true,
+				Where: AndExpr{
+					Left: AndExpr{
+						Left: AndExpr{
+							Left: AndExpr{
+								Left: AndExpr{
+									Left:  LikeExpr{Column: "name", Pattern: param(0)},
+									Right: NotExpr{Expr: InExpr{Column: "user_id", Not: true, Values: []Value{param(1), param(2)}}},
+								},
+								Right: UnknownExpr{},
+							},
+							Right: UnknownExpr{},
+						},
+						Right: UnknownExpr{},
+					},
+					Right: UnknownExpr{},
+				},
+				OrderBy:        []OrderByItem{{Column: "e", Desc: true}},
+				ComplexOrderBy: true,
+				Limit:          &Limit{Offset: param(3), Count: param(4)},
+			},
+		},
+		{
+			name: "select distinct limit offset",
+			sql:  "SELECT DISTINCT name FROM `order` ORDER BY name LIMIT 10 OFFSET 20",
+			wantStmt: &Statement{
+				Type:     Select,
+				Table:    TableName{Name: "order"},
+				Distinct: true,
+				OrderBy:  []OrderByItem{{Column: "name"}},
+				Limit:    &Limit{Offset: literal(int64(20)), Count: literal(int64(10))},
+			},
+		},
+		{
+			name: "insert",
+			sql:  "INSERT IGNORE INTO `order`(`user_id`,`name`) VALUES(?,?),(-3,NOW()) ON DUPLICATE KEY UPDATE `name`=VALUES(`name`)",
+			wantStmt: &Statement{
+				Type:    Insert,
+				Table:   TableName{Name: "order"},
+				Columns: []string{"user_id", "name"},
+				Rows: []Row{
+					{Values: []Expr{param(0), param(1)}},
+					{Values: []Expr{literal(int64(-3)), UnknownExpr{}}},
+				},
+			},
+		},
+		{
+			name: "update",
+			sql:  "UPDATE `order` SET `name` = CONCAT(`name`, ?), amount = amount + 1 WHERE user_id = ? LIMIT 1",
+			wantStmt: &Statement{
+				Type:        Update,
+				Table:       TableName{Name: "order"},
+				Assignments: []string{"name", "amount"},
+				Where:       CompareExpr{Column: "user_id", Op: operator.OpEQ, Value: param(1)},
+				Limit:       &Limit{Offset: literal(int64(0)), Count: literal(int64(1))},
+			},
+		},
+		{
+			name: "delete",
+			sql:  "DELETE FROM `order` WHERE ? <> user_id",
+			wantStmt: &Statement{
+				Type:  Delete,
+				Table: TableName{Name: "order"},
+				Where: CompareExpr{Column: "user_id", Op: operator.OpNEQ, Value: param(0)},
+			},
+		},
+		{
+			name:    "join",
+			sql:     "SELECT * FROM `order` JOIN `item` ON `order`.id = `item`.order_id",
+			wantErr: errs.NewErrUnsupportedRawSQL("JOIN"),
+		},
+		{
+			name:    "subquery",
+			sql:     "SELECT * FROM `order` WHERE id IN (SELECT order_id FROM item)",
+			wantErr: errs.NewErrUnsupportedRawSQL("子查询"),
+		},
+		{
+			name:    "insert select",
+			sql:     "INSERT INTO `order`(id) SELECT id FROM item",
+			wantErr: errs.NewErrUnsupportedRawSQL("子查询"),
+		},
+		{
+			name:    "union",
+			sql:     "SELECT * FROM a UNION SELECT * FROM b",
+			wantErr: errs.NewErrUnsupportedRawSQL("UNION"),
+		},
+		{
+			name:    "insert without columns",
+			sql:     "INSERT INTO `order` VALUES (1, 2)",
+			wantErr: errs.NewErrUnsupportedRawSQL("没有指定列的 INSERT"),
+		},
+		{
+			name:    "unsupported statement",
+			sql:     "SHOW TABLES",
+			wantErr: errs.NewErrUnsupportedRawSQL("SHOW"),
+		},
+		{
+			name:    "unterminated string",
+			sql:     "SELECT * FROM `order` WHERE name = 'abc",
+			wantErr: errs.NewErrRawSQLSyntax(35, "'abc"),
+		},
+		{
+			name:    "syntax error",
+			sql:     "SELECT * FROM `order` WHERE user_id = ? ORDER",
+			wantErr: errs.NewErrRawSQLSyntax(45, ""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stmt, err := Parse(tc.sql)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			// 位置信息由 TestStatement_Rewrite 覆盖
+			stmt.sql = ""
+			stmt.params = nil
+			stmt.tableSpans = nil
+			stmt.valuesSpan = span{}
+			if stmt.Limit != nil {
+				stmt.Limit.span = span{}
+			}
+			for i := range stmt.Rows {
+				stmt.Rows[i].span = span{}
+			}
+			assert.Equal(t, tc.wantStmt, stmt)
+		})
+	}
+}
+
+func TestStatement_Rewrite(t *testing.T) {
+	testCases := []struct {
+		name       string
+		sql        string
+		rows       []int
+		limit      int
+		wantSQL    string
+		wantParams []int
+	}{
+		{
+			name:       "table",
+			sql:        "SELECT `order`.id FROM `order` WHERE `order`.user_id = ? AND name = ?",
+			wantSQL:    "SELECT `order_tab_1`.id FROM `order_db_0`.`order_tab_1` WHERE `order_tab_1`.user_id = ? AND name = ?",
+			wantParams: []int{0, 1},
+		},
+		{
+			name:       "schema",
+			sql:        "SELECT logic.order.id FROM logic.`order` AS o WHERE o.user_id = ?",
+			wantSQL:    "SELECT `order_db_0`.`order_tab_1`.id FROM `order_db_0`.`order_tab_1` AS o WHERE o.user_id = ?",
+			wantParams: []int{0},
+		},
+		{
+			name:       "limit",
+			sql:        "SELECT id FROM `order` WHERE user_id IN (?,?) ORDER BY id LIMIT ?, ? FOR UPDATE",
+			limit:      30,
+			wantSQL:    "SELECT id FROM `order_db_0`.`order_tab_1` WHERE user_id IN (?,?) ORDER BY id LIMIT 30 FOR UPDATE",
+			wantParams: []int{0, 1},
+		},
+		{
+			name:       "insert rows",
+			sql:        "INSERT INTO `order`(user_id, name) VALUES (?, ?), (?, 'b'), (?, ?) ON DUPLICATE KEY UPDATE name = ?",
+			rows:       []int{0, 2},
+			wantSQL:    "INSERT INTO `order_db_0`.`order_tab_1`(user_id, name) VALUES (?, ?),(?, ?) ON DUPLICATE KEY UPDATE name = ?",
+			wantParams: []int{0, 1, 3, 4, 5},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stmt, err := Parse(tc.sql)
+			assert.NoError(t, err)
+			sql, params := stmt.Rewrite("order_db_0", "order_tab_1", tc.rows, tc.limit)
+			assert.Equal(t, tc.wantSQL, sql)
+			assert.Equal(t, tc.wantParams, params)
+		})
+	}
+}
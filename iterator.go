@@ -0,0 +1,89 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/ecodeclub/eorm/internal/valuer"
+)
+
+// Iterator 是查询结果的游标，每次调用 Next 才会读取下一行数据，
+// 不会把全部结果都加载到内存里面，适合导出大量数据之类的场景。
+// 读完全部数据或者出错之后会自动关闭底层的结果集；
+// 提前退出循环的时候必须调用 Close，一般直接 defer it.Close() 即可
+type Iterator[T any] struct {
+	rows       rows.Rows
+	valCreator valuer.PrimitiveCreator
+	meta       *model.TableMeta
+	cur        *T
+	err        error
+	closed     bool
+}
+
+func newIterator[T any](rs rows.Rows, c core, meta *model.TableMeta) *Iterator[T] {
+	return &Iterator[T]{
+		rows:       rs,
+		valCreator: c.valCreator,
+		meta:       meta,
+	}
+}
+
+// Next 读取下一行数据，没有数据或者出错的时候返回 false
+func (it *Iterator[T]) Next() bool {
+	if it.closed {
+		return false
+	}
+	if !it.rows.Next() {
+		it.fail(it.rows.Err())
+		return false
+	}
+	tp := new(T)
+	val := it.valCreator.NewPrimitiveValue(tp, it.meta)
+	if err := val.SetColumns(it.rows); err != nil {
+		it.fail(err)
+		return false
+	}
+	it.cur = tp
+	return true
+}
+
+// fail 记录错误并且关闭结果集
+func (it *Iterator[T]) fail(err error) {
+	it.cur = nil
+	it.err = err
+	if er := it.Close(); it.err == nil {
+		it.err = er
+	}
+}
+
+// Value 返回 Next 读到的数据
+func (it *Iterator[T]) Value() *T {
+	return it.cur
+}
+
+// Err 返回遍历过程中发生的错误
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// Close 关闭底层的结果集，可以重复调用
+func (it *Iterator[T]) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	return it.rows.Close()
+}
@@ -18,23 +18,25 @@ import operator "github.com/ecodeclub/eorm/internal/operator"
 
 // type op Operator.Op
 var (
-	opLT      = operator.OpLT
-	opLTEQ    = operator.OpLTEQ
-	opGT      = operator.OpGT
-	opGTEQ    = operator.OpGTEQ
-	opEQ      = operator.OpEQ
-	opNEQ     = operator.OpNEQ
-	opAdd     = operator.OpAdd
-	opMulti   = operator.OpMulti
-	opAnd     = operator.OpAnd
-	opOr      = operator.OpOr
-	opNot     = operator.OpNot
-	opIn      = operator.OpIn
-	opNotIN   = operator.OpNotIN
-	opFalse   = operator.OpFalse
-	opLike    = operator.OpLike
-	opNotLike = operator.OpNotLike
-	opExist   = operator.OpExist
+	opLT         = operator.OpLT
+	opLTEQ       = operator.OpLTEQ
+	opGT         = operator.OpGT
+	opGTEQ       = operator.OpGTEQ
+	opEQ         = operator.OpEQ
+	opNEQ        = operator.OpNEQ
+	opAdd        = operator.OpAdd
+	opMulti      = operator.OpMulti
+	opAnd        = operator.OpAnd
+	opOr         = operator.OpOr
+	opNot        = operator.OpNot
+	opIn         = operator.OpIn
+	opNotIN      = operator.OpNotIN
+	opFalse      = operator.OpFalse
+	opLike       = operator.OpLike
+	opNotLike    = operator.OpNotLike
+	opExist      = operator.OpExist
+	opBetween    = operator.OpBetween
+	opNotBetween = operator.OpNotBetween
 )
 
 // Predicate will be used in Where Or Having
@@ -87,6 +87,13 @@ func TestPredicate_C(t *testing.T) {
 			wantSql:  "SELECT `id` FROM `test_model` WHERE `age`>(`id`*(`age`+?));",
 			wantArgs: []interface{}{66},
 		},
+		{
+			name: "between",
+			builder: NewSelector[TestModel](db).Select(Columns("Id")).
+				Where(C("Age").Between(18, 30).And(C("Id").NotBetween(1, 10))),
+			wantSql:  "SELECT `id` FROM `test_model` WHERE (`age` BETWEEN ? AND ?) AND (`id` NOT BETWEEN ? AND ?);",
+			wantArgs: []interface{}{18, 30, 1, 10},
+		},
 		{
 			name:     "Avg with EQ",
 			builder:  NewSelector[TestModel](db).Select().GroupBy("FirstName").Having(Avg("Age").EQ(18)),
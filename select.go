@@ -136,13 +136,13 @@ func (s *Selector[T]) Build() (Query, error) {
 func (s *Selector[T]) buildTable(table TableReference) error {
 	switch t := table.(type) {
 	case nil:
-		s.quote(s.meta.TableName)
+		s.quote(s.tableName(s.meta))
 	case Table:
 		m, err := s.metaRegistry.Get(t.entity)
 		if err != nil {
 			return err
 		}
-		s.quote(m.TableName)
+		s.quote(s.tableName(m))
 		if t.alias != "" {
 			s.writeString(" AS ")
 			s.quote(t.alias)
@@ -359,6 +359,7 @@ func (s *Selector[T]) AsSubquery(alias string) Subquery {
 // 而且要注意，这个方法会强制设置 Limit 1
 // 在没有查找到数据的情况下，会返回 ErrNoRows
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
+	s.useShadow(ctx)
 	query, err := s.Limit(1).Build()
 	if err != nil {
 		return nil, err
@@ -394,6 +395,7 @@ type Selectable interface {
 }
 
 func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	s.useShadow(ctx)
 	query, err := s.Build()
 	if err != nil {
 		return nil, err
@@ -401,6 +403,16 @@ func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
 	return newQuerier[T](s.Session, query, s.meta, SELECT).GetMulti(ctx)
 }
 
+// Iter 和 GetMulti 一样执行查询，但是返回的是游标，遍历的时候才逐行读取数据
+func (s *Selector[T]) Iter(ctx context.Context) (*Iterator[T], error) {
+	s.useShadow(ctx)
+	query, err := s.Build()
+	if err != nil {
+		return nil, err
+	}
+	return newQuerier[T](s.Session, query, s.meta, SELECT).Iter(ctx)
+}
+
 func (s *Selector[T]) buildJoin(t Join) error {
 	s.writeByte('(')
 	if err := s.buildTable(t.left); err != nil {
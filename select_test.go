@@ -17,6 +17,7 @@ package eorm
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"testing"
 
@@ -782,6 +783,86 @@ func TestSelector_GetMulti_baseType(t *testing.T) {
 	}
 }
 
+func TestSelector_Iter(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDS("mysql", single.NewDB(mockDB))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		mockOrder func(mock sqlmock.Sqlmock)
+		// limit 是读取的行数，小于 0 表示全部读完
+		limit   int
+		wantErr error
+		wantVal []*TestModel
+	}{
+		{
+			name: "all",
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id", "first_name"}).
+					AddRow(1, "Tom").AddRow(2, "Jerry")
+				mock.ExpectQuery("SELECT `id`,`first_name` FROM `test_model`;").
+					WillReturnRows(rows).RowsWillBeClosed()
+			},
+			limit:   -1,
+			wantVal: []*TestModel{{Id: 1, FirstName: "Tom"}, {Id: 2, FirstName: "Jerry"}},
+		},
+		{
+			name: "break",
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id", "first_name"}).
+					AddRow(1, "Tom").AddRow(2, "Jerry")
+				mock.ExpectQuery("SELECT `id`,`first_name` FROM `test_model`;").
+					WillReturnRows(rows).RowsWillBeClosed()
+			},
+			limit:   1,
+			wantVal: []*TestModel{{Id: 1, FirstName: "Tom"}},
+		},
+		{
+			name: "rows err",
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				rows := mock.NewRows([]string{"id", "first_name"}).
+					AddRow(1, "Tom").AddRow(2, "Jerry").RowError(1, errors.New("mock error"))
+				mock.ExpectQuery("SELECT `id`,`first_name` FROM `test_model`;").
+					WillReturnRows(rows).RowsWillBeClosed()
+			},
+			limit:   -1,
+			wantErr: errors.New("mock error"),
+			wantVal: []*TestModel{{Id: 1, FirstName: "Tom"}},
+		},
+		{
+			name: "query err",
+			mockOrder: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT `id`,`first_name` FROM `test_model`;").
+					WillReturnError(errors.New("mock error"))
+			},
+			wantErr: errors.New("mock error"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockOrder(mock)
+			it, err := NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Iter(context.Background())
+			if err != nil {
+				assert.Equal(t, tc.wantErr, err)
+				return
+			}
+			var res []*TestModel
+			for (tc.limit < 0 || len(res) < tc.limit) && it.Next() {
+				res = append(res, it.Value())
+			}
+			require.NoError(t, it.Close())
+			assert.Equal(t, tc.wantErr, it.Err())
+			assert.Equal(t, tc.wantVal, res)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
 func TestSelectable(t *testing.T) {
 	db := memoryDB()
 	testCases := []CommonTestCase{
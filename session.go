@@ -21,7 +21,6 @@ import (
 	"github.com/ecodeclub/ekit/list"
 	"github.com/ecodeclub/eorm/internal/datasource"
 	"github.com/ecodeclub/eorm/internal/rows"
-	"golang.org/x/sync/errgroup"
 )
 
 var _ Session = (*baseSession)(nil)
@@ -35,22 +34,14 @@ func (sess *baseSession) queryContext(ctx context.Context, q Query) (rows.Rows,
 	return sess.executor.Query(ctx, q)
 }
 
+// queryMulti 并发执行 qs，并发度受 DBWithMaxParallelism 限制。
+// 任何一个查询失败都会取消其它查询
 func (sess *baseSession) queryMulti(ctx context.Context, qs []Query) (list.List[rows.Rows], error) {
-	res := &list.ConcurrentList[rows.Rows]{
-		List: list.NewArrayList[rows.Rows](len(qs)),
+	rowsList, _, err := queryAll(ctx, sess, sess.maxParallelism, qs, false)
+	if err != nil {
+		return nil, err
 	}
-	var eg errgroup.Group
-	for _, query := range qs {
-		q := query
-		eg.Go(func() error {
-			rs, err := sess.queryContext(ctx, q)
-			if err == nil {
-				return res.Append(rs)
-			}
-			return err
-		})
-	}
-	return res, eg.Wait()
+	return list.NewArrayListOf(rowsList), nil
 }
 
 func (sess *baseSession) execContext(ctx context.Context, q Query) (sql.Result, error) {
@@ -0,0 +1,107 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// DBWithShadowPrefix 设置影子表的前缀，默认是 sharding.DefaultShadowPrefix。
+// 没有分库分表的 Selector、Inserter、Updater 和 Deleter 在压测流量下会访问加上前缀的影子表，
+// 影子保护也用它来判断目标是不是影子，所以需要和分库分表算法里面的前缀保持一致
+func DBWithShadowPrefix(prefix string) DBOption {
+	return func(db *DB) {
+		db.shadowPrefix = prefix
+	}
+}
+
+// DBWithShadowGuard 开启影子保护。
+// 开启之后，压测流量写入的数据源、库和表至少有一个要带有影子前缀，否则直接返回错误，不会执行；
+// 压测流量也不能通过 RawQuery 写入数据，因为无法确认写入的是哪张表
+func DBWithShadowGuard() DBOption {
+	return func(db *DB) {
+		db.shadowGuard = true
+	}
+}
+
+// checkShadowWrite 开启影子保护之后，检查压测流量是不是只写入了影子
+func (c core) checkShadowWrite(ctx context.Context, dsts []sharding.Dst) error {
+	if !c.shadowGuard {
+		return nil
+	}
+	if _, ok := sharding.ShadowFromCtx(ctx); !ok {
+		return nil
+	}
+	for _, dst := range dsts {
+		if !sharding.IsShadowDst(c.shadowPrefix, dst) {
+			return errs.NewErrShadowWriteProd(dstName(dst))
+		}
+	}
+	return nil
+}
+
+// checkShadowRaw 开启影子保护之后，压测流量不能执行原生 SQL 的写操作
+func (c core) checkShadowRaw(ctx context.Context) error {
+	if !c.shadowGuard {
+		return nil
+	}
+	if _, ok := sharding.ShadowFromCtx(ctx); ok {
+		return errs.ErrShadowRawWrite
+	}
+	return nil
+}
+
+func dstName(dst sharding.Dst) string {
+	name := dst.Table
+	if dst.DB != "" {
+		name = dst.DB + "." + name
+	}
+	if dst.Name != "" {
+		name = dst.Name + "/" + name
+	}
+	return name
+}
+
+// useShadow 根据 ctx 上的压测标记决定是否使用影子表
+func (b *builder) useShadow(ctx context.Context) {
+	target, ok := sharding.ShadowFromCtx(ctx)
+	b.shadow = ok && target.Has(sharding.ShadowTable)
+}
+
+// tableName 返回 SQL 里面使用的表名，压测流量使用影子表
+func (b *builder) tableName(m *model.TableMeta) string {
+	if b.shadow {
+		return b.shadowPrefix + m.TableName
+	}
+	return m.TableName
+}
+
+// checkShadowTable 开启影子保护之后，检查压测流量是不是写入了影子表
+func (b *builder) checkShadowTable(ctx context.Context) error {
+	return b.checkShadowWrite(ctx, []sharding.Dst{{Table: b.tableName(b.meta)}})
+}
+
+// shadowable 是可以使用影子表的子查询
+type shadowable interface {
+	setShadow(shadow bool)
+}
+
+func (b *builder) setShadow(shadow bool) {
+	b.shadow = shadow
+}
@@ -0,0 +1,296 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/datasource/single"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadow_Exec(t *testing.T) {
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDS("mysql", single.NewDB(mockDB), DBWithShadowGuard())
+	require.NoError(t, err)
+
+	shadowCtx := sharding.CtxWithShadow(context.Background())
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		mockExec func(mock sqlmock.Sqlmock)
+		exec     func(ctx context.Context) error
+		wantErr  error
+	}{
+		{
+			name: "insert",
+			ctx:  shadowCtx,
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `shadow_test_model`(`id`,`first_name`,`age`,`last_name`) VALUES(?,?,?,?);").
+					WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			exec: func(ctx context.Context) error {
+				return NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "update",
+			ctx:  shadowCtx,
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("UPDATE `shadow_test_model` SET `age`=? WHERE `id`=?;").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			exec: func(ctx context.Context) error {
+				return NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+					Set(C("Age")).Where(C("Id").EQ(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "delete",
+			ctx:  shadowCtx,
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE FROM `shadow_test_model` WHERE `id`=?;").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			exec: func(ctx context.Context) error {
+				return NewDeleter[TestModel](db).From(&TestModel{}).Where(C("Id").EQ(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "select subquery",
+			ctx:  shadowCtx,
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT `id` FROM (SELECT `id`,`first_name`,`age`,`last_name` FROM `shadow_test_model`) AS `sub`;").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			exec: func(ctx context.Context) error {
+				sub := NewSelector[TestModel](db).AsSubquery("sub")
+				_, err := NewSelector[TestModel](db).Select(C("Id")).From(sub).GetMulti(ctx)
+				return err
+			},
+		},
+		{
+			name: "not shadow",
+			ctx:  context.Background(),
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE FROM `test_model` WHERE `id`=?;").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			exec: func(ctx context.Context) error {
+				return NewDeleter[TestModel](db).From(&TestModel{}).Where(C("Id").EQ(1)).Exec(ctx).Err()
+			},
+		},
+		{
+			name: "shadow db only",
+			ctx:  sharding.CtxWithShadow(context.Background(), sharding.ShadowDB),
+			exec: func(ctx context.Context) error {
+				return NewInserter[TestModel](db).Values(&TestModel{Id: 1}).Exec(ctx).Err()
+			},
+			wantErr: errs.NewErrShadowWriteProd("test_model"),
+		},
+		{
+			name: "raw write",
+			ctx:  shadowCtx,
+			exec: func(ctx context.Context) error {
+				return RawQuery[TestModel](db, "DELETE FROM `test_model`").Exec(ctx).Err()
+			},
+			wantErr: errs.ErrShadowRawWrite,
+		},
+		{
+			name: "raw read",
+			ctx:  shadowCtx,
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT `id` FROM `shadow_test_model`").
+					WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+			},
+			exec: func(ctx context.Context) error {
+				_, err := RawQuery[TestModel](db, "SELECT `id` FROM `shadow_test_model`").Get(ctx)
+				return err
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.mockExec != nil {
+				tc.mockExec(mock)
+			}
+			err := tc.exec(tc.ctx)
+			assert.Equal(t, tc.wantErr, err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShadow_Sharding(t *testing.T) {
+	dsPattern := "0.db.cluster.company.com:3306"
+	newHash := func() *hash.Hash {
+		return &hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}
+	}
+	shadowRegistry := model.NewMetaRegistry()
+	_, err := shadowRegistry.Register(&Order{},
+		model.WithTableShardingAlgorithm(&sharding.ShadowAlgorithm{Algorithm: newHash()}))
+	require.NoError(t, err)
+	prodRegistry := model.NewMetaRegistry()
+	_, err = prodRegistry.Register(&Order{}, model.WithTableShardingAlgorithm(newHash()))
+	require.NoError(t, err)
+
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	})
+	shadowDB, err := OpenDS("sqlite3", ds, DBWithMetaRegistry(shadowRegistry), DBWithShadowGuard())
+	require.NoError(t, err)
+	prodDB, err := OpenDS("sqlite3", ds, DBWithMetaRegistry(prodRegistry), DBWithShadowGuard())
+	require.NoError(t, err)
+
+	tableCtx := sharding.CtxWithShadow(context.Background())
+	dbCtx := sharding.CtxWithShadow(context.Background(), sharding.ShadowDB)
+
+	t.Run("build", func(t *testing.T) {
+		testCases := []struct {
+			name   string
+			ctx    context.Context
+			where  []Predicate
+			wantQs []sharding.Query
+		}{
+			{
+				name:  "shadow table",
+				ctx:   tableCtx,
+				where: []Predicate{C("UserId").EQ(123)},
+				wantQs: []sharding.Query{
+					{
+						SQL:        "SELECT `content` FROM `order_db_1`.`shadow_order_tab_0` WHERE `user_id`=?;",
+						Args:       []any{123},
+						DB:         "order_db_1",
+						Datasource: dsPattern,
+					},
+				},
+			},
+			{
+				name:  "shadow db",
+				ctx:   dbCtx,
+				where: []Predicate{C("UserId").EQ(123)},
+				wantQs: []sharding.Query{
+					{
+						SQL:        "SELECT `content` FROM `shadow_order_db_1`.`order_tab_0` WHERE `user_id`=?;",
+						Args:       []any{123},
+						DB:         "shadow_order_db_1",
+						Datasource: dsPattern,
+					},
+				},
+			},
+			{
+				name:  "broadcast",
+				ctx:   tableCtx,
+				where: []Predicate{C("Content").EQ("hello")},
+				wantQs: func() []sharding.Query {
+					var res []sharding.Query
+					for i := 0; i < 2; i++ {
+						for j := 0; j < 3; j++ {
+							res = append(res, sharding.Query{
+								SQL:        fmt.Sprintf("SELECT `content` FROM `order_db_%d`.`shadow_order_tab_%d` WHERE `content`=?;", i, j),
+								Args:       []any{"hello"},
+								DB:         fmt.Sprintf("order_db_%d", i),
+								Datasource: dsPattern,
+							})
+						}
+					}
+					return res
+				}(),
+			},
+			{
+				name:  "not shadow",
+				ctx:   context.Background(),
+				where: []Predicate{C("UserId").EQ(123)},
+				wantQs: []sharding.Query{
+					{
+						SQL:        "SELECT `content` FROM `order_db_1`.`order_tab_0` WHERE `user_id`=?;",
+						Args:       []any{123},
+						DB:         "order_db_1",
+						Datasource: dsPattern,
+					},
+				},
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				qs, err := NewShardingSelector[Order](shadowDB).
+					Select(C("Content")).Where(tc.where...).Build(tc.ctx)
+				require.NoError(t, err)
+				assert.ElementsMatch(t, tc.wantQs, qs)
+			})
+		}
+	})
+
+	t.Run("guard", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			exec    func(ctx context.Context) sharding.Result
+			wantErr error
+		}{
+			{
+				name: "insert",
+				exec: NewShardingInsert[Order](prodDB).
+					Values([]*Order{{UserId: 123, OrderId: 1}}).Exec,
+				wantErr: errs.NewErrShadowWriteProd(dsPattern + "/order_db_1.order_tab_0"),
+			},
+			{
+				name: "update",
+				exec: NewShardingUpdater[Order](prodDB).Update(&Order{Content: "hello"}).
+					Set(C("Content")).Where(C("UserId").EQ(123)).Exec,
+				wantErr: errs.NewErrShadowWriteProd(dsPattern + "/order_db_1.order_tab_0"),
+			},
+			{
+				name:    "delete",
+				exec:    NewShardingDeleter[Order](prodDB).Where(C("UserId").EQ(123)).Exec,
+				wantErr: errs.NewErrShadowWriteProd(dsPattern + "/order_db_1.order_tab_0"),
+			},
+			{
+				name: "raw",
+				exec: ShardingRawQuery[Order](prodDB,
+					"DELETE FROM `order` WHERE `user_id`=?", 123).Exec,
+				wantErr: errs.NewErrShadowWriteProd(dsPattern + "/order_db_1.order_tab_0"),
+			},
+		}
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				err := tc.exec(tableCtx).Err()
+				assert.Equal(t, tc.wantErr, err)
+			})
+		}
+	})
+}
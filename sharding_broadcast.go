@@ -0,0 +1,69 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ecodeclub/eorm/internal/datasource/transaction"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"go.uber.org/multierr"
+)
+
+// execBroadcast 在一个事务里面执行广播表的写操作，保证每个目标上的数据是一致的
+func execBroadcast(ctx context.Context, sess Session, qs []Query) sharding.Result {
+	var res []sql.Result
+	err := execInTx(ctx, sess, func(ctx context.Context, tx Session) error {
+		var err error
+		res, err = execQueries(ctx, tx, qs)
+		return err
+	})
+	return sharding.NewResult(res, err)
+}
+
+// execInTx 在事务里面执行 fn，fn 返回错误的时候回滚事务。
+// 如果 sess 本身就是一个事务，那么直接使用它，由调用者负责提交或者回滚；
+// 否则开启一个 Delay 类型的分布式事务
+func execInTx(ctx context.Context, sess Session, fn func(ctx context.Context, tx Session) error) error {
+	db, ok := sess.(*DB)
+	if !ok {
+		return fn(ctx, sess)
+	}
+	if transaction.GetCtxTypeKey(ctx) == nil {
+		ctx = transaction.UsingTxType(ctx, transaction.Delay)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(ctx, tx); err != nil {
+		return multierr.Combine(err, tx.Rollback())
+	}
+	return tx.Commit()
+}
+
+// execQueries 依次执行 qs，遇到错误就立刻返回
+func execQueries(ctx context.Context, sess Session, qs []Query) ([]sql.Result, error) {
+	res := make([]sql.Result, 0, len(qs))
+	for _, q := range qs {
+		r, err := sess.execContext(ctx, q)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, r)
+	}
+	return res, nil
+}
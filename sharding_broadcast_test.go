@@ -0,0 +1,247 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Currency struct {
+	Id   int64
+	Name string
+}
+
+func newCurrencyRegistry(t *testing.T, dsPattern string) model.MetaRegistry {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&Currency{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "Id",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "currency", NotSharding: true},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}), model.WithBroadcastTable())
+	require.NoError(t, err)
+	return r
+}
+
+func TestShardingBroadcastTable_Build(t *testing.T) {
+	dsPattern := "0.db.cluster.company.com:3306"
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	shardingDB, err := OpenDS("sqlite3", shardingsource.NewShardingDataSource(ds),
+		DBWithMetaRegistry(newCurrencyRegistry(t, dsPattern)))
+	require.NoError(t, err)
+
+	// 读操作只会命中其中一个目标
+	qs, err := NewShardingSelector[Currency](shardingDB).
+		Where(C("Id").EQ(1)).Build(context.Background())
+	require.NoError(t, err)
+	require.Len(t, qs, 1)
+	assert.Contains(t, []sharding.Query{
+		{
+			SQL:        "SELECT `id`,`name` FROM `order_db_0`.`currency` WHERE `id`=?;",
+			Args:       []any{1},
+			DB:         "order_db_0",
+			Datasource: dsPattern,
+		},
+		{
+			SQL:        "SELECT `id`,`name` FROM `order_db_1`.`currency` WHERE `id`=?;",
+			Args:       []any{1},
+			DB:         "order_db_1",
+			Datasource: dsPattern,
+		},
+	}, qs[0])
+
+	testCases := []struct {
+		name    string
+		builder sharding.QueryBuilder
+		wantQs  []sharding.Query
+	}{
+		{
+			name: "insert",
+			builder: NewShardingInsert[Currency](shardingDB).Values([]*Currency{
+				{Id: 1, Name: "CNY"},
+				{Id: 2, Name: "USD"},
+			}),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_db_0`.`currency`(`id`,`name`) VALUES(?,?),(?,?);",
+					Args:       []any{int64(1), "CNY", int64(2), "USD"},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "INSERT INTO `order_db_1`.`currency`(`id`,`name`) VALUES(?,?),(?,?);",
+					Args:       []any{int64(1), "CNY", int64(2), "USD"},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "insert without sharding key",
+			builder: NewShardingInsert[Currency](shardingDB).
+				Columns([]string{"Name"}).Values([]*Currency{{Name: "CNY"}}),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_db_0`.`currency`(`name`) VALUES(?);",
+					Args:       []any{"CNY"},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "INSERT INTO `order_db_1`.`currency`(`name`) VALUES(?);",
+					Args:       []any{"CNY"},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "update",
+			builder: NewShardingUpdater[Currency](shardingDB).Update(&Currency{Name: "RMB"}).
+				Set(C("Name")).Where(C("Id").EQ(1)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "UPDATE `order_db_0`.`currency` SET `name`=? WHERE `id`=?;",
+					Args:       []any{"RMB", 1},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "UPDATE `order_db_1`.`currency` SET `name`=? WHERE `id`=?;",
+					Args:       []any{"RMB", 1},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name:    "delete",
+			builder: NewShardingDeleter[Currency](shardingDB).Where(C("Id").EQ(1)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_db_0`.`currency` WHERE `id`=?;",
+					Args:       []any{1},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "DELETE FROM `order_db_1`.`currency` WHERE `id`=?;",
+					Args:       []any{1},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(context.Background())
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.wantQs, qs)
+		})
+	}
+}
+
+func TestShardingBroadcastTable_Exec(t *testing.T) {
+	dsPattern := "0.db.cluster.company.com:3306"
+	testCases := []struct {
+		name         string
+		mock         func(mock0, mock1 sqlmock.Sqlmock)
+		wantAffected int64
+		wantErr      error
+	}{
+		{
+			name: "commit",
+			mock: func(mock0, mock1 sqlmock.Sqlmock) {
+				mock0.ExpectBegin()
+				mock0.ExpectExec("DELETE FROM `order_db_0`.`currency` WHERE `id`=?;").
+					WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock0.ExpectCommit()
+				mock1.ExpectBegin()
+				mock1.ExpectExec("DELETE FROM `order_db_1`.`currency` WHERE `id`=?;").
+					WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock1.ExpectCommit()
+			},
+			wantAffected: 2,
+		},
+		{
+			name: "rollback",
+			mock: func(mock0, mock1 sqlmock.Sqlmock) {
+				mock0.ExpectBegin()
+				mock0.ExpectExec("DELETE FROM `order_db_0`.`currency` WHERE `id`=?;").
+					WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock0.ExpectRollback()
+				mock1.ExpectBegin()
+				mock1.ExpectExec("DELETE FROM `order_db_1`.`currency` WHERE `id`=?;").
+					WithArgs(1).WillReturnError(errors.New("exec err"))
+				mock1.ExpectRollback()
+			},
+			wantErr: errors.New("exec err"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB0, mock0, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer func() { _ = mockDB0.Close() }()
+			mockDB1, mock1, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer func() { _ = mockDB1.Close() }()
+			tc.mock(mock0, mock1)
+
+			m := map[string]*masterslave.MasterSlavesDB{
+				"order_db_0": masterslave.NewMasterSlavesDB(mockDB0),
+				"order_db_1": masterslave.NewMasterSlavesDB(mockDB1),
+			}
+			ds := map[string]datasource.DataSource{
+				dsPattern: cluster.NewClusterDB(m),
+			}
+			shardingDB, err := OpenDS("mysql", shardingsource.NewShardingDataSource(ds),
+				DBWithMetaRegistry(newCurrencyRegistry(t, dsPattern)))
+			require.NoError(t, err)
+
+			res := NewShardingDeleter[Currency](shardingDB).
+				Where(C("Id").EQ(1)).Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			if res.Err() == nil {
+				affected, err := res.RowsAffected()
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantAffected, affected)
+			}
+			assert.NoError(t, mock0.ExpectationsWereMet())
+			assert.NoError(t, mock1.ExpectationsWereMet())
+		})
+	}
+}
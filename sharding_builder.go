@@ -16,9 +16,12 @@ package eorm
 
 import (
 	"context"
+	"reflect"
+	"strings"
 
 	"github.com/ecodeclub/ekit/slice"
 	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
 	operator "github.com/ecodeclub/eorm/internal/operator"
 	"github.com/ecodeclub/eorm/internal/sharding"
 )
@@ -28,10 +31,17 @@ type shardingBuilder struct {
 }
 
 func (b *shardingBuilder) findDst(ctx context.Context, predicates ...Predicate) (sharding.Response, error) {
+	if res, ok, err := b.findHintDst(ctx); ok {
+		return res, err
+	}
+	// 广播表的每一个目标上都有全部数据，和查询条件无关
+	if b.meta.BroadcastTable {
+		return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+	}
 	//  通过遍历 pre 查找目标 shardingkey
 	if len(predicates) > 0 {
 		pre := predicates[0]
-		for i := 1; i < len(predicates)-1; i++ {
+		for i := 1; i < len(predicates); i++ {
 			pre = pre.And(predicates[i])
 		}
 		return b.findDstByPredicate(ctx, pre)
@@ -42,6 +52,24 @@ func (b *shardingBuilder) findDst(ctx context.Context, predicates ...Predicate)
 	return res, nil
 }
 
+// findHintDst 使用 sharding.CtxWithDst 指定的目标表。
+// 第二个返回值表示 ctx 里面有没有指定目标表；指定的目标表必须是 Broadcast 的结果之一
+func (b *shardingBuilder) findHintDst(ctx context.Context) (sharding.Response, bool, error) {
+	dsts, ok := sharding.DstFromCtx(ctx)
+	if !ok {
+		return sharding.EmptyResp, false, nil
+	}
+	all := b.meta.ShardingAlgorithm.Broadcast(ctx)
+	for _, dst := range dsts {
+		if !slice.ContainsFunc[sharding.Dst](all, func(src sharding.Dst) bool {
+			return src.Equals(dst)
+		}) {
+			return sharding.EmptyResp, true, errs.NewErrInvalidHintDst(dst.Name, dst.DB, dst.Table)
+		}
+	}
+	return sharding.Response{Dsts: dsts}, true, nil
+}
+
 func (b *shardingBuilder) findDstByPredicate(ctx context.Context, pre Predicate) (sharding.Response, error) {
 	switch pre.op {
 	case opAnd:
@@ -66,6 +94,9 @@ func (b *shardingBuilder) findDstByPredicate(ctx context.Context, pre Predicate)
 		return b.mergeOR(left, right), nil
 	case opIn:
 		col := pre.left.(Column)
+		if !b.isRoutingColumn(col) {
+			return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+		}
 		right := pre.right.(values)
 		var results []sharding.Response
 		for _, val := range right.data {
@@ -86,12 +117,46 @@ func (b *shardingBuilder) findDstByPredicate(ctx context.Context, pre Predicate)
 	case opNotIN:
 		return b.meta.ShardingAlgorithm.Sharding(ctx,
 			sharding.Request{Op: opNotIN, SkValues: map[string]any{}})
+	case opNotBetween, opNotLike:
+		return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+	case opBetween:
+		col, isCol := pre.left.(Column)
+		bounds, isBounds := pre.right.(binaryExpr)
+		if !isCol || !isBounds {
+			return sharding.EmptyResp, errs.ErrUnsupportedTooComplexQuery
+		}
+		start, isStart := bounds.left.(valueExpr)
+		end, isEnd := bounds.right.(valueExpr)
+		if !isStart || !isEnd {
+			return sharding.EmptyResp, errs.ErrUnsupportedTooComplexQuery
+		}
+		if !b.isRoutingColumn(col) {
+			return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+		}
+		return b.meta.ShardingAlgorithm.Sharding(ctx, sharding.Request{
+			Op:       opBetween,
+			SkValues: map[string]any{col.name: sharding.Range{Start: start.val, End: end.val}},
+		})
+	case opLike:
+		col, isCol := pre.left.(Column)
+		right, isVals := pre.right.(valueExpr)
+		if !isCol || !isVals {
+			return sharding.EmptyResp, errs.ErrUnsupportedTooComplexQuery
+		}
+		pattern, ok := right.val.(string)
+		if !ok || !b.isRoutingColumn(col) {
+			return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+		}
+		return b.findDstByLike(ctx, col.name, pattern)
 	case opEQ, opGT, opLT, opGTEQ, opLTEQ, opNEQ:
 		col, isCol := pre.left.(Column)
 		right, isVals := pre.right.(valueExpr)
 		if !isCol || !isVals {
 			return sharding.EmptyResp, errs.ErrUnsupportedTooComplexQuery
 		}
+		if !b.isRoutingColumn(col) {
+			return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+		}
 		return b.meta.ShardingAlgorithm.Sharding(ctx,
 			sharding.Request{Op: pre.op, SkValues: map[string]any{col.name: right.val}})
 	default:
@@ -99,6 +164,127 @@ func (b *shardingBuilder) findDstByPredicate(ctx context.Context, pre Predicate)
 	}
 }
 
+// findDstByLike 使用 LIKE 模式中第一个通配符之前的前缀查找目标表。
+// 没有通配符的时候等价于 =；前缀 abc 对应范围 [abc, abd)；没有前缀的时候只能广播
+func (b *shardingBuilder) findDstByLike(ctx context.Context, field string, pattern string) (sharding.Response, error) {
+	// 只有字符串类型的 sharding key 才能按照前缀查找，例如整数 123 LIKE '12%' 也成立
+	if !b.isStringField(field) {
+		return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+	}
+	prefix, exact := likePrefix(pattern)
+	if exact {
+		return b.meta.ShardingAlgorithm.Sharding(ctx,
+			sharding.Request{Op: opEQ, SkValues: map[string]any{field: prefix}})
+	}
+	if prefix == "" {
+		return sharding.Response{Dsts: b.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+	}
+	rg := sharding.Range{Start: prefix, ExclusiveEnd: true}
+	// 前缀全部是 0xff 的时候没有上界
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			rg.End = string(end[:i+1])
+			break
+		}
+	}
+	return b.meta.ShardingAlgorithm.Sharding(ctx,
+		sharding.Request{Op: opBetween, SkValues: map[string]any{field: rg}})
+}
+
+func (b *shardingBuilder) isStringField(field string) bool {
+	cm, ok := b.meta.FieldMap[field]
+	if !ok {
+		return false
+	}
+	typ := cm.Typ
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.String
+}
+
+// likePrefix 返回 LIKE 模式中第一个通配符之前的部分，去掉了转义符。
+// exact 表示模式中没有通配符
+func likePrefix(pattern string) (prefix string, exact bool) {
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%', '_':
+			return sb.String(), false
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			sb.WriteByte(pattern[i])
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), true
+}
+
+// isRoutingColumn 判断能否使用 col 查找目标表。
+// JOIN 的时候只有主表和绑定表上的列能够用来查找目标表，广播表上的列和分片无关
+func (b *shardingBuilder) isRoutingColumn(col Column) bool {
+	t, ok := col.table.(Table)
+	if !ok {
+		return true
+	}
+	m, err := b.metaRegistry.Get(t.entity)
+	if err != nil {
+		return false
+	}
+	return m.Typ == b.meta.Typ || (!m.BroadcastTable && b.isBindingTable(m))
+}
+
+// isBindingTable 判断 m 是否和主表在同一个绑定表分组里面
+func (b *shardingBuilder) isBindingTable(m *model.TableMeta) bool {
+	return m.BindingGroup != "" && m.BindingGroup == b.meta.BindingGroup &&
+		m.ShardingAlgorithm != nil
+}
+
+// checkJoinTable 检查 m 能否和主表在每个分片上直接 JOIN。
+// 广播表可以和任何表 JOIN；主表不是广播表的时候，还可以和绑定表 JOIN
+func (b *shardingBuilder) checkJoinTable(m *model.TableMeta) error {
+	if m.Typ == b.meta.Typ || m.BroadcastTable && m.ShardingAlgorithm != nil {
+		return nil
+	}
+	if !b.meta.BroadcastTable && b.isBindingTable(m) {
+		return nil
+	}
+	return errs.NewErrUnboundJoinTable(b.meta.TableName, m.TableName)
+}
+
+// bindingDst 找到表 m 上和主表的目标表 dst 对应的目标表。
+// 广播表使用同一个库上的那一份数据；
+// 绑定表和主表的分片规则一致，所以两者 Broadcast 的结果按照顺序一一对应
+func (b *shardingBuilder) bindingDst(ctx context.Context, m *model.TableMeta, dst sharding.Dst) (sharding.Dst, error) {
+	if m.Typ == b.meta.Typ {
+		return dst, nil
+	}
+	all := m.ShardingAlgorithm.Broadcast(ctx)
+	var idx int
+	if m.BroadcastTable {
+		idx = slice.IndexFunc[sharding.Dst](all, func(src sharding.Dst) bool {
+			return src.Name == dst.Name && src.DB == dst.DB
+		})
+	} else {
+		idx = slice.IndexFunc[sharding.Dst](b.meta.ShardingAlgorithm.Broadcast(ctx), func(src sharding.Dst) bool {
+			return src.Equals(dst)
+		})
+		// 对应的目标表必须在同一个库上，否则无法 JOIN
+		if idx >= 0 && (idx >= len(all) || all[idx].Name != dst.Name || all[idx].DB != dst.DB) {
+			idx = -1
+		}
+	}
+	if idx < 0 {
+		return sharding.Dst{}, errs.NewErrBindingTableMismatch(m.TableName, dst.DB, dst.Table)
+	}
+	return all[idx], nil
+}
+
 func (b *shardingBuilder) negatePredicate(pre Predicate) (Predicate, error) {
 	switch pre.op {
 	case opAnd:
@@ -0,0 +1,306 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/ecodeclub/eorm/internal/sharding/ranges"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardingBuilder_HintDst(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	dst := sharding.Dst{Name: dsPattern, DB: "order_db_0", Table: "order_tab_2"}
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		builder sharding.QueryBuilder
+		wantQs  []sharding.Query
+		wantErr error
+	}{
+		{
+			name: "select",
+			ctx:  sharding.CtxWithDst(context.Background(), dst),
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(C("Content")).Where(C("UserId").EQ(123)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT `content` FROM `order_db_0`.`order_tab_2` WHERE `user_id`=?;",
+					Args:       []any{123},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "select multiple dsts",
+			ctx: sharding.CtxWithDst(context.Background(), dst,
+				sharding.Dst{Name: dsPattern, DB: "order_db_1", Table: "order_tab_0"}),
+			builder: NewShardingSelector[Order](shardingDB).Select(C("Content")),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT `content` FROM `order_db_0`.`order_tab_2`;",
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "SELECT `content` FROM `order_db_1`.`order_tab_0`;",
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "select invalid dst",
+			ctx: sharding.CtxWithDst(context.Background(),
+				sharding.Dst{Name: dsPattern, DB: "order_db_3", Table: "order_tab_7"}),
+			builder: NewShardingSelector[Order](shardingDB).Select(C("Content")),
+			wantErr: errs.NewErrInvalidHintDst(dsPattern, "order_db_3", "order_tab_7"),
+		},
+		{
+			name: "insert",
+			ctx:  sharding.CtxWithDst(context.Background(), dst),
+			builder: NewShardingInsert[Order](shardingDB).Values([]*Order{
+				{UserId: 1, OrderId: 1, Content: "1", Account: 1.0},
+				{UserId: 2, OrderId: 2, Content: "2", Account: 2.0},
+			}),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_db_0`.`order_tab_2`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?),(?,?,?,?);",
+					Args:       []any{1, int64(1), "1", 1.0, 2, int64(2), "2", 2.0},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "insert multiple dsts",
+			ctx: sharding.CtxWithDst(context.Background(), dst,
+				sharding.Dst{Name: dsPattern, DB: "order_db_1", Table: "order_tab_0"}),
+			builder: NewShardingInsert[Order](shardingDB).Values([]*Order{
+				{UserId: 1, OrderId: 1, Content: "1", Account: 1.0},
+			}),
+			wantErr: errs.ErrInsertFindingDst,
+		},
+		{
+			name: "update",
+			ctx:  sharding.CtxWithDst(context.Background(), dst),
+			builder: NewShardingUpdater[Order](shardingDB).Update(&Order{
+				Content: "1",
+			}).Set(C("Content")).Where(C("UserId").EQ(1)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "UPDATE `order_db_0`.`order_tab_2` SET `content`=? WHERE `user_id`=?;",
+					Args:       []any{"1", 1},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name:    "delete",
+			ctx:     sharding.CtxWithDst(context.Background(), dst),
+			builder: NewShardingDeleter[Order](shardingDB).Where(C("UserId").EQ(1)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_db_0`.`order_tab_2` WHERE `user_id`=?;",
+					Args:       []any{1},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(tc.ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQs, qs)
+		})
+	}
+}
+
+// requestRecorder 记录最后一次查找目标表的请求
+type requestRecorder struct {
+	sharding.Algorithm
+	req sharding.Request
+}
+
+func (r *requestRecorder) Sharding(ctx context.Context, req sharding.Request) (sharding.Response, error) {
+	r.req = req
+	return r.Algorithm.Sharding(ctx, req)
+}
+
+func TestShardingBuilder_RangeDst(t *testing.T) {
+	dsPattern := "0.db.cluster.company.com:3306"
+	dst := func(tbl string) sharding.Dst {
+		return sharding.Dst{Name: dsPattern, DB: "order_db", Table: tbl}
+	}
+	rg, err := ranges.NewRange("OrderId",
+		ranges.Interval{Start: 0, End: 100, Dst: dst("order_tab_0")},
+		ranges.Interval{Start: 100, End: 200, Dst: dst("order_tab_1")},
+		ranges.Interval{Start: 200, End: 300, Dst: dst("order_tab_2")},
+	)
+	require.NoError(t, err)
+	recorder := &requestRecorder{Algorithm: &hash.Hash{
+		ShardingKey:  "Content",
+		DBPattern:    &hash.Pattern{Name: "order_db", NotSharding: true},
+		TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+		DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+	}}
+	r := model.NewMetaRegistry()
+	rangeMeta, err := r.Register(&Order{}, model.WithTableShardingAlgorithm(rg))
+	require.NoError(t, err)
+	// LIKE 只能按照字符串类型的 sharding key 查找，所以使用 Order 的 Content
+	hashMeta, err := model.NewMetaRegistry().Register(&Order{}, model.WithTableShardingAlgorithm(recorder))
+	require.NoError(t, err)
+	all := []sharding.Dst{dst("order_tab_0"), dst("order_tab_1"), dst("order_tab_2")}
+
+	testCases := []struct {
+		name    string
+		meta    *model.TableMeta
+		pre     Predicate
+		wantReq sharding.Request
+		wantRes []sharding.Dst
+		wantErr error
+	}{
+		{
+			name:    "between",
+			meta:    rangeMeta,
+			pre:     C("OrderId").Between(50, 150),
+			wantRes: []sharding.Dst{dst("order_tab_0"), dst("order_tab_1")},
+		},
+		{
+			name:    "or between",
+			meta:    rangeMeta,
+			pre:     C("OrderId").Between(10, 20).Or(C("OrderId").Between(250, 260)),
+			wantRes: []sharding.Dst{dst("order_tab_0"), dst("order_tab_2")},
+		},
+		{
+			name:    "and between",
+			meta:    rangeMeta,
+			pre:     C("OrderId").Between(10, 150).And(C("OrderId").GTEQ(120)),
+			wantRes: []sharding.Dst{dst("order_tab_1")},
+		},
+		{
+			name:    "not between",
+			meta:    rangeMeta,
+			pre:     C("OrderId").NotBetween(100, 199),
+			wantRes: all,
+		},
+		{
+			name:    "negate between",
+			meta:    rangeMeta,
+			pre:     Not(C("OrderId").Between(100, 199)),
+			wantRes: all,
+		},
+		{
+			name:    "between expression",
+			meta:    rangeMeta,
+			pre:     C("OrderId").Between(C("UserId"), 150),
+			wantErr: errs.ErrUnsupportedTooComplexQuery,
+		},
+		{
+			name:    "like prefix",
+			meta:    hashMeta,
+			pre:     C("Content").Like("abc%"),
+			wantReq: sharding.Request{Op: opBetween, SkValues: map[string]any{"Content": sharding.Range{Start: "abc", End: "abd", ExclusiveEnd: true}}},
+			wantRes: all,
+		},
+		{
+			name:    "like escape",
+			meta:    hashMeta,
+			pre:     C("Content").Like("a\\_b_"),
+			wantReq: sharding.Request{Op: opBetween, SkValues: map[string]any{"Content": sharding.Range{Start: "a_b", End: "a_c", ExclusiveEnd: true}}},
+			wantRes: all,
+		},
+		{
+			name:    "like without wildcard",
+			meta:    hashMeta,
+			pre:     C("Content").Like("abc"),
+			wantReq: sharding.Request{Op: opEQ, SkValues: map[string]any{"Content": "abc"}},
+			wantRes: []sharding.Dst{dst("order_tab_0")},
+		},
+		{
+			name:    "like suffix",
+			meta:    hashMeta,
+			pre:     C("Content").Like("%abc"),
+			wantRes: all,
+		},
+		{
+			name:    "not like",
+			meta:    hashMeta,
+			pre:     C("Content").NotLike("abc%"),
+			wantRes: all,
+		},
+		{
+			name:    "like not string",
+			meta:    rangeMeta,
+			pre:     C("OrderId").Like("12%"),
+			wantRes: all,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder.req = sharding.Request{}
+			b := shardingBuilder{}
+			b.metaRegistry = r
+			b.meta = tc.meta
+			res, err := b.findDstByPredicate(context.Background(), tc.pre)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.ElementsMatch(t, tc.wantRes, res.Dsts)
+			if tc.meta == hashMeta {
+				assert.Equal(t, tc.wantReq, recorder.req)
+			}
+		})
+	}
+}
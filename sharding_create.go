@@ -0,0 +1,252 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/dialect"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/valyala/bytebufferpool"
+)
+
+// ShardingCreator 根据模型的元数据创建分库分表之后的全部物理库和物理表，
+// 目标是 ShardingAlgorithm.Broadcast 返回的全部结果。
+// 库和表已经存在的时候会跳过，所以可以重复执行，例如扩容之后再执行一次
+type ShardingCreator[T any] struct {
+	shardingBuilder
+	db Session
+}
+
+// NewShardingCreator 开始构建分库分表的建库建表语句
+func NewShardingCreator[T any](sess Session) *ShardingCreator[T] {
+	b := shardingBuilder{}
+	b.core = sess.getCore()
+	b.buffer = bytebufferpool.Get()
+	return &ShardingCreator[T]{
+		shardingBuilder: b,
+		db:              sess,
+	}
+}
+
+// Build 返回需要执行的 CREATE DATABASE 和 CREATE TABLE 语句，但是不会执行，也就是 dry-run。
+// 同一个库的 CREATE DATABASE 排在它的 CREATE TABLE 前面。
+// SQLite 的库是单独的文件，需要自己 ATTACH，所以只会生成 CREATE TABLE
+func (c *ShardingCreator[T]) Build(ctx context.Context) ([]sharding.Query, error) {
+	_, qs, err := c.build(ctx)
+	return qs, err
+}
+
+func (c *ShardingCreator[T]) build(ctx context.Context) ([]sharding.Dst, []sharding.Query, error) {
+	var err error
+	if c.meta == nil {
+		c.meta, err = c.metaRegistry.Get(new(T))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.meta.ShardingAlgorithm == nil {
+		return nil, nil, errs.ErrMissingShardingAlgorithm
+	}
+	defer bytebufferpool.Put(c.buffer)
+	if err = c.buildColumnDefs(); err != nil {
+		return nil, nil, err
+	}
+	defs := c.buffer.String()
+	c.buffer.Reset()
+
+	dsts := c.meta.ShardingAlgorithm.Broadcast(ctx)
+	res := make([]sharding.Query, 0, len(dsts)+4)
+	type dsDB struct {
+		ds string
+		db string
+	}
+	dbs := make(map[dsDB]struct{}, 4)
+	for _, dst := range dsts {
+		key := dsDB{ds: dst.Name, db: dst.DB}
+		if _, ok := dbs[key]; !ok && c.dialect != dialect.SQLite {
+			dbs[key] = struct{}{}
+			c.writeString("CREATE DATABASE IF NOT EXISTS ")
+			c.quote(dst.DB)
+			res = append(res, c.endQuery(dst))
+		}
+		c.writeString("CREATE TABLE IF NOT EXISTS ")
+		c.quote(dst.DB)
+		c.point()
+		c.quote(dst.Table)
+		c.writeString(defs)
+		res = append(res, c.endQuery(dst))
+	}
+	return dsts, res, nil
+}
+
+func (c *ShardingCreator[T]) endQuery(dst sharding.Dst) sharding.Query {
+	c.end()
+	q := sharding.Query{SQL: c.buffer.String(), DB: dst.DB, Datasource: dst.Name}
+	c.buffer.Reset()
+	return q
+}
+
+// buildColumnDefs 构造每张表都一样的列定义和主键部分
+func (c *ShardingCreator[T]) buildColumnDefs() error {
+	c.writeByte('(')
+	pks := make([]string, 0, 1)
+	for i, col := range c.meta.Columns {
+		if i > 0 {
+			c.comma()
+		}
+		typ, nullable, err := c.columnType(col)
+		if err != nil {
+			return err
+		}
+		c.quote(col.ColumnName)
+		c.space()
+		c.writeString(typ)
+		if !nullable {
+			c.writeString(" NOT NULL")
+		}
+		if col.IsPrimaryKey {
+			pks = append(pks, col.ColumnName)
+		}
+	}
+	if len(pks) > 0 {
+		c.writeString(",PRIMARY KEY (")
+		for i, pk := range pks {
+			if i > 0 {
+				c.comma()
+			}
+			c.quote(pk)
+		}
+		c.writeByte(')')
+	}
+	c.writeByte(')')
+	return nil
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	bytesType   = reflect.TypeOf([]byte(nil))
+	nullTypeMap = map[reflect.Type]reflect.Type{
+		reflect.TypeOf(sql.NullString{}):  reflect.TypeOf(""),
+		reflect.TypeOf(sql.NullBool{}):    reflect.TypeOf(false),
+		reflect.TypeOf(sql.NullByte{}):    reflect.TypeOf(byte(0)),
+		reflect.TypeOf(sql.NullInt16{}):   reflect.TypeOf(int16(0)),
+		reflect.TypeOf(sql.NullInt32{}):   reflect.TypeOf(int32(0)),
+		reflect.TypeOf(sql.NullInt64{}):   reflect.TypeOf(int64(0)),
+		reflect.TypeOf(sql.NullFloat64{}): reflect.TypeOf(float64(0)),
+		reflect.TypeOf(sql.NullTime{}):    timeType,
+	}
+)
+
+// columnType 返回字段对应的列类型，指针和 sql.NullXXX 类型的列可以为 NULL，主键除外
+func (c *ShardingCreator[T]) columnType(col *model.ColumnMeta) (string, bool, error) {
+	typ := col.Typ
+	nullable := false
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+		nullable = true
+	}
+	if t, ok := nullTypeMap[typ]; ok {
+		typ = t
+		nullable = true
+	}
+	nullable = nullable && !col.IsPrimaryKey
+	var res string
+	if c.dialect == dialect.SQLite {
+		res = sqliteColumnType(typ)
+	} else {
+		res = mysqlColumnType(typ)
+	}
+	if res == "" {
+		return "", false, errs.NewErrUnsupportedColumnType(col.FieldName, col.Typ)
+	}
+	return res, nullable, nil
+}
+
+func mysqlColumnType(typ reflect.Type) string {
+	switch typ {
+	case timeType:
+		return "DATETIME"
+	case bytesType:
+		return "BLOB"
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "BOOLEAN"
+	case reflect.Int8:
+		return "TINYINT"
+	case reflect.Int16:
+		return "SMALLINT"
+	case reflect.Int32:
+		return "INT"
+	case reflect.Int, reflect.Int64:
+		return "BIGINT"
+	case reflect.Uint8:
+		return "TINYINT UNSIGNED"
+	case reflect.Uint16:
+		return "SMALLINT UNSIGNED"
+	case reflect.Uint32:
+		return "INT UNSIGNED"
+	case reflect.Uint, reflect.Uint64:
+		return "BIGINT UNSIGNED"
+	case reflect.Float32:
+		return "FLOAT"
+	case reflect.Float64:
+		return "DOUBLE"
+	case reflect.String:
+		return "VARCHAR(255)"
+	default:
+		return ""
+	}
+}
+
+func sqliteColumnType(typ reflect.Type) string {
+	switch typ {
+	case timeType:
+		return "DATETIME"
+	case bytesType:
+		return "BLOB"
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "INTEGER"
+	case reflect.Float32, reflect.Float64:
+		return "REAL"
+	case reflect.String:
+		return "TEXT"
+	default:
+		return ""
+	}
+}
+
+// Exec 依次执行 Build 返回的语句，遇到错误就停止。
+// 已经执行成功的语句不会回滚，修正问题之后重新执行即可
+func (c *ShardingCreator[T]) Exec(ctx context.Context) sharding.Result {
+	dsts, qs, err := c.build(ctx)
+	if err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if err = c.checkShadowWrite(ctx, dsts); err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	res, err := execQueries(ctx, c.db, qs)
+	return sharding.NewResult(res, err)
+}
@@ -0,0 +1,228 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/datasource/single"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ShardingUser struct {
+	Id       int64 `eorm:"primary_key"`
+	TenantId int
+	Name     string
+	Nickname *string
+	Age      sql.NullInt32
+	Avatar   []byte
+	Active   bool
+	Balance  float64
+	Ctime    time.Time
+}
+
+type ShardingTagUser struct {
+	Id   int64 `eorm:"primary_key"`
+	Tags map[string]string
+}
+
+func TestShardingCreator_Build(t *testing.T) {
+	newHash := func() *hash.Hash {
+		return &hash.Hash{
+			ShardingKey:  "TenantId",
+			DBPattern:    &hash.Pattern{Name: "user_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "user_tab_%d", Base: 2},
+			DsPattern:    &hash.Pattern{Name: "ds", NotSharding: true},
+		}
+	}
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&ShardingUser{}, model.WithTableShardingAlgorithm(newHash()))
+	require.NoError(t, err)
+	_, err = r.Register(&ShardingTagUser{}, model.WithTableShardingAlgorithm(newHash()))
+	require.NoError(t, err)
+	shadowRegistry := model.NewMetaRegistry()
+	_, err = shadowRegistry.Register(&ShardingUser{},
+		model.WithTableShardingAlgorithm(&sharding.ShadowAlgorithm{Algorithm: newHash()}))
+	require.NoError(t, err)
+
+	ds := shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+		"ds": MasterSlavesMemoryDB(),
+	})
+	mysqlDB, err := OpenDS("mysql", ds, DBWithMetaRegistry(r))
+	require.NoError(t, err)
+	sqliteDB, err := OpenDS("sqlite3", ds, DBWithMetaRegistry(r))
+	require.NoError(t, err)
+	shadowDB, err := OpenDS("mysql", ds, DBWithMetaRegistry(shadowRegistry))
+	require.NoError(t, err)
+
+	mysqlDefs := "(`id` BIGINT NOT NULL,`tenant_id` BIGINT NOT NULL,`name` VARCHAR(255) NOT NULL," +
+		"`nickname` VARCHAR(255),`age` INT,`avatar` BLOB NOT NULL,`active` BOOLEAN NOT NULL," +
+		"`balance` DOUBLE NOT NULL,`ctime` DATETIME NOT NULL,PRIMARY KEY (`id`));"
+	sqliteDefs := "(`id` INTEGER NOT NULL,`tenant_id` INTEGER NOT NULL,`name` TEXT NOT NULL," +
+		"`nickname` TEXT,`age` INTEGER,`avatar` BLOB NOT NULL,`active` INTEGER NOT NULL," +
+		"`balance` REAL NOT NULL,`ctime` DATETIME NOT NULL,PRIMARY KEY (`id`));"
+	createDB := func(db string) sharding.Query {
+		return sharding.Query{
+			SQL:        fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`;", db),
+			DB:         db,
+			Datasource: "ds",
+		}
+	}
+	createTable := func(db, tbl, defs string) sharding.Query {
+		return sharding.Query{
+			SQL:        fmt.Sprintf("CREATE TABLE IF NOT EXISTS `%s`.`%s`%s", db, tbl, defs),
+			DB:         db,
+			Datasource: "ds",
+		}
+	}
+
+	testCases := []struct {
+		name    string
+		ctx     context.Context
+		builder sharding.QueryBuilder
+		wantQs  []sharding.Query
+		wantErr error
+	}{
+		{
+			name:    "mysql",
+			ctx:     context.Background(),
+			builder: NewShardingCreator[ShardingUser](mysqlDB),
+			wantQs: []sharding.Query{
+				createDB("user_db_0"),
+				createTable("user_db_0", "user_tab_0", mysqlDefs),
+				createTable("user_db_0", "user_tab_1", mysqlDefs),
+				createDB("user_db_1"),
+				createTable("user_db_1", "user_tab_0", mysqlDefs),
+				createTable("user_db_1", "user_tab_1", mysqlDefs),
+			},
+		},
+		{
+			name:    "sqlite",
+			ctx:     context.Background(),
+			builder: NewShardingCreator[ShardingUser](sqliteDB),
+			wantQs: []sharding.Query{
+				createTable("user_db_0", "user_tab_0", sqliteDefs),
+				createTable("user_db_0", "user_tab_1", sqliteDefs),
+				createTable("user_db_1", "user_tab_0", sqliteDefs),
+				createTable("user_db_1", "user_tab_1", sqliteDefs),
+			},
+		},
+		{
+			name:    "shadow",
+			ctx:     sharding.CtxWithShadow(context.Background(), sharding.ShadowDB),
+			builder: NewShardingCreator[ShardingUser](shadowDB),
+			wantQs: []sharding.Query{
+				createDB("shadow_user_db_0"),
+				createTable("shadow_user_db_0", "user_tab_0", mysqlDefs),
+				createTable("shadow_user_db_0", "user_tab_1", mysqlDefs),
+				createDB("shadow_user_db_1"),
+				createTable("shadow_user_db_1", "user_tab_0", mysqlDefs),
+				createTable("shadow_user_db_1", "user_tab_1", mysqlDefs),
+			},
+		},
+		{
+			name:    "unsupported column type",
+			ctx:     context.Background(),
+			builder: NewShardingCreator[ShardingTagUser](mysqlDB),
+			wantErr: errs.NewErrUnsupportedColumnType("Tags", reflect.TypeOf(map[string]string{})),
+		},
+		{
+			name:    "missing sharding algorithm",
+			ctx:     context.Background(),
+			builder: NewShardingCreator[TestModel](mysqlDB),
+			wantErr: errs.ErrMissingShardingAlgorithm,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(tc.ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQs, qs)
+		})
+	}
+}
+
+func TestShardingCreator_Exec(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&TenantUser{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "TenantId",
+			DBPattern:    &hash.Pattern{Name: "user_db", NotSharding: true},
+			TablePattern: &hash.Pattern{Name: "user_tab_%d", Base: 2},
+			DsPattern:    &hash.Pattern{Name: "ds", NotSharding: true},
+		}))
+	require.NoError(t, err)
+	mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	db, err := OpenDS("mysql", shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+		"ds": single.NewDB(mockDB),
+	}), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	defs := "(`id` BIGINT NOT NULL,`tenant_id` BIGINT NOT NULL,`name` VARCHAR(255) NOT NULL,PRIMARY KEY (`id`));"
+	testCases := []struct {
+		name     string
+		mockExec func(mock sqlmock.Sqlmock)
+		wantErr  error
+	}{
+		{
+			name: "success",
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("CREATE DATABASE IF NOT EXISTS `user_db`;").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS `user_db`.`user_tab_0`" + defs).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS `user_db`.`user_tab_1`" + defs).
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+		},
+		{
+			name: "failed",
+			mockExec: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("CREATE DATABASE IF NOT EXISTS `user_db`;").
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("CREATE TABLE IF NOT EXISTS `user_db`.`user_tab_0`" + defs).
+					WillReturnError(errors.New("access denied"))
+			},
+			wantErr: errors.New("access denied"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockExec(mock)
+			res := NewShardingCreator[TenantUser](db).Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
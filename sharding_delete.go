@@ -0,0 +1,119 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/valyala/bytebufferpool"
+)
+
+var _ sharding.Executor = &ShardingDeleter[any]{}
+var _ sharding.QueryBuilder = &ShardingDeleter[any]{}
+
+type ShardingDeleter[T any] struct {
+	shardingDeleterBuilder
+	db Session
+	// bestEffort 表示部分目标表删除失败的时候，不影响其它目标表
+	bestEffort bool
+}
+
+// NewShardingDeleter 开始构建一个 Sharding DELETE 查询
+func NewShardingDeleter[T any](sess Session) *ShardingDeleter[T] {
+	b := shardingDeleterBuilder{}
+	b.core = sess.getCore()
+	b.buffer = bytebufferpool.Get()
+	return &ShardingDeleter[T]{
+		shardingDeleterBuilder: b,
+		db:                     sess,
+	}
+}
+
+// Where accepts predicates
+func (d *ShardingDeleter[T]) Where(predicates ...Predicate) *ShardingDeleter[T] {
+	d.where = predicates
+	return d
+}
+
+// Build returns DELETE []sharding.Query
+func (d *ShardingDeleter[T]) Build(ctx context.Context) ([]sharding.Query, error) {
+	_, qs, err := d.build(ctx)
+	return qs, err
+}
+
+func (d *ShardingDeleter[T]) build(ctx context.Context) ([]sharding.Dst, []sharding.Query, error) {
+	var err error
+	if d.meta == nil {
+		d.meta, err = d.metaRegistry.Get(new(T))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	shardingRes, err := d.findDst(ctx, d.where...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := make([]sharding.Query, 0, len(shardingRes.Dsts))
+	defer bytebufferpool.Put(d.buffer)
+	for _, dst := range shardingRes.Dsts {
+		q, err := d.buildQuery(dst.DB, dst.Table, dst.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, q)
+		d.args = nil
+		d.buffer.Reset()
+	}
+	return shardingRes.Dsts, res, nil
+}
+
+func (d *ShardingDeleter[T]) buildQuery(db, tbl, ds string) (sharding.Query, error) {
+	d.writeString("DELETE FROM ")
+	d.quote(db)
+	d.writeByte('.')
+	d.quote(tbl)
+	if len(d.where) > 0 {
+		d.writeString(" WHERE ")
+		if err := d.buildPredicates(d.where); err != nil {
+			return sharding.EmptyQuery, err
+		}
+	}
+	d.end()
+	return sharding.Query{SQL: d.buffer.String(), Args: d.args, Datasource: ds, DB: db}, nil
+}
+
+// BestEffort 开启 best effort 模式。
+// 默认情况下，只要有一个目标表删除失败，就会取消其它目标表上的删除；
+// 开启之后会在全部目标表上执行，Result 里面包含成功的结果和 *sharding.PartialError
+func (d *ShardingDeleter[T]) BestEffort() *ShardingDeleter[T] {
+	d.bestEffort = true
+	return d
+}
+
+func (d *ShardingDeleter[T]) Exec(ctx context.Context) sharding.Result {
+	dsts, qs, err := d.build(ctx)
+	if err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if err = d.checkShadowWrite(ctx, dsts); err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if d.meta.BroadcastTable {
+		return execBroadcast(ctx, d.db, qs)
+	}
+	return execAll(ctx, d.db, d.maxParallelism, dsts, qs, d.bestEffort)
+}
@@ -0,0 +1,283 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/multierr"
+)
+
+func TestShardingDeleter_Build(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dbBase, tableBase := 2, 3
+	orderDBPattern, orderTablePattern := "order_db_%d", "order_tab_%d"
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: orderDBPattern, Base: dbBase},
+			TablePattern: &hash.Pattern{Name: orderTablePattern, Base: tableBase},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	clusterDB := cluster.NewClusterDB(m)
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+	testCases := []struct {
+		name    string
+		builder sharding.QueryBuilder
+		wantQs  []sharding.Query
+		wantErr error
+	}{
+		{
+			name:    "where eq",
+			builder: NewShardingDeleter[Order](shardingDB).Where(C("UserId").EQ(1)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_db_1`.`order_tab_1` WHERE `user_id`=?;",
+					Args:       []any{1},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name:    "not where",
+			builder: NewShardingDeleter[Order](shardingDB),
+			wantQs: func() []sharding.Query {
+				var res []sharding.Query
+				sql := "DELETE FROM `%s`.`%s`;"
+				for i := 0; i < dbBase; i++ {
+					dbName := fmt.Sprintf(orderDBPattern, i)
+					for j := 0; j < tableBase; j++ {
+						tableName := fmt.Sprintf(orderTablePattern, j)
+						res = append(res, sharding.Query{
+							SQL:        fmt.Sprintf(sql, dbName, tableName),
+							DB:         dbName,
+							Datasource: dsPattern,
+						})
+					}
+				}
+				return res
+			}(),
+		},
+		{
+			name: "where or",
+			builder: NewShardingDeleter[Order](shardingDB).
+				Where(C("UserId").EQ(123).Or(C("UserId").EQ(234))),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);",
+					Args:       []any{123, 234},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "DELETE FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);",
+					Args:       []any{123, 234},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "where and empty",
+			builder: NewShardingDeleter[Order](shardingDB).
+				Where(C("UserId").EQ(123).And(C("UserId").EQ(234))),
+			wantQs: []sharding.Query{},
+		},
+		{
+			name: "where in",
+			builder: NewShardingDeleter[Order](shardingDB).
+				Where(C("UserId").In(1, 2)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_db_1`.`order_tab_1` WHERE `user_id` IN (?,?);",
+					Args:       []any{1, 2},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "DELETE FROM `order_db_0`.`order_tab_2` WHERE `user_id` IN (?,?);",
+					Args:       []any{1, 2},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name:    "invalid field err",
+			builder: NewShardingDeleter[Order](shardingDB).Where(C("ccc").EQ(1)),
+			wantErr: errs.NewInvalidFieldError("ccc"),
+		},
+		{
+			name:    "pointer only err",
+			builder: NewShardingDeleter[int64](shardingDB).Where(C("UserId").EQ(1)),
+			wantErr: errs.ErrPointerOnly,
+		},
+		{
+			name:    "too complex operator",
+			builder: NewShardingDeleter[Order](shardingDB).Where(Exist(NewSelector[Order](shardingDB).AsSubquery("sub"))),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(context.Background())
+			require.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.ElementsMatch(t, tc.wantQs, qs)
+		})
+	}
+}
+
+type ShardingDeleterSuite struct {
+	suite.Suite
+	mock01   sqlmock.Sqlmock
+	mockDB01 *sql.DB
+	mock02   sqlmock.Sqlmock
+	mockDB02 *sql.DB
+}
+
+func (s *ShardingDeleterSuite) SetupSuite() {
+	t := s.T()
+	var err error
+	s.mockDB01, s.mock01, err = sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mockDB02, s.mock02, err = sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (s *ShardingDeleterSuite) TearDownTest() {
+	_ = s.mockDB01.Close()
+	_ = s.mockDB02.Close()
+}
+
+func (s *ShardingDeleterSuite) TestShardingDeleter_Exec() {
+	t := s.T()
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMockDB(s.mockDB01),
+		"order_db_1": MasterSlavesMockDB(s.mockDB02),
+	}
+	clusterDB := cluster.NewClusterDB(m)
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+	testCases := []struct {
+		name             string
+		exec             sharding.Executor
+		mockDB           func()
+		wantAffectedRows int64
+		wantErr          error
+	}{
+		{
+			name:    "invalid field err",
+			exec:    NewShardingDeleter[Order](shardingDB).Where(C("ccc").EQ(1)),
+			mockDB:  func() {},
+			wantErr: errs.NewInvalidFieldError("ccc"),
+		},
+		{
+			name: "delete fail",
+			exec: NewShardingDeleter[Order](shardingDB).Where(C("UserId").EQ(1)),
+			mockDB: func() {
+				s.mock02.ExpectExec(regexp.QuoteMeta("DELETE FROM `order_db_1`.`order_tab_1` WHERE `user_id`=?;")).
+					WithArgs(1).WillReturnError(newMockErr("db"))
+			},
+			wantErr: multierr.Combine(newMockErr("db")),
+		},
+		{
+			name: "where eq",
+			exec: NewShardingDeleter[Order](shardingDB).Where(C("UserId").EQ(1)),
+			mockDB: func() {
+				s.mock02.ExpectExec(regexp.QuoteMeta("DELETE FROM `order_db_1`.`order_tab_1` WHERE `user_id`=?;")).
+					WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			wantAffectedRows: 1,
+		},
+		{
+			name: "where or",
+			exec: NewShardingDeleter[Order](shardingDB).
+				Where(C("UserId").EQ(123).Or(C("UserId").EQ(234))),
+			mockDB: func() {
+				s.mock02.ExpectExec(regexp.QuoteMeta("DELETE FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);")).
+					WithArgs(123, 234).WillReturnResult(sqlmock.NewResult(0, 2))
+				s.mock01.ExpectExec(regexp.QuoteMeta("DELETE FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);")).
+					WithArgs(123, 234).WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			wantAffectedRows: 5,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockDB()
+			res := tc.exec.Exec(context.Background())
+			require.Equal(t, tc.wantErr, res.Err())
+			if res.Err() != nil {
+				return
+			}
+
+			affectRows, err := res.RowsAffected()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAffectedRows, affectRows)
+		})
+	}
+}
+
+func TestShardingDeleterSuite(t *testing.T) {
+	suite.Run(t, &ShardingDeleterSuite{})
+}
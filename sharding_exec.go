@@ -0,0 +1,176 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// scatter 并发执行 n 个任务，同时执行的任务不超过 limit 个，limit 小于等于 0 表示不限制。
+// bestEffort 为 false 的时候，第一个任务失败之后就调用 cancel 取消其它正在执行的任务，
+// 并且不再启动剩下的任务；为 true 的时候会执行全部任务。
+// errList 和任务一一对应，err 是第一个发生的错误
+func scatter(ctx context.Context, cancel context.CancelFunc, limit int, n int, bestEffort bool,
+	task func(ctx context.Context, idx int) error) (errList []error, err error) {
+	errList = make([]error, n)
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		sem  chan struct{}
+	)
+	fail := func(er error) {
+		once.Do(func() {
+			err = er
+			if !bestEffort {
+				cancel()
+			}
+		})
+	}
+	if limit > 0 && limit < n {
+		sem = make(chan struct{}, limit)
+	}
+	for i := 0; i < n; i++ {
+		if sem != nil {
+			sem <- struct{}{}
+		}
+		if !bestEffort && ctx.Err() != nil {
+			// 已经有任务失败了，或者用户取消了 ctx，剩下的任务不需要再执行
+			fail(ctx.Err())
+			break
+		}
+		wg.Add(1)
+		go func(idx int) {
+			defer func() {
+				if sem != nil {
+					<-sem
+				}
+				wg.Done()
+			}()
+			if er := task(ctx, idx); er != nil {
+				errList[idx] = er
+				fail(er)
+			}
+		}(i)
+	}
+	wg.Wait()
+	return errList, err
+}
+
+// queryAll 在每个目标上执行查询，rowsList 和 qs 一一对应。
+// bestEffort 为 false 的时候，只要有一个查询失败，就会取消其它查询，关闭已经拿到的结果集并且返回错误；
+// 为 true 的时候失败的查询对应的结果集是 nil，errList 里面记录了失败的原因
+func queryAll(ctx context.Context, sess Session, limit int, qs []Query,
+	bestEffort bool) (rowsList []rows.Rows, errList []error, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	rowsList = make([]rows.Rows, len(qs))
+	errList, err = scatter(ctx, cancel, limit, len(qs), bestEffort, func(ctx context.Context, idx int) error {
+		rs, er := sess.queryContext(ctx, qs[idx])
+		if er != nil {
+			return er
+		}
+		rowsList[idx] = rs
+		return nil
+	})
+	if err != nil && !bestEffort {
+		cancel()
+		for _, rs := range rowsList {
+			if rs != nil {
+				_ = rs.Close()
+			}
+		}
+		return nil, nil, err
+	}
+	return releaseOnClose(rowsList, cancel), errList, nil
+}
+
+// releaseOnClose 在全部结果集都关闭之后再调用 cancel。
+// 结果集在 ctx 被取消的时候会被关闭，所以查询成功之后不能立刻取消 ctx
+func releaseOnClose(rowsList []rows.Rows, cancel context.CancelFunc) []rows.Rows {
+	var cnt int32
+	for _, rs := range rowsList {
+		if rs != nil {
+			cnt++
+		}
+	}
+	if cnt == 0 {
+		cancel()
+		return rowsList
+	}
+	release := func() {
+		if atomic.AddInt32(&cnt, -1) == 0 {
+			cancel()
+		}
+	}
+	res := make([]rows.Rows, len(rowsList))
+	for i, rs := range rowsList {
+		if rs != nil {
+			res[i] = &cancelRows{Rows: rs, release: release}
+		}
+	}
+	return res
+}
+
+type cancelRows struct {
+	rows.Rows
+	once    sync.Once
+	release func()
+}
+
+func (r *cancelRows) Close() error {
+	err := r.Rows.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// execAll 在每个目标表上执行 qs，dsts 和 qs 一一对应。
+// bestEffort 为 true 的时候，返回执行成功的结果，失败的目标表记录在 *sharding.PartialError 里面
+func execAll(ctx context.Context, sess Session, limit int, dsts []sharding.Dst, qs []Query,
+	bestEffort bool) sharding.Result {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resList := make([]sql.Result, len(qs))
+	errList, err := scatter(ctx, cancel, limit, len(qs), bestEffort, func(ctx context.Context, idx int) error {
+		res, er := sess.execContext(ctx, qs[idx])
+		if er != nil {
+			return er
+		}
+		resList[idx] = res
+		return nil
+	})
+	if bestEffort {
+		return sharding.NewResult(resList, newPartialError(dsts, errList))
+	}
+	return sharding.NewResult(resList, err)
+}
+
+// newPartialError 把每个目标表的错误整理成 *sharding.PartialError，全部成功的时候返回 nil
+func newPartialError(dsts []sharding.Dst, errList []error) error {
+	var res []sharding.DstError
+	for i, err := range errList {
+		if err != nil {
+			res = append(res, sharding.DstError{Dst: dsts[i], Err: err})
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return &sharding.PartialError{Errs: res}
+}
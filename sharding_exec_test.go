@@ -0,0 +1,95 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScatter(t *testing.T) {
+	mockErr := errors.New("mock error")
+	testCases := []struct {
+		name       string
+		limit      int
+		bestEffort bool
+		// failIdx 是会失败的任务
+		failIdx int
+
+		wantErr error
+		// wantMaxRunning 是同时执行的任务数量的最大值
+		wantMaxRunning int32
+		wantExecuted   int32
+	}{
+		{
+			name:           "limit",
+			limit:          2,
+			failIdx:        -1,
+			wantMaxRunning: 2,
+			wantExecuted:   6,
+		},
+		{
+			name:           "fail fast",
+			limit:          1,
+			failIdx:        1,
+			wantErr:        mockErr,
+			wantMaxRunning: 1,
+			wantExecuted:   2,
+		},
+		{
+			name:           "best effort",
+			limit:          1,
+			bestEffort:     true,
+			failIdx:        1,
+			wantErr:        mockErr,
+			wantMaxRunning: 1,
+			wantExecuted:   6,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var running, maxRunning, executed int32
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			errList, err := scatter(ctx, cancel, tc.limit, 6, tc.bestEffort, func(ctx context.Context, idx int) error {
+				atomic.AddInt32(&executed, 1)
+				cnt := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					old := atomic.LoadInt32(&maxRunning)
+					if cnt <= old || atomic.CompareAndSwapInt32(&maxRunning, old, cnt) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond * 10)
+				if idx == tc.failIdx {
+					return mockErr
+				}
+				return nil
+			})
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantMaxRunning, maxRunning)
+			assert.Equal(t, tc.wantExecuted, executed)
+			if tc.failIdx >= 0 {
+				assert.Equal(t, mockErr, errList[tc.failIdx])
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/valyala/bytebufferpool"
+)
+
+// ShardingExplain 是分库分表查询的路由结果
+type ShardingExplain struct {
+	// Dsts 是最终命中的目标表
+	Dsts []sharding.Dst
+	// Hint 表示目标表是通过 sharding.CtxWithDst 指定的，和查询条件无关
+	Hint bool
+	// Broadcast 表示命中了全部的目标表
+	Broadcast bool
+	// Predicates 是每一个查询条件单独的路由结果
+	Predicates []PredicateExplain
+	// Queries 是每一个目标表上执行的 SQL，和 Build 的结果一样
+	Queries []sharding.Query
+}
+
+// PredicateExplain 是单个查询条件的路由结果
+type PredicateExplain struct {
+	Predicate Predicate
+	Dsts      []sharding.Dst
+	// Broadcast 表示这个查询条件无法缩小目标表的范围，
+	// 例如查询条件里面没有 sharding key，或者使用了 != 之类的操作符
+	Broadcast bool
+}
+
+// explainBuffer 给 Explain 换一个单独的 buffer，返回的函数用于恢复原本的 buffer。
+// build 结束的时候会把 buffer 放回池子，这样 Explain 之后还可以继续使用原本的 buffer 执行
+func (b *shardingBuilder) explainBuffer() func() {
+	buffer := b.buffer
+	b.buffer = bytebufferpool.Get()
+	return func() {
+		b.buffer = buffer
+	}
+}
+
+// explain 计算每个查询条件单独的路由结果
+func (b *shardingBuilder) explain(ctx context.Context, dsts []sharding.Dst,
+	qs []sharding.Query, predicates []Predicate) (ShardingExplain, error) {
+	all := b.meta.ShardingAlgorithm.Broadcast(ctx)
+	_, hint := sharding.DstFromCtx(ctx)
+	res := ShardingExplain{
+		Dsts:      dsts,
+		Hint:      hint,
+		Broadcast: len(dsts) == len(all),
+		Queries:   qs,
+	}
+	if len(predicates) > 0 {
+		res.Predicates = make([]PredicateExplain, 0, len(predicates))
+	}
+	for _, p := range predicates {
+		pr, err := b.findDstByPredicate(ctx, p)
+		if err != nil {
+			return ShardingExplain{}, err
+		}
+		res.Predicates = append(res.Predicates, PredicateExplain{
+			Predicate: p,
+			Dsts:      pr.Dsts,
+			Broadcast: len(pr.Dsts) == len(all),
+		})
+	}
+	return res, nil
+}
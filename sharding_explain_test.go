@@ -0,0 +1,269 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardingExplain(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	dst := func(db, tbl int) sharding.Dst {
+		return sharding.Dst{
+			Name:  dsPattern,
+			DB:    fmt.Sprintf("order_db_%d", db),
+			Table: fmt.Sprintf("order_tab_%d", tbl),
+		}
+	}
+	var all []sharding.Dst
+	for i := 0; i < 2; i++ {
+		for j := 0; j < 3; j++ {
+			all = append(all, dst(i, j))
+		}
+	}
+	byUser := C("UserId").EQ(123)
+	byContent := C("Content").EQ("hello")
+	byUsers := C("UserId").In(1, 2)
+
+	testCases := []struct {
+		name        string
+		ctx         context.Context
+		explain     func(ctx context.Context) (ShardingExplain, error)
+		wantExplain ShardingExplain
+		wantErr     error
+	}{
+		{
+			name: "select",
+			ctx:  context.Background(),
+			explain: NewShardingSelector[Order](shardingDB).
+				Select(C("Content")).Where(byUser, byContent).Explain,
+			wantExplain: ShardingExplain{
+				Dsts: []sharding.Dst{dst(1, 0)},
+				Predicates: []PredicateExplain{
+					{Predicate: byUser, Dsts: []sharding.Dst{dst(1, 0)}},
+					{Predicate: byContent, Dsts: all, Broadcast: true},
+				},
+				Queries: []sharding.Query{
+					{
+						SQL:        "SELECT `content` FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) AND (`content`=?);",
+						Args:       []any{123, "hello"},
+						DB:         "order_db_1",
+						Datasource: dsPattern,
+					},
+				},
+			},
+		},
+		{
+			name: "select broadcast",
+			ctx:  context.Background(),
+			explain: NewShardingSelector[Order](shardingDB).
+				Select(C("Content")).Where(byContent).Explain,
+			wantExplain: ShardingExplain{
+				Dsts:      all,
+				Broadcast: true,
+				Predicates: []PredicateExplain{
+					{Predicate: byContent, Dsts: all, Broadcast: true},
+				},
+				Queries: func() []sharding.Query {
+					res := make([]sharding.Query, 0, len(all))
+					for _, d := range all {
+						res = append(res, sharding.Query{
+							SQL:        fmt.Sprintf("SELECT `content` FROM `%s`.`%s` WHERE `content`=?;", d.DB, d.Table),
+							Args:       []any{"hello"},
+							DB:         d.DB,
+							Datasource: dsPattern,
+						})
+					}
+					return res
+				}(),
+			},
+		},
+		{
+			name: "select hint",
+			ctx:  sharding.CtxWithDst(context.Background(), dst(0, 2)),
+			explain: NewShardingSelector[Order](shardingDB).
+				Select(C("Content")).Where(byUser).Explain,
+			wantExplain: ShardingExplain{
+				Dsts: []sharding.Dst{dst(0, 2)},
+				Hint: true,
+				Predicates: []PredicateExplain{
+					{Predicate: byUser, Dsts: []sharding.Dst{dst(1, 0)}},
+				},
+				Queries: []sharding.Query{
+					{
+						SQL:        "SELECT `content` FROM `order_db_0`.`order_tab_2` WHERE `user_id`=?;",
+						Args:       []any{123},
+						DB:         "order_db_0",
+						Datasource: dsPattern,
+					},
+				},
+			},
+		},
+		{
+			name: "select invalid column",
+			ctx:  context.Background(),
+			explain: NewShardingSelector[Order](shardingDB).
+				Select(C("Invalid")).Where(byUser).Explain,
+			wantErr: errs.NewInvalidFieldError("Invalid"),
+		},
+		{
+			name: "update",
+			ctx:  context.Background(),
+			explain: NewShardingUpdater[Order](shardingDB).Update(&Order{Content: "world"}).
+				Set(C("Content")).Where(byUsers).Explain,
+			wantExplain: ShardingExplain{
+				Dsts: []sharding.Dst{dst(0, 2), dst(1, 1)},
+				Predicates: []PredicateExplain{
+					{Predicate: byUsers, Dsts: []sharding.Dst{dst(0, 2), dst(1, 1)}},
+				},
+				Queries: []sharding.Query{
+					{
+						SQL:        "UPDATE `order_db_0`.`order_tab_2` SET `content`=? WHERE `user_id` IN (?,?);",
+						Args:       []any{"world", 1, 2},
+						DB:         "order_db_0",
+						Datasource: dsPattern,
+					},
+					{
+						SQL:        "UPDATE `order_db_1`.`order_tab_1` SET `content`=? WHERE `user_id` IN (?,?);",
+						Args:       []any{"world", 1, 2},
+						DB:         "order_db_1",
+						Datasource: dsPattern,
+					},
+				},
+			},
+		},
+		{
+			name: "insert",
+			ctx:  context.Background(),
+			explain: NewShardingInsert[Order](shardingDB).Values([]*Order{
+				{UserId: 1, OrderId: 1, Content: "1", Account: 1.0},
+				{UserId: 2, OrderId: 2, Content: "2", Account: 2.0},
+			}).Explain,
+			wantExplain: ShardingExplain{
+				Dsts: []sharding.Dst{dst(0, 2), dst(1, 1)},
+				Queries: []sharding.Query{
+					{
+						SQL:        "INSERT INTO `order_db_0`.`order_tab_2`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);",
+						Args:       []any{2, int64(2), "2", 2.0},
+						DB:         "order_db_0",
+						Datasource: dsPattern,
+					},
+					{
+						SQL:        "INSERT INTO `order_db_1`.`order_tab_1`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);",
+						Args:       []any{1, int64(1), "1", 1.0},
+						DB:         "order_db_1",
+						Datasource: dsPattern,
+					},
+				},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.explain(tc.ctx)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantExplain, res)
+		})
+	}
+}
+
+func TestShardingExplain_SideEffectFree(t *testing.T) {
+	dsPattern := "0.db.cluster.company.com:3306"
+	r := newCurrencyRegistry(t, dsPattern)
+	_, err := r.Register(&OrderWithAutoId{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 4},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	gen := &mockIDGenerator{}
+	shardingDB, err := OpenDS("sqlite3", shardingsource.NewShardingDataSource(ds),
+		DBWithMetaRegistry(r), DBWithIDGenerator("order", gen))
+	require.NoError(t, err)
+
+	t.Run("auto id", func(t *testing.T) {
+		values := []*OrderWithAutoId{{UserId: 1, Content: "1"}}
+		_, err := NewShardingInsert[OrderWithAutoId](shardingDB).Values(values).Explain(context.Background())
+		assert.Equal(t, errs.NewErrExplainAutoIDShardingKey("OrderId"), err)
+		// Explain 不会生成主键，也不会回填到数据里面
+		assert.Equal(t, int64(0), values[0].OrderId)
+		assert.Equal(t, int64(0), gen.id)
+	})
+
+	t.Run("explain then build", func(t *testing.T) {
+		inserter := NewShardingInsert[OrderWithAutoId](shardingDB).
+			Values([]*OrderWithAutoId{{OrderId: 5, UserId: 1, Content: "1"}})
+		explain, err := inserter.Explain(context.Background())
+		require.NoError(t, err)
+		qs, err := inserter.Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, explain.Queries, qs)
+	})
+
+	t.Run("broadcast table", func(t *testing.T) {
+		wantDst := sharding.Dst{Name: dsPattern, DB: "order_db_0", Table: "currency"}
+		for i := 0; i < 10; i++ {
+			explain, err := NewShardingSelector[Currency](shardingDB).
+				Where(C("Id").EQ(1)).Explain(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, []sharding.Dst{wantDst}, explain.Dsts)
+		}
+	})
+}
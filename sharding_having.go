@@ -0,0 +1,264 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/havingmerger"
+)
+
+// aggregateKey 用于判断两个聚合函数是否相同，别名不影响结果
+type aggregateKey struct {
+	fn       string
+	arg      string
+	distinct bool
+}
+
+func keyOfAggregate(agg Aggregate) aggregateKey {
+	return aggregateKey{fn: agg.fn, arg: agg.arg, distinct: agg.distinct}
+}
+
+// havingAggregates 返回 HAVING 中使用了，但是没有出现在 SELECT 里面的聚合函数
+func (s *ShardingSelector[T]) havingAggregates() []Aggregate {
+	if len(s.having) == 0 {
+		return nil
+	}
+	seen := make(map[aggregateKey]struct{}, len(s.columns))
+	for _, selectable := range s.columns {
+		if agg, ok := selectable.(Aggregate); ok {
+			seen[keyOfAggregate(agg)] = struct{}{}
+		}
+	}
+	var res []Aggregate
+	var collect func(expr Expr)
+	collect = func(expr Expr) {
+		switch e := expr.(type) {
+		case Predicate:
+			collect(e.left)
+			collect(e.right)
+		case Aggregate:
+			key := keyOfAggregate(e)
+			if _, ok := seen[key]; ok {
+				return
+			}
+			seen[key] = struct{}{}
+			res = append(res, Aggregate{fn: e.fn, arg: e.arg, distinct: e.distinct})
+		}
+	}
+	for _, p := range s.having {
+		collect(p)
+	}
+	return res
+}
+
+// buildHavingAggregates 把 HAVING 中额外需要的聚合函数追加到 SELECT 后面
+func (s *ShardingSelector[T]) buildHavingAggregates() error {
+	for _, agg := range s.havingAggregates() {
+		s.comma()
+		var err error
+		if agg.fn == "AVG" {
+			err = s.selectAvgAsSumCount(agg)
+		} else {
+			err = s.selectAggregate(agg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// havingPredicate 将 HAVING 转化为在归并结果上执行的判断条件。
+// 归并结果中先是 GROUP BY 的列，而后是聚合函数
+func (s *ShardingSelector[T]) havingPredicate(groupCols []merger.ColumnInfo,
+	aggs []Aggregate) (havingmerger.Predicate, error) {
+	colIdx := make(map[string]int, len(groupCols))
+	for i := range groupCols {
+		colIdx[s.groupBy[i]] = i
+	}
+	aggIdx := make(map[aggregateKey]int, len(aggs))
+	for i, agg := range aggs {
+		key := keyOfAggregate(agg)
+		if _, ok := aggIdx[key]; !ok {
+			aggIdx[key] = len(groupCols) + i
+		}
+	}
+	p := s.having[0]
+	for i := 1; i < len(s.having); i++ {
+		p = p.And(s.having[i])
+	}
+	return compileHaving(p, colIdx, aggIdx)
+}
+
+func compileHaving(p Predicate, colIdx map[string]int,
+	aggIdx map[aggregateKey]int) (havingmerger.Predicate, error) {
+	switch p.op {
+	case opAnd, opOr:
+		left, err := compileHaving(p.left.(Predicate), colIdx, aggIdx)
+		if err != nil {
+			return nil, err
+		}
+		right, err := compileHaving(p.right.(Predicate), colIdx, aggIdx)
+		if err != nil {
+			return nil, err
+		}
+		isAnd := p.op == opAnd
+		return func(row []any) (bool, error) {
+			l, err := left(row)
+			if err != nil {
+				return false, err
+			}
+			if l != isAnd {
+				return l, nil
+			}
+			return right(row)
+		}, nil
+	case opNot:
+		pre, err := compileHaving(p.right.(Predicate), colIdx, aggIdx)
+		if err != nil {
+			return nil, err
+		}
+		return func(row []any) (bool, error) {
+			ok, err := pre(row)
+			return !ok, err
+		}, nil
+	case opEQ, opNEQ, opLT, opLTEQ, opGT, opGTEQ:
+		left, err := havingOperand(p.left, colIdx, aggIdx)
+		if err != nil {
+			return nil, err
+		}
+		right, err := havingOperand(p.right, colIdx, aggIdx)
+		if err != nil {
+			return nil, err
+		}
+		op := p.op
+		return func(row []any) (bool, error) {
+			res, err := compareHavingValue(left(row), right(row))
+			if err != nil {
+				return false, err
+			}
+			switch op {
+			case opEQ:
+				return res == 0, nil
+			case opNEQ:
+				return res != 0, nil
+			case opLT:
+				return res < 0, nil
+			case opLTEQ:
+				return res <= 0, nil
+			case opGT:
+				return res > 0, nil
+			default:
+				return res >= 0, nil
+			}
+		}, nil
+	default:
+		return nil, errs.NewUnsupportedOperatorError(p.op.Text)
+	}
+}
+
+func havingOperand(expr Expr, colIdx map[string]int,
+	aggIdx map[aggregateKey]int) (func(row []any) any, error) {
+	switch e := expr.(type) {
+	case Column:
+		idx, ok := colIdx[e.name]
+		if !ok {
+			return nil, errs.NewErrInvalidHavingColumn(e.name)
+		}
+		return func(row []any) any { return row[idx] }, nil
+	case Aggregate:
+		idx := aggIdx[keyOfAggregate(e)]
+		return func(row []any) any { return row[idx] }, nil
+	case valueExpr:
+		return func([]any) any { return e.val }, nil
+	default:
+		return nil, errs.ErrUnsupportedTooComplexQuery
+	}
+}
+
+// compareHavingValue 比较两个值，数字统一转化为 float64 进行比较
+func compareHavingValue(left, right any) (int, error) {
+	left, err := driverValue(left)
+	if err != nil {
+		return 0, err
+	}
+	right, err = driverValue(right)
+	if err != nil {
+		return 0, err
+	}
+	if l, ok := toFloat64(left); ok {
+		r, ok := toFloat64(right)
+		if !ok {
+			return 0, errs.NewErrUnsupportedHavingValue(right)
+		}
+		switch {
+		case l < r:
+			return -1, nil
+		case l > r:
+			return 1, nil
+		default:
+			return 0, nil
+		}
+	}
+	switch l := left.(type) {
+	case string:
+		r, ok := right.(string)
+		if !ok {
+			return 0, errs.NewErrUnsupportedHavingValue(right)
+		}
+		return strings.Compare(l, r), nil
+	case []byte:
+		r, ok := right.([]byte)
+		if !ok {
+			return 0, errs.NewErrUnsupportedHavingValue(right)
+		}
+		return strings.Compare(string(l), string(r)), nil
+	case time.Time:
+		r, ok := right.(time.Time)
+		if !ok {
+			return 0, errs.NewErrUnsupportedHavingValue(right)
+		}
+		return l.Compare(r), nil
+	default:
+		return 0, errs.NewErrUnsupportedHavingValue(left)
+	}
+}
+
+func driverValue(val any) (any, error) {
+	if v, ok := val.(driver.Valuer); ok {
+		return v.Value()
+	}
+	return val, nil
+}
+
+func toFloat64(val any) (float64, bool) {
+	rv := reflect.ValueOf(val)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
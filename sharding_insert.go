@@ -16,10 +16,8 @@ package eorm
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"reflect"
-	"sync"
 
 	"github.com/ecodeclub/ekit/mapx"
 
@@ -27,7 +25,6 @@ import (
 	"github.com/ecodeclub/eorm/internal/model"
 	"github.com/ecodeclub/eorm/internal/sharding"
 	"github.com/valyala/bytebufferpool"
-	"go.uber.org/multierr"
 )
 
 var _ sharding.Executor = &ShardingInserter[any]{}
@@ -36,46 +33,79 @@ type ShardingInserter[T any] struct {
 	shardingInserterBuilder
 	values []*T
 	db     Session
-	lock   sync.RWMutex
+	// bestEffort 表示部分目标表写入失败的时候，不影响其它目标表
+	bestEffort bool
+	upsert     *upsert
 }
 
 func (si *ShardingInserter[T]) Build(ctx context.Context) ([]sharding.Query, error) {
+	_, qs, err := si.build(ctx, false)
+	return qs, err
+}
+
+// Explain 返回数据写入的目标表以及每个目标表上的 SQL，但是不会执行插入。
+// 插入没有查询条件，每一行数据都按照 sharding key 的值查找目标表。
+// Explain 不会生成主键，所以自动生成的主键是 sharding key 的时候会返回错误
+func (si *ShardingInserter[T]) Explain(ctx context.Context) (ShardingExplain, error) {
+	defer si.explainBuffer()()
+	dsts, qs, err := si.build(ctx, true)
+	if err != nil {
+		return ShardingExplain{}, err
+	}
+	return si.explain(ctx, dsts, qs, nil)
+}
+
+// build 构造每个目标表上的 INSERT 语句，explain 为 true 的时候不会生成主键
+func (si *ShardingInserter[T]) build(ctx context.Context, explain bool) ([]sharding.Dst, []sharding.Query, error) {
 	defer bytebufferpool.Put(si.buffer)
 	var err error
 	if len(si.values) == 0 {
-		return nil, errors.New("插入0行")
+		return nil, nil, errors.New("插入0行")
 	}
 	si.meta, err = si.metaRegistry.Get(si.values[0])
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	colMetaData, err := si.getColumns()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	skNames := si.meta.ShardingAlgorithm.ShardingKeys()
-	if err := si.checkColumns(colMetaData, skNames); err != nil {
-		return nil, err
+	if err = si.checkUpsert(); err != nil {
+		return nil, nil, err
+	}
+	// 广播表的数据会写入全部目标，不需要 sharding key
+	if !si.meta.BroadcastTable {
+		skNames := si.meta.ShardingAlgorithm.ShardingKeys()
+		if err := si.checkColumns(colMetaData, skNames); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// ds-db => 目标表
 	//dsDBMap, err := mapx.NewTreeMap[key, *mapx.TreeMap[key, []*T]](compareDSDB)
 	dsDBTabMap, err := mapx.NewMultiTreeMap[sharding.Dst, *T](sharding.CompareDSDBTab)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, value := range si.values {
-		dst, err := si.findDst(ctx, value)
+		if !explain {
+			if err = si.fillAutoID(ctx, value); err != nil {
+				return nil, nil, err
+			}
+		}
+		dst, err := si.findDst(ctx, value, explain)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		// 一个value只能命中一个库表如果不满足就报错
-		if len(dst.Dsts) != 1 {
-			return nil, errs.ErrInsertFindingDst
+		// 广播表除外，广播表的每一行数据都要写入全部目标
+		if len(dst.Dsts) != 1 && !si.meta.BroadcastTable {
+			return nil, nil, errs.ErrInsertFindingDst
 		}
-		err = dsDBTabMap.Put(dst.Dsts[0], value)
-		if err != nil {
-			return nil, err
+		for _, d := range dst.Dsts {
+			if err = dsDBTabMap.Put(d, value); err != nil {
+				return nil, nil, err
+			}
 		}
 	}
 
@@ -87,7 +117,7 @@ func (si *ShardingInserter[T]) Build(ctx context.Context) ([]sharding.Query, err
 		vals, _ := dsDBTabMap.Get(dst)
 		err = si.buildQuery(dst.DB, dst.Table, colMetaData, vals)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		ansQuery = append(ansQuery, sharding.Query{
 			SQL:        si.buffer.String(),
@@ -98,7 +128,7 @@ func (si *ShardingInserter[T]) Build(ctx context.Context) ([]sharding.Query, err
 		si.buffer.Reset()
 		si.args = []any{}
 	}
-	return ansQuery, nil
+	return dsts, ansQuery, nil
 }
 
 func (si *ShardingInserter[T]) buildQuery(db, table string, colMetas []*model.ColumnMeta, values []*T) error {
@@ -132,6 +162,11 @@ func (si *ShardingInserter[T]) buildQuery(db, table string, colMetas []*model.Co
 		}
 		si.writeString(")")
 	}
+	if si.upsert != nil {
+		if err = si.buildUpsert(); err != nil {
+			return err
+		}
+	}
 	si.end()
 	return nil
 }
@@ -150,17 +185,85 @@ func (*ShardingInserter[T]) checkColumns(colMetas []*model.ColumnMeta, sks []str
 	return nil
 }
 
-func (si *ShardingInserter[T]) findDst(ctx context.Context, val *T) (sharding.Response, error) {
+func (si *ShardingInserter[T]) findDst(ctx context.Context, val *T, explain bool) (sharding.Response, error) {
+	if res, ok, err := si.findHintDst(ctx); ok {
+		return res, err
+	}
+	if si.meta.BroadcastTable {
+		return sharding.Response{Dsts: si.meta.ShardingAlgorithm.Broadcast(ctx)}, nil
+	}
+	if explain {
+		if err := si.checkAutoIDShardingKey(val); err != nil {
+			return sharding.Response{}, err
+		}
+	}
+	return si.meta.ShardingAlgorithm.Sharding(ctx, sharding.Request{
+		Op:       opEQ,
+		SkValues: si.skValues(val),
+	})
+}
+
+func (si *ShardingInserter[T]) skValues(val *T) map[string]any {
 	sks := si.meta.ShardingAlgorithm.ShardingKeys()
 	skValues := make(map[string]any)
 	for _, sk := range sks {
 		refVal := reflect.ValueOf(val).Elem().FieldByName(sk).Interface()
 		skValues[sk] = refVal
 	}
-	return si.meta.ShardingAlgorithm.Sharding(ctx, sharding.Request{
-		Op:       opEQ,
-		SkValues: skValues,
-	})
+	return skValues
+}
+
+// fieldValues 返回字段名到字段值的映射，供主键生成器使用
+func (si *ShardingInserter[T]) fieldValues(val *T) map[string]any {
+	refVal := reflect.ValueOf(val).Elem()
+	res := make(map[string]any, len(si.meta.Columns))
+	for _, c := range si.meta.Columns {
+		res[c.FieldName] = refVal.FieldByIndex(c.FieldIndexes).Interface()
+	}
+	return res
+}
+
+// fillAutoID 使用标签 auto_id 指定的生成器为零值字段生成主键。
+// 主键在查找目标表之前生成，所以主键本身也可以作为 sharding key
+func (si *ShardingInserter[T]) fillAutoID(ctx context.Context, val *T) error {
+	for _, c := range si.meta.Columns {
+		if c.AutoID == "" {
+			continue
+		}
+		fd := reflect.ValueOf(val).Elem().FieldByIndex(c.FieldIndexes)
+		if !fd.IsZero() {
+			continue
+		}
+		gen, ok := si.idGenerators[c.AutoID]
+		if !ok {
+			return errs.NewErrUnknownIDGenerator(c.AutoID)
+		}
+		id, err := gen.Generate(ctx, si.fieldValues(val))
+		if err != nil {
+			return err
+		}
+		switch fd.Kind() {
+		case reflect.Int, reflect.Int64:
+			fd.SetInt(id)
+		case reflect.Uint, reflect.Uint64:
+			fd.SetUint(uint64(id))
+		default:
+			return errs.NewErrUnsupportedAutoIDType(c.FieldName)
+		}
+	}
+	return nil
+}
+
+// checkAutoIDShardingKey 检查 sharding key 是不是还没有生成的主键，这种情况下无法确定目标表
+func (si *ShardingInserter[T]) checkAutoIDShardingKey(val *T) error {
+	refVal := reflect.ValueOf(val).Elem()
+	for _, sk := range si.meta.ShardingAlgorithm.ShardingKeys() {
+		c := si.meta.FieldMap[sk]
+		if c.AutoID != "" && refVal.FieldByIndex(c.FieldIndexes).IsZero() {
+			return errs.NewErrExplainAutoIDShardingKey(sk)
+		}
+	}
+	return nil
 }
 
 func (si *ShardingInserter[T]) getColumns() ([]*model.ColumnMeta, error) {
@@ -175,7 +278,8 @@ func (si *ShardingInserter[T]) getColumns() ([]*model.ColumnMeta, error) {
 		}
 	} else {
 		for _, val := range si.meta.Columns {
-			if si.ignorePK && val.IsPrimaryKey {
+			// 自动生成的主键不会被忽略
+			if si.ignorePK && val.IsPrimaryKey && val.AutoID == "" {
 				continue
 			}
 			cs = append(cs, val)
@@ -220,26 +324,24 @@ func NewShardingInsert[T any](db Session) *ShardingInserter[T] {
 	}
 }
 
+// BestEffort 开启 best effort 模式。
+// 默认情况下，只要有一个目标表写入失败，就会取消其它目标表上的写入；
+// 开启之后会在全部目标表上执行，Result 里面包含成功的结果和 *sharding.PartialError
+func (si *ShardingInserter[T]) BestEffort() *ShardingInserter[T] {
+	si.bestEffort = true
+	return si
+}
+
 func (si *ShardingInserter[T]) Exec(ctx context.Context) sharding.Result {
-	qs, err := si.Build(ctx)
+	dsts, qs, err := si.build(ctx, false)
 	if err != nil {
 		return sharding.NewResult(nil, err)
 	}
-	errList := make([]error, len(qs))
-	resList := make([]sql.Result, len(qs))
-	var wg sync.WaitGroup
-	wg.Add(len(qs))
-	for idx, q := range qs {
-		go func(idx int, q Query) {
-			defer wg.Done()
-			res, er := si.db.execContext(ctx, q)
-			si.lock.Lock()
-			errList[idx] = er
-			resList[idx] = res
-			si.lock.Unlock()
-		}(idx, q)
-	}
-	wg.Wait()
-	shardingRes := sharding.NewResult(resList, multierr.Combine(errList...))
-	return shardingRes
+	if err = si.checkShadowWrite(ctx, dsts); err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if si.meta.BroadcastTable {
+		return execBroadcast(ctx, si.db, qs)
+	}
+	return execAll(ctx, si.db, si.maxParallelism, dsts, qs, si.bestEffort)
 }
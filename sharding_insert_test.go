@@ -31,6 +31,7 @@ import (
 	"github.com/ecodeclub/eorm/internal/model"
 	"github.com/ecodeclub/eorm/internal/sharding"
 	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/ecodeclub/eorm/internal/sharding/idgen"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -206,6 +207,99 @@ func TestShardingInsert_Build(t *testing.T) {
 	}
 }
 
+type OrderWithAutoId struct {
+	OrderId int64 `eorm:"primary_key,auto_id=order"`
+	UserId  int
+	Content string
+}
+
+type mockIDGenerator struct {
+	id int64
+}
+
+func (g *mockIDGenerator) Generate(ctx context.Context, fields map[string]any) (int64, error) {
+	g.id++
+	return g.id, nil
+}
+
+func TestShardingInsert_AutoID(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&OrderWithAutoId{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 4},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	})
+	openDB := func(gen idgen.Generator) *DB {
+		opts := []DBOption{DBWithMetaRegistry(r)}
+		if gen != nil {
+			opts = append(opts, DBWithIDGenerator("order", gen))
+		}
+		db, err := OpenDS("sqlite3", ds, opts...)
+		require.NoError(t, err)
+		return db
+	}
+
+	t.Run("ignore pk", func(t *testing.T) {
+		values := []*OrderWithAutoId{
+			{UserId: 1, Content: "1"},
+			{UserId: 2, Content: "2"},
+			{OrderId: 13, UserId: 3, Content: "3"},
+		}
+		qs, err := NewShardingInsert[OrderWithAutoId](openDB(&mockIDGenerator{id: 4})).
+			Values(values).IgnorePK().Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, []sharding.Query{
+			{
+				SQL:        "INSERT INTO `order_db_0`.`order_tab_2`(`order_id`,`user_id`,`content`) VALUES(?,?,?);",
+				Args:       []any{int64(6), 2, "2"},
+				DB:         "order_db_0",
+				Datasource: dsPattern,
+			},
+			{
+				SQL:        "INSERT INTO `order_db_1`.`order_tab_1`(`order_id`,`user_id`,`content`) VALUES(?,?,?),(?,?,?);",
+				Args:       []any{int64(5), 1, "1", int64(13), 3, "3"},
+				DB:         "order_db_1",
+				Datasource: dsPattern,
+			},
+		}, qs)
+		// 生成的主键会回填到数据里面
+		assert.Equal(t, int64(5), values[0].OrderId)
+		assert.Equal(t, int64(6), values[1].OrderId)
+		assert.Equal(t, int64(13), values[2].OrderId)
+	})
+
+	t.Run("gene", func(t *testing.T) {
+		gene, err := idgen.NewGene("UserId", 3, 1)
+		require.NoError(t, err)
+		values := []*OrderWithAutoId{{UserId: 13, Content: "1"}}
+		qs, err := NewShardingInsert[OrderWithAutoId](openDB(gene)).
+			Values(values).Build(context.Background())
+		require.NoError(t, err)
+		// 主键的低 3 位和 UserId 相同，所以落在 13 对应的分片上
+		assert.Equal(t, int64(13&7), values[0].OrderId&7)
+		require.Len(t, qs, 1)
+		assert.Equal(t, "order_db_1", qs[0].DB)
+		assert.Equal(t, "INSERT INTO `order_db_1`.`order_tab_1`(`order_id`,`user_id`,`content`) VALUES(?,?,?);", qs[0].SQL)
+	})
+
+	t.Run("unknown generator", func(t *testing.T) {
+		_, err := NewShardingInsert[OrderWithAutoId](openDB(nil)).
+			Values([]*OrderWithAutoId{{UserId: 1, Content: "1"}}).Build(context.Background())
+		assert.Equal(t, errs.NewErrUnknownIDGenerator("order"), err)
+	})
+}
+
 type ShardingInsertSuite struct {
 	suite.Suite
 	mock01   sqlmock.Sqlmock
@@ -294,6 +388,29 @@ func (s *ShardingInsertSuite) TestShardingInsert_Exec() {
 			},
 			wantErr: multierr.Combine(newMockErr("db01")),
 		},
+		{
+			name: "best effort 部分插入失败",
+			si: NewShardingInsert[OrderInsert](shardingDB).Values([]*OrderInsert{
+				{UserId: 1, OrderId: 1, Content: "1", Account: 1.0},
+				{UserId: 2, OrderId: 2, Content: "2", Account: 2.0},
+				{UserId: 3, OrderId: 3, Content: "3", Account: 3.0},
+			}).BestEffort(),
+			mockDb: func() {
+				s.mock02.MatchExpectationsInOrder(false)
+				s.mock02.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_db_1`.`order_tab_1`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);")).WithArgs(1, int64(1), "1", 1.0).WillReturnError(newMockErr("db01"))
+				s.mock02.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_db_1`.`order_tab_0`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);")).WithArgs(3, int64(3), "3", 3.0).WillReturnResult(sqlmock.NewResult(1, 1))
+				s.mock01.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_db_0`.`order_tab_2`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);")).WithArgs(2, int64(2), "2", 2.0).WillReturnResult(sqlmock.NewResult(1, 1))
+			},
+			wantErr: &sharding.PartialError{
+				Errs: []sharding.DstError{
+					{
+						Dst: sharding.Dst{Name: dsPattern, DB: "order_db_1", Table: "order_tab_1"},
+						Err: newMockErr("db01"),
+					},
+				},
+			},
+			wantAffectedRows: 2,
+		},
 		{
 			name: "全部插入失败",
 			si: NewShardingInsert[OrderInsert](shardingDB).Values([]*OrderInsert{
@@ -307,7 +424,8 @@ func (s *ShardingInsertSuite) TestShardingInsert_Exec() {
 				s.mock02.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_db_1`.`order_tab_0`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);")).WithArgs(3, int64(3), "3", 3.0).WillReturnError(newMockErr("db"))
 				s.mock01.ExpectExec(regexp.QuoteMeta("INSERT INTO `order_db_0`.`order_tab_2`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?);")).WithArgs(2, int64(2), "2", 2.0).WillReturnError(newMockErr("db"))
 			},
-			wantErr: multierr.Combine(newMockErr("db"), newMockErr("db"), newMockErr("db")),
+			// 第一个失败的查询会取消其它查询
+			wantErr: newMockErr("db"),
 		},
 	}
 	for _, tc := range testcases {
@@ -315,12 +433,9 @@ func (s *ShardingInsertSuite) TestShardingInsert_Exec() {
 			tc.mockDb()
 			res := tc.si.Exec(context.Background())
 			require.Equal(t, tc.wantErr, res.Err())
-			if res.Err() != nil {
-				return
-			}
 
 			affectRows, err := res.RowsAffected()
-			require.NoError(t, err)
+			assert.Equal(t, tc.wantErr, err)
 			assert.Equal(t, tc.wantAffectedRows, affectRows)
 		})
 	}
@@ -0,0 +1,256 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+
+	"github.com/ecodeclub/ekit/mapx"
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+)
+
+// AllowShardingKeyUpdate 允许在 Set 里面更新 sharding key。
+// 更新 sharding key 的时候，Exec 会在一个分布式事务里面读出命中的数据，
+// 通过主键把它们从原本的目标表删除，更新之后再插入到 sharding key 对应的新目标表，
+// 迁移了目标表的数据可以通过 sharding.Result 的 Moves 获得。
+// 这种模式下 Set 只支持列和直接赋值的 Assign，模型必须有主键，并且会忽略 BestEffort。
+// MySQL 上读取数据的时候会使用 SELECT ... FOR UPDATE 加锁，InnoDB 的加锁读总是读取最新提交的数据，
+// 所以 READ COMMITTED 及以上的隔离级别都不会覆盖并发的更新；SQLite 的写事务是串行的。
+// 删除的行数和读出来的行数不一致的时候会返回 errs.ErrMoveRowsChanged 并且回滚
+func (s *ShardingUpdater[T]) AllowShardingKeyUpdate() *ShardingUpdater[T] {
+	s.allowShardingKeyUpdate = true
+	return s
+}
+
+// tryMove 在 Set 里面更新了 sharding key 的时候迁移数据，ok 为 false 表示没有更新 sharding key
+func (s *ShardingUpdater[T]) tryMove(ctx context.Context) (sharding.Result, bool) {
+	if err := s.initMeta(); err != nil {
+		return sharding.NewResult(nil, err), true
+	}
+	// 广播表的每个目标上都有全部的数据，不需要迁移
+	if s.meta.BroadcastTable {
+		return sharding.Result{}, false
+	}
+	vals, ok, err := s.moveValues()
+	if err != nil {
+		return sharding.NewResult(nil, err), true
+	}
+	if !ok {
+		return sharding.Result{}, false
+	}
+	return s.execMove(ctx, vals), true
+}
+
+// moveValues 返回 Set 里面每个字段更新之后的值，以及是否更新了 sharding key。
+// 没有更新 sharding key 的时候，Set 里面的错误留给普通的 UPDATE 处理
+func (s *ShardingUpdater[T]) moveValues() (map[string]any, bool, error) {
+	vals := make(map[string]any, len(s.assigns))
+	tableVal := reflect.ValueOf(s.table).Elem()
+	// err 是 Set 里面的第一个错误
+	var err error
+	fail := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	setField := func(name string) {
+		c, ok := s.meta.FieldMap[name]
+		if !ok {
+			fail(errs.NewInvalidFieldError(name))
+			return
+		}
+		vals[name] = tableVal.FieldByIndex(c.FieldIndexes).Interface()
+	}
+	for _, assign := range s.assigns {
+		switch a := assign.(type) {
+		case Column:
+			setField(a.name)
+		case columns:
+			for _, name := range a.cs {
+				setField(name)
+			}
+		case Assignment:
+			col, ok := a.left.(Column)
+			if !ok {
+				fail(errs.ErrUnsupportedAssignment)
+				continue
+			}
+			val, ok := a.right.(valueExpr)
+			if a.op != opEQ || !ok {
+				vals[col.name] = nil
+				fail(errs.NewErrUnsupportedMoveAssignment(col.name))
+				continue
+			}
+			if _, ok = s.meta.FieldMap[col.name]; !ok {
+				fail(errs.NewInvalidFieldError(col.name))
+				continue
+			}
+			vals[col.name] = val.val
+		default:
+			fail(errs.ErrUnsupportedAssignment)
+		}
+	}
+	for _, sk := range s.meta.ShardingAlgorithm.ShardingKeys() {
+		if _, ok := vals[sk]; ok {
+			return vals, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// execMove 更新 sharding key，把数据从原本的目标表迁移到新的目标表
+func (s *ShardingUpdater[T]) execMove(ctx context.Context, vals map[string]any) sharding.Result {
+	pks := slice.FilterMap[*model.ColumnMeta, *model.ColumnMeta](s.meta.Columns,
+		func(idx int, src *model.ColumnMeta) (*model.ColumnMeta, bool) {
+			return src, src.IsPrimaryKey
+		})
+	if len(pks) == 0 {
+		return sharding.NewResult(nil, errs.ErrMoveRowsWithoutPrimaryKey)
+	}
+	shardingRes, err := s.findDst(ctx, s.where...)
+	if err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if err = s.checkShadowWrite(ctx, shardingRes.Dsts); err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	var res []sql.Result
+	var moves []sharding.Move
+	err = execInTx(ctx, s.db, func(ctx context.Context, tx Session) error {
+		moves = nil
+		dstRows, err := mapx.NewMultiTreeMap[sharding.Dst, *T](sharding.CompareDSDBTab)
+		if err != nil {
+			return err
+		}
+		for _, dst := range shardingRes.Dsts {
+			dstCtx := sharding.CtxWithDst(ctx, dst)
+			ts, err := NewShardingSelector[T](tx).Where(s.where...).ForUpdate().GetMulti(dstCtx)
+			if err != nil {
+				return err
+			}
+			if len(ts) == 0 {
+				continue
+			}
+			qs, err := NewShardingDeleter[T](tx).Where(pkPredicate(pks, ts)).Build(dstCtx)
+			if err != nil {
+				return err
+			}
+			delRes, err := execQueries(ctx, tx, qs)
+			if err != nil {
+				return err
+			}
+			affected, err := sharding.NewResult(delRes, nil).RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected != int64(len(ts)) {
+				return errs.ErrMoveRowsChanged
+			}
+			for _, t := range ts {
+				newDst, err := s.moveRow(ctx, t, vals)
+				if err != nil {
+					return err
+				}
+				if !newDst.Equals(dst) {
+					moves = append(moves, sharding.Move{From: dst, To: newDst, Row: t})
+				}
+				if err = dstRows.Put(newDst, t); err != nil {
+					return err
+				}
+			}
+		}
+		if err = s.checkShadowWrite(ctx, dstRows.Keys()); err != nil {
+			return err
+		}
+		res = nil
+		for _, dst := range dstRows.Keys() {
+			ts, _ := dstRows.Get(dst)
+			qs, err := NewShardingInsert[T](tx).Values(ts).Build(sharding.CtxWithDst(ctx, dst))
+			if err != nil {
+				return err
+			}
+			r, err := execQueries(ctx, tx, qs)
+			if err != nil {
+				return err
+			}
+			res = append(res, r...)
+		}
+		return nil
+	})
+	if err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	return sharding.NewMoveResult(res, moves, nil)
+}
+
+// moveRow 把更新之后的值写入 t，返回 t 新的目标表
+func (s *ShardingUpdater[T]) moveRow(ctx context.Context, t *T, vals map[string]any) (sharding.Dst, error) {
+	refVal := reflect.ValueOf(t).Elem()
+	for name, val := range vals {
+		fd := refVal.FieldByIndex(s.meta.FieldMap[name].FieldIndexes)
+		v := reflect.ValueOf(val)
+		if !v.IsValid() {
+			fd.Set(reflect.Zero(fd.Type()))
+			continue
+		}
+		if !v.Type().ConvertibleTo(fd.Type()) {
+			return sharding.Dst{}, errs.NewErrUnsupportedMoveAssignment(name)
+		}
+		fd.Set(v.Convert(fd.Type()))
+	}
+	sks := s.meta.ShardingAlgorithm.ShardingKeys()
+	skValues := make(map[string]any, len(sks))
+	for _, sk := range sks {
+		skValues[sk] = refVal.FieldByIndex(s.meta.FieldMap[sk].FieldIndexes).Interface()
+	}
+	res, err := s.meta.ShardingAlgorithm.Sharding(ctx, sharding.Request{Op: opEQ, SkValues: skValues})
+	if err != nil {
+		return sharding.Dst{}, err
+	}
+	if len(res.Dsts) != 1 {
+		return sharding.Dst{}, errs.ErrInsertFindingDst
+	}
+	return res.Dsts[0], nil
+}
+
+// pkPredicate 构造按照主键匹配 ts 的查询条件
+func pkPredicate[T any](pks []*model.ColumnMeta, ts []*T) Predicate {
+	if len(pks) == 1 {
+		vals := make([]any, 0, len(ts))
+		for _, t := range ts {
+			vals = append(vals, reflect.ValueOf(t).Elem().FieldByIndex(pks[0].FieldIndexes).Interface())
+		}
+		return C(pks[0].FieldName).In(vals...)
+	}
+	var res Predicate
+	for i, t := range ts {
+		refVal := reflect.ValueOf(t).Elem()
+		p := C(pks[0].FieldName).EQ(refVal.FieldByIndex(pks[0].FieldIndexes).Interface())
+		for _, pk := range pks[1:] {
+			p = p.And(C(pk.FieldName).EQ(refVal.FieldByIndex(pk.FieldIndexes).Interface()))
+		}
+		if i == 0 {
+			res = p
+		} else {
+			res = res.Or(p)
+		}
+	}
+	return res
+}
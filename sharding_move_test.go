@@ -0,0 +1,213 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TenantUser struct {
+	Id       int64 `eorm:"primary_key"`
+	TenantId int
+	Name     string
+}
+
+func TestShardingUpdater_AllowShardingKeyUpdate(t *testing.T) {
+	dsPattern := "0.db.cluster.company.com:3306"
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&TenantUser{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "TenantId",
+			DBPattern:    &hash.Pattern{Name: "tenant_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "tenant_user", NotSharding: true},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	_, err = r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "tenant_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order", NotSharding: true},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	dst := func(db string) sharding.Dst {
+		return sharding.Dst{Name: dsPattern, DB: db, Table: "tenant_user"}
+	}
+	cols := []string{"id", "tenant_id", "name"}
+	testCases := []struct {
+		name         string
+		updater      func(db *DB) sharding.Executor
+		mock         func(mock0, mock1 sqlmock.Sqlmock)
+		wantAffected int64
+		wantMoves    []sharding.Move
+		wantErr      error
+	}{
+		{
+			name: "move",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[TenantUser](db).AllowShardingKeyUpdate().
+					Set(Assign("TenantId", 2)).Where(C("TenantId").EQ(1))
+			},
+			mock: func(mock0, mock1 sqlmock.Sqlmock) {
+				mock1.ExpectBegin()
+				mock1.ExpectQuery("SELECT `id`,`tenant_id`,`name` FROM `tenant_db_1`.`tenant_user` WHERE `tenant_id`=? FOR UPDATE;").
+					WithArgs(1).WillReturnRows(mock1.NewRows(cols).AddRow(10, 1, "Tom").AddRow(11, 1, "Jerry"))
+				mock1.ExpectExec("DELETE FROM `tenant_db_1`.`tenant_user` WHERE `id` IN (?,?);").
+					WithArgs(int64(10), int64(11)).WillReturnResult(sqlmock.NewResult(0, 2))
+				mock1.ExpectCommit()
+				mock0.ExpectBegin()
+				mock0.ExpectExec("INSERT INTO `tenant_db_0`.`tenant_user`(`id`,`tenant_id`,`name`) VALUES(?,?,?),(?,?,?);").
+					WithArgs(int64(10), 2, "Tom", int64(11), 2, "Jerry").WillReturnResult(sqlmock.NewResult(11, 2))
+				mock0.ExpectCommit()
+			},
+			wantAffected: 2,
+			wantMoves: []sharding.Move{
+				{From: dst("tenant_db_1"), To: dst("tenant_db_0"), Row: &TenantUser{Id: 10, TenantId: 2, Name: "Tom"}},
+				{From: dst("tenant_db_1"), To: dst("tenant_db_0"), Row: &TenantUser{Id: 11, TenantId: 2, Name: "Jerry"}},
+			},
+		},
+		{
+			name: "same dst",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[TenantUser](db).AllowShardingKeyUpdate().
+					Update(&TenantUser{TenantId: 3, Name: "Tom"}).
+					Set(Columns("TenantId", "Name")).Where(C("Id").EQ(10).And(C("TenantId").EQ(1)))
+			},
+			mock: func(mock0, mock1 sqlmock.Sqlmock) {
+				mock1.ExpectBegin()
+				mock1.ExpectQuery("SELECT `id`,`tenant_id`,`name` FROM `tenant_db_1`.`tenant_user` WHERE (`id`=?) AND (`tenant_id`=?) FOR UPDATE;").
+					WithArgs(10, 1).WillReturnRows(mock1.NewRows(cols).AddRow(10, 1, "Jerry"))
+				mock1.ExpectExec("DELETE FROM `tenant_db_1`.`tenant_user` WHERE `id` IN (?);").
+					WithArgs(int64(10)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock1.ExpectExec("INSERT INTO `tenant_db_1`.`tenant_user`(`id`,`tenant_id`,`name`) VALUES(?,?,?);").
+					WithArgs(int64(10), 3, "Tom").WillReturnResult(sqlmock.NewResult(10, 1))
+				mock1.ExpectCommit()
+			},
+			wantAffected: 1,
+		},
+		{
+			name: "rollback",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[TenantUser](db).AllowShardingKeyUpdate().
+					Set(Assign("TenantId", 2)).Where(C("TenantId").EQ(1))
+			},
+			mock: func(mock0, mock1 sqlmock.Sqlmock) {
+				mock1.ExpectBegin()
+				mock1.ExpectQuery("SELECT `id`,`tenant_id`,`name` FROM `tenant_db_1`.`tenant_user` WHERE `tenant_id`=? FOR UPDATE;").
+					WithArgs(1).WillReturnRows(mock1.NewRows(cols).AddRow(10, 1, "Tom"))
+				mock1.ExpectExec("DELETE FROM `tenant_db_1`.`tenant_user` WHERE `id` IN (?);").
+					WithArgs(int64(10)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock1.ExpectRollback()
+				mock0.ExpectBegin()
+				mock0.ExpectExec("INSERT INTO `tenant_db_0`.`tenant_user`(`id`,`tenant_id`,`name`) VALUES(?,?,?);").
+					WithArgs(int64(10), 2, "Tom").WillReturnError(errors.New("exec err"))
+				mock0.ExpectRollback()
+			},
+			wantErr: errors.New("exec err"),
+		},
+		{
+			name: "concurrent modified",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[TenantUser](db).AllowShardingKeyUpdate().
+					Set(Assign("TenantId", 2)).Where(C("TenantId").EQ(1))
+			},
+			mock: func(mock0, mock1 sqlmock.Sqlmock) {
+				mock1.ExpectBegin()
+				mock1.ExpectQuery("SELECT `id`,`tenant_id`,`name` FROM `tenant_db_1`.`tenant_user` WHERE `tenant_id`=? FOR UPDATE;").
+					WithArgs(1).WillReturnRows(mock1.NewRows(cols).AddRow(10, 1, "Tom").AddRow(11, 1, "Jerry"))
+				mock1.ExpectExec("DELETE FROM `tenant_db_1`.`tenant_user` WHERE `id` IN (?,?);").
+					WithArgs(int64(10), int64(11)).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock1.ExpectRollback()
+			},
+			wantErr: errs.ErrMoveRowsChanged,
+		},
+		{
+			name: "expression",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[TenantUser](db).AllowShardingKeyUpdate().
+					Set(Assign("TenantId", C("TenantId").Add(1))).Where(C("TenantId").EQ(1))
+			},
+			mock:    func(mock0, mock1 sqlmock.Sqlmock) {},
+			wantErr: errs.NewErrUnsupportedMoveAssignment("TenantId"),
+		},
+		{
+			name: "without primary key",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[Order](db).AllowShardingKeyUpdate().
+					Set(Assign("UserId", 2)).Where(C("UserId").EQ(1))
+			},
+			mock:    func(mock0, mock1 sqlmock.Sqlmock) {},
+			wantErr: errs.ErrMoveRowsWithoutPrimaryKey,
+		},
+		{
+			name: "not allowed",
+			updater: func(db *DB) sharding.Executor {
+				return NewShardingUpdater[TenantUser](db).
+					Set(Assign("TenantId", 2)).Where(C("TenantId").EQ(1))
+			},
+			mock:    func(mock0, mock1 sqlmock.Sqlmock) {},
+			wantErr: errs.NewErrUpdateShardingKeyUnsupported("TenantId"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB0, mock0, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer func() { _ = mockDB0.Close() }()
+			mockDB1, mock1, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer func() { _ = mockDB1.Close() }()
+			tc.mock(mock0, mock1)
+
+			m := map[string]*masterslave.MasterSlavesDB{
+				"tenant_db_0": masterslave.NewMasterSlavesDB(mockDB0),
+				"tenant_db_1": masterslave.NewMasterSlavesDB(mockDB1),
+			}
+			ds := map[string]datasource.DataSource{
+				dsPattern: cluster.NewClusterDB(m),
+			}
+			shardingDB, err := OpenDS("mysql", shardingsource.NewShardingDataSource(ds),
+				DBWithMetaRegistry(r))
+			require.NoError(t, err)
+
+			res := tc.updater(shardingDB).Exec(context.Background())
+			assert.Equal(t, tc.wantErr, res.Err())
+			if res.Err() == nil {
+				affected, err := res.RowsAffected()
+				require.NoError(t, err)
+				assert.Equal(t, tc.wantAffected, affected)
+				assert.Equal(t, tc.wantMoves, res.Moves())
+			}
+			assert.NoError(t, mock0.ExpectationsWereMet())
+			assert.NoError(t, mock1.ExpectationsWereMet())
+		})
+	}
+}
@@ -0,0 +1,503 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"github.com/ecodeclub/ekit/mapx"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/batchmerger"
+	"github.com/ecodeclub/eorm/internal/merger/pagedmerger"
+	"github.com/ecodeclub/eorm/internal/merger/sortmerger"
+	operator "github.com/ecodeclub/eorm/internal/operator"
+	"github.com/ecodeclub/eorm/internal/rows"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sqlparser"
+)
+
+var _ sharding.QueryBuilder = &ShardingRawQuerier[any]{}
+var _ sharding.Executor = &ShardingRawQuerier[any]{}
+
+// ShardingRawQuerier 在分库分表的数据源上执行原生 SQL，T 是逻辑表对应的模型。
+// 只支持 MySQL 单表的 SELECT、INSERT、UPDATE 和 DELETE 语句：
+// 根据 WHERE 里面 sharding key 的条件查找目标表，INSERT 则按照每一行 sharding key 的值分配目标表，
+// 然后把逻辑表替换成物理表再执行。无法用来查找目标表的条件会广播到全部目标表。
+// 命中多个目标表的 SELECT 只支持 ORDER BY 列和 LIMIT，不支持聚合函数、GROUP BY 和 DISTINCT；
+// UPDATE 和 DELETE 则不支持 ORDER BY 和 LIMIT
+type ShardingRawQuerier[T any] struct {
+	shardingBuilder
+	db   Session
+	sql  string
+	args []any
+	// bestEffort 表示部分目标表执行失败的时候，不影响其它目标表
+	bestEffort bool
+}
+
+// ShardingRawQuery 创建一个 ShardingRawQuerier
+func ShardingRawQuery[T any](sess Session, sql string, args ...any) *ShardingRawQuerier[T] {
+	q := &ShardingRawQuerier[T]{
+		db:   sess,
+		sql:  sql,
+		args: args,
+	}
+	q.core = sess.getCore()
+	return q
+}
+
+func (q *ShardingRawQuerier[T]) Build(ctx context.Context) ([]sharding.Query, error) {
+	_, _, qs, err := q.build(ctx)
+	return qs, err
+}
+
+func (q *ShardingRawQuerier[T]) build(ctx context.Context) (*sqlparser.Statement, []sharding.Dst, []sharding.Query, error) {
+	var err error
+	if q.meta == nil {
+		q.meta, err = q.metaRegistry.Get(new(T))
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	stmt, err := sqlparser.Parse(q.sql)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if stmt.Table.Name != q.meta.TableName {
+		return nil, nil, nil, errs.NewErrRawTableMismatch(stmt.Table.Name, q.meta.TableName)
+	}
+	if stmt.ParamCount() != len(q.args) {
+		return nil, nil, nil, errs.NewErrRawArgsMismatch(stmt.ParamCount(), len(q.args))
+	}
+	if stmt.Type == sqlparser.Insert {
+		dsts, qs, err := q.buildInsert(ctx, stmt)
+		return stmt, dsts, qs, err
+	}
+	if stmt.Type == sqlparser.Update {
+		for _, col := range stmt.Assignments {
+			if field, ok := q.fieldOf(col); ok && q.isShardingKey(field) {
+				return nil, nil, nil, errs.NewErrUpdateShardingKeyUnsupported(field)
+			}
+		}
+	}
+	dsts, err := q.findRawDst(ctx, stmt)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if len(dsts) > 1 && !q.meta.BroadcastTable && (stmt.Type == sqlparser.Update || stmt.Type == sqlparser.Delete) {
+		if err = checkRawMultiWrite(stmt); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	// 命中多个分片的时候，每个分片都要查出 offset + limit 行，再在内存中分页
+	limit := 0
+	if stmt.Type == sqlparser.Select && len(dsts) > 1 {
+		if err = checkRawMerge(stmt); err != nil {
+			return nil, nil, nil, err
+		}
+		if stmt.Limit != nil {
+			offset, cnt, err := q.limitOf(stmt)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			limit = offset + cnt
+		}
+	}
+	qs := make([]sharding.Query, 0, len(dsts))
+	for _, dst := range dsts {
+		qs = append(qs, q.rewrite(stmt, dst, nil, limit))
+	}
+	return stmt, dsts, qs, nil
+}
+
+// buildInsert 按照每一行 sharding key 的值分配目标表，每个目标表生成一个 INSERT 语句
+func (q *ShardingRawQuerier[T]) buildInsert(ctx context.Context, stmt *sqlparser.Statement) ([]sharding.Dst, []sharding.Query, error) {
+	// 广播表的每一行数据都要写入全部目标
+	if q.meta.BroadcastTable {
+		dsts := q.meta.ShardingAlgorithm.Broadcast(ctx)
+		qs := make([]sharding.Query, 0, len(dsts))
+		for _, dst := range dsts {
+			qs = append(qs, q.rewrite(stmt, dst, nil, 0))
+		}
+		return dsts, qs, nil
+	}
+	skIdx := make(map[string]int, 1)
+	for _, sk := range q.meta.ShardingAlgorithm.ShardingKeys() {
+		cm, ok := q.meta.FieldMap[sk]
+		if !ok {
+			return nil, nil, errs.ErrInsertShardingKeyNotFound
+		}
+		idx := -1
+		for i, col := range stmt.Columns {
+			if col == cm.ColumnName {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, nil, errs.ErrInsertShardingKeyNotFound
+		}
+		skIdx[sk] = idx
+	}
+	dstRows, err := mapx.NewMultiTreeMap[sharding.Dst, int](sharding.CompareDSDBTab)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i, row := range stmt.Rows {
+		skValues := make(map[string]any, len(skIdx))
+		for sk, idx := range skIdx {
+			val, ok := row.Values[idx].(sqlparser.Value)
+			if !ok {
+				return nil, nil, errs.NewErrUnsupportedRawSQL("表达式作为 sharding key 的值")
+			}
+			skVal, ok := q.skValueOf(sk, val)
+			if !ok {
+				return nil, nil, errs.NewErrUnsupportedShardingValue(val.Val)
+			}
+			skValues[sk] = skVal
+		}
+		res, err := q.meta.ShardingAlgorithm.Sharding(ctx, sharding.Request{Op: opEQ, SkValues: skValues})
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(res.Dsts) != 1 {
+			return nil, nil, errs.ErrInsertFindingDst
+		}
+		if err = dstRows.Put(res.Dsts[0], i); err != nil {
+			return nil, nil, err
+		}
+	}
+	dsts := dstRows.Keys()
+	qs := make([]sharding.Query, 0, len(dsts))
+	for _, dst := range dsts {
+		rows, _ := dstRows.Get(dst)
+		qs = append(qs, q.rewrite(stmt, dst, rows, 0))
+	}
+	return dsts, qs, nil
+}
+
+// rewrite 把逻辑表替换成 dst，并且挑出改写之后的 SQL 用到的参数
+func (q *ShardingRawQuerier[T]) rewrite(stmt *sqlparser.Statement, dst sharding.Dst, rows []int, limit int) sharding.Query {
+	sql, params := stmt.Rewrite(dst.DB, dst.Table, rows, limit)
+	args := make([]any, 0, len(params))
+	for _, p := range params {
+		args = append(args, q.args[p])
+	}
+	return sharding.Query{SQL: sql, Args: args, DB: dst.DB, Datasource: dst.Name}
+}
+
+func (q *ShardingRawQuerier[T]) findRawDst(ctx context.Context, stmt *sqlparser.Statement) ([]sharding.Dst, error) {
+	if res, ok, err := q.findHintDst(ctx); ok {
+		return res.Dsts, err
+	}
+	all := q.meta.ShardingAlgorithm.Broadcast(ctx)
+	if q.meta.BroadcastTable {
+		// 广播表只需要从其中一个目标读取数据
+		if stmt.Type == sqlparser.Select && len(all) > 1 {
+			return []sharding.Dst{all[rand.Intn(len(all))]}, nil
+		}
+		return all, nil
+	}
+	if stmt.Where == nil {
+		return all, nil
+	}
+	res, err := q.findDstByExpr(ctx, stmt.Where, all)
+	return res.Dsts, err
+}
+
+// findDstByExpr 和 findDstByPredicate 一样查找目标表，无法处理的条件当成广播
+func (q *ShardingRawQuerier[T]) findDstByExpr(ctx context.Context, expr sqlparser.Expr, all []sharding.Dst) (sharding.Response, error) {
+	switch e := expr.(type) {
+	case sqlparser.AndExpr:
+		left, err := q.findDstByExpr(ctx, e.Left, all)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		right, err := q.findDstByExpr(ctx, e.Right, all)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		return q.mergeAnd(left, right), nil
+	case sqlparser.OrExpr:
+		left, err := q.findDstByExpr(ctx, e.Left, all)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		right, err := q.findDstByExpr(ctx, e.Right, all)
+		if err != nil {
+			return sharding.EmptyResp, err
+		}
+		return q.mergeOR(left, right), nil
+	case sqlparser.CompareExpr:
+		field, ok := q.fieldOf(e.Column)
+		if !ok || !isRoutableOp(e.Op) {
+			return sharding.Response{Dsts: all}, nil
+		}
+		val, ok := q.skValueOf(field, e.Value)
+		if !ok {
+			return sharding.Response{Dsts: all}, nil
+		}
+		return q.meta.ShardingAlgorithm.Sharding(ctx,
+			sharding.Request{Op: e.Op, SkValues: map[string]any{field: val}})
+	case sqlparser.LikeExpr:
+		field, ok := q.fieldOf(e.Column)
+		if !ok || !q.isShardingKey(field) {
+			return sharding.Response{Dsts: all}, nil
+		}
+		pattern, ok := q.valueOf(e.Pattern).(string)
+		if !ok {
+			return sharding.Response{Dsts: all}, nil
+		}
+		return q.findDstByLike(ctx, field, pattern)
+	case sqlparser.InExpr:
+		field, ok := q.fieldOf(e.Column)
+		if !ok || e.Not {
+			return sharding.Response{Dsts: all}, nil
+		}
+		results := make([]sharding.Response, 0, len(e.Values))
+		for _, v := range e.Values {
+			val, ok := q.skValueOf(field, v)
+			if !ok {
+				return sharding.Response{Dsts: all}, nil
+			}
+			res, err := q.meta.ShardingAlgorithm.Sharding(ctx,
+				sharding.Request{Op: opEQ, SkValues: map[string]any{field: val}})
+			if err != nil {
+				return sharding.EmptyResp, err
+			}
+			results = append(results, res)
+		}
+		return q.mergeIN(results), nil
+	default:
+		return sharding.Response{Dsts: all}, nil
+	}
+}
+
+// isRoutableOp 判断比较运算能不能交给 ShardingAlgorithm 查找目标表，和 shardingBuilder 保持一致
+func isRoutableOp(op operator.Op) bool {
+	switch op {
+	case opEQ, opGT, opLT, opGTEQ, opLTEQ, opNEQ:
+		return true
+	default:
+		return false
+	}
+}
+
+// fieldOf 返回列对应的字段名
+func (q *ShardingRawQuerier[T]) fieldOf(col string) (string, bool) {
+	cm, ok := q.meta.ColumnMap[col]
+	if !ok {
+		return "", false
+	}
+	return cm.FieldName, true
+}
+
+func (q *ShardingRawQuerier[T]) isShardingKey(field string) bool {
+	for _, sk := range q.meta.ShardingAlgorithm.ShardingKeys() {
+		if sk == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (q *ShardingRawQuerier[T]) valueOf(val sqlparser.Value) any {
+	if val.Param >= 0 {
+		return q.args[val.Param]
+	}
+	return val.Val
+}
+
+// skValueOf 返回交给 ShardingAlgorithm 的值。
+// SQL 里面的字面量需要先转化为字段的类型，例如 int 类型的 sharding key 写成了 '2'，
+// 否则会和参数 2 落在不同的目标表上。第二个返回值表示能否转化
+func (q *ShardingRawQuerier[T]) skValueOf(field string, val sqlparser.Value) (any, bool) {
+	if val.Param >= 0 {
+		return q.args[val.Param], true
+	}
+	fd, ok := q.meta.FieldMap[field]
+	if !ok {
+		return nil, false
+	}
+	typ := fd.Typ
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	dst := reflect.New(typ)
+	if err := rows.ConvertAssign(dst.Interface(), val.Val); err != nil {
+		return nil, false
+	}
+	return dst.Elem().Interface(), true
+}
+
+func (q *ShardingRawQuerier[T]) limitOf(stmt *sqlparser.Statement) (int, int, error) {
+	offset, err := toInt(q.valueOf(stmt.Limit.Offset))
+	if err != nil {
+		return 0, 0, err
+	}
+	cnt, err := toInt(q.valueOf(stmt.Limit.Count))
+	return offset, cnt, err
+}
+
+func toInt(val any) (int, error) {
+	v := reflect.ValueOf(val)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), nil
+	default:
+		return 0, errs.NewErrUnsupportedRawSQL(fmt.Sprintf("LIMIT %v", val))
+	}
+}
+
+// checkRawMerge 检查命中多个分片的 SELECT 能不能在内存中归并
+func checkRawMerge(stmt *sqlparser.Statement) error {
+	switch {
+	case stmt.Aggregate:
+		return errs.NewErrUnsupportedRawSQL("跨分片的聚合函数")
+	case stmt.GroupBy || stmt.Having:
+		return errs.NewErrUnsupportedRawSQL("跨分片的 GROUP BY")
+	case stmt.Distinct:
+		return errs.NewErrUnsupportedRawSQL("跨分片的 DISTINCT")
+	case stmt.ComplexOrderBy:
+		return errs.NewErrUnsupportedRawSQL("跨分片的 ORDER BY 表达式")
+	}
+	return nil
+}
+
+// checkRawMultiWrite 检查命中多个分片的 UPDATE 和 DELETE。
+// 每个分片都会执行一次 LIMIT n，总共可能修改 n 乘以分片数量行数据，所以不支持 ORDER BY 和 LIMIT
+func checkRawMultiWrite(stmt *sqlparser.Statement) error {
+	if len(stmt.OrderBy) > 0 || stmt.ComplexOrderBy {
+		return errs.NewErrUnsupportedRawSQL("跨分片的 UPDATE 和 DELETE 使用 ORDER BY")
+	}
+	if stmt.Limit != nil {
+		return errs.NewErrUnsupportedRawSQL("跨分片的 UPDATE 和 DELETE 使用 LIMIT")
+	}
+	return nil
+}
+
+func (q *ShardingRawQuerier[T]) getMerger(stmt *sqlparser.Statement, shardCnt int) (merger.Merger, error) {
+	var mgr merger.Merger = batchmerger.NewMerger()
+	if shardCnt <= 1 {
+		return mgr, nil
+	}
+	if len(stmt.OrderBy) > 0 {
+		sortCols := make([]sortmerger.SortColumn, 0, len(stmt.OrderBy))
+		for _, ob := range stmt.OrderBy {
+			order := sortmerger.ASC
+			if ob.Desc {
+				order = sortmerger.DESC
+			}
+			sortCols = append(sortCols, sortmerger.NewSortColumn(ob.Column, order))
+		}
+		sm, err := sortmerger.NewMerger(sortCols...)
+		if err != nil {
+			return nil, err
+		}
+		mgr = sm
+	}
+	if stmt.Limit != nil {
+		offset, cnt, err := q.limitOf(stmt)
+		if err != nil {
+			return nil, err
+		}
+		return pagedmerger.NewMerger(mgr, offset, cnt)
+	}
+	return mgr, nil
+}
+
+// BestEffort 开启 best effort 模式，只对 Exec 生效。
+// 默认情况下，只要有一个目标表执行失败，就会取消其它目标表上的执行；
+// 开启之后会在全部目标表上执行，Result 里面包含成功的结果和 *sharding.PartialError
+func (q *ShardingRawQuerier[T]) BestEffort() *ShardingRawQuerier[T] {
+	q.bestEffort = true
+	return q
+}
+
+// Exec 在每个目标表上执行 SQL，广播表的写操作会在同一个事务里面写入全部目标
+func (q *ShardingRawQuerier[T]) Exec(ctx context.Context) sharding.Result {
+	_, dsts, qs, err := q.build(ctx)
+	if err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if err = q.checkShadowWrite(ctx, dsts); err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if q.meta.BroadcastTable {
+		return execBroadcast(ctx, q.db, qs)
+	}
+	return execAll(ctx, q.db, q.maxParallelism, dsts, qs, q.bestEffort)
+}
+
+// Iter 执行 SELECT 语句，返回归并之后的结果的游标
+func (q *ShardingRawQuerier[T]) Iter(ctx context.Context) (*Iterator[T], error) {
+	stmt, _, qs, err := q.build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	mgr, err := q.getMerger(stmt, len(qs))
+	if err != nil {
+		return nil, err
+	}
+	rowsList, err := q.db.queryMulti(ctx, qs)
+	if err != nil {
+		return nil, err
+	}
+	rs, err := mgr.Merge(ctx, rowsList.AsSlice())
+	if err != nil {
+		for _, r := range rowsList.AsSlice() {
+			_ = r.Close()
+		}
+		return nil, err
+	}
+	return newIterator[T](rs, q.core, q.meta), nil
+}
+
+func (q *ShardingRawQuerier[T]) Get(ctx context.Context) (*T, error) {
+	it, err := q.Iter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = it.Close()
+	}()
+	if !it.Next() {
+		if err = it.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrNoRows
+	}
+	return it.Value(), nil
+}
+
+func (q *ShardingRawQuerier[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	it, err := q.Iter(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = it.Close()
+	}()
+	var res []*T
+	for it.Next() {
+		res = append(res, it.Value())
+	}
+	return res, it.Err()
+}
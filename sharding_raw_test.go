@@ -0,0 +1,474 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/ecodeclub/eorm/internal/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardingRawQuerier_Build(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_detail_db_0": MasterSlavesMemoryDB(),
+		"order_detail_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		sql     string
+		args    []any
+		wantQs  []sharding.Query
+		wantErr error
+	}{
+		{
+			name: "select eq",
+			sql:  "SELECT * FROM order_detail WHERE order_id = ? AND item_id > ?",
+			args: []any{123, 10},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id = ? AND item_id > ?",
+					Args:       []any{123, 10},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "select like",
+			sql:  "SELECT * FROM order_detail WHERE order_id = ? AND order_id LIKE ? AND using_col1 LIKE 'a%'",
+			args: []any{123, "12%"},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id = ? AND order_id LIKE ? AND using_col1 LIKE 'a%'",
+					Args:       []any{123, "12%"},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "select like broadcast",
+			sql:  "SELECT * FROM order_detail WHERE order_id LIKE ?",
+			args: []any{"12%"},
+			wantQs: func() []sharding.Query {
+				var res []sharding.Query
+				for i := 0; i < 2; i++ {
+					for j := 0; j < 3; j++ {
+						res = append(res, sharding.Query{
+							SQL:        fmt.Sprintf("SELECT * FROM `order_detail_db_%d`.`order_detail_tab_%d` WHERE order_id LIKE ?", i, j),
+							Args:       []any{"12%"},
+							DB:         fmt.Sprintf("order_detail_db_%d", i),
+							Datasource: dsPattern,
+						})
+					}
+				}
+				return res
+			}(),
+		},
+		{
+			name: "select in with literal",
+			sql:  "SELECT * FROM order_detail WHERE order_id IN (123, ?) AND using_col1 = 'a'",
+			args: []any{234},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT * FROM `order_detail_db_0`.`order_detail_tab_0` WHERE order_id IN (123, ?) AND using_col1 = 'a'",
+					Args:       []any{234},
+					DB:         "order_detail_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id IN (123, ?) AND using_col1 = 'a'",
+					Args:       []any{234},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "select quoted numeric literal",
+			sql:  "SELECT * FROM order_detail WHERE order_id = '123'",
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id = '123'",
+					Args:       []any{},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "select unconvertible literal broadcast",
+			sql:  "SELECT * FROM order_detail WHERE order_id IN ('abc', 125)",
+			wantQs: func() []sharding.Query {
+				var res []sharding.Query
+				for i := 0; i < 2; i++ {
+					for j := 0; j < 3; j++ {
+						res = append(res, sharding.Query{
+							SQL:        fmt.Sprintf("SELECT * FROM `order_detail_db_%d`.`order_detail_tab_%d` WHERE order_id IN ('abc', 125)", i, j),
+							Args:       []any{},
+							DB:         fmt.Sprintf("order_detail_db_%d", i),
+							Datasource: dsPattern,
+						})
+					}
+				}
+				return res
+			}(),
+		},
+		{
+			name: "select limit",
+			sql:  "SELECT * FROM order_detail WHERE order_id = ? OR order_id = ? ORDER BY item_id LIMIT ?, ?",
+			args: []any{123, 234, 10, 20},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT * FROM `order_detail_db_0`.`order_detail_tab_0` WHERE order_id = ? OR order_id = ? ORDER BY item_id LIMIT 30",
+					Args:       []any{123, 234},
+					DB:         "order_detail_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id = ? OR order_id = ? ORDER BY item_id LIMIT 30",
+					Args:       []any{123, 234},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "insert",
+			sql:  "INSERT INTO order_detail(order_id, item_id) VALUES (?, ?), (234, ?), (?, ?)",
+			args: []any{123, 1, 2, 126, 3},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_detail_db_0`.`order_detail_tab_0`(order_id, item_id) VALUES (234, ?),(?, ?)",
+					Args:       []any{2, 126, 3},
+					DB:         "order_detail_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "INSERT INTO `order_detail_db_1`.`order_detail_tab_0`(order_id, item_id) VALUES (?, ?)",
+					Args:       []any{123, 1},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "insert quoted numeric literal",
+			sql:  "INSERT INTO order_detail(order_id, item_id) VALUES ('123', ?)",
+			args: []any{1},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_detail_db_1`.`order_detail_tab_0`(order_id, item_id) VALUES ('123', ?)",
+					Args:       []any{1},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name:    "insert unconvertible literal",
+			sql:     "INSERT INTO order_detail(order_id, item_id) VALUES ('abc', ?)",
+			args:    []any{1},
+			wantErr: errs.NewErrUnsupportedShardingValue("abc"),
+		},
+		{
+			name: "delete",
+			sql:  "DELETE FROM order_detail WHERE order_id = ?",
+			args: []any{125},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_detail_db_1`.`order_detail_tab_2` WHERE order_id = ?",
+					Args:       []any{125},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "update",
+			sql:  "UPDATE order_detail SET using_col1 = ? WHERE order_id = ?",
+			args: []any{"a", 125},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "UPDATE `order_detail_db_1`.`order_detail_tab_2` SET using_col1 = ? WHERE order_id = ?",
+					Args:       []any{"a", 125},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "delete limit single shard",
+			sql:  "DELETE FROM order_detail WHERE order_id = ? ORDER BY item_id LIMIT 1",
+			args: []any{125},
+			wantQs: []sharding.Query{
+				{
+					SQL:        "DELETE FROM `order_detail_db_1`.`order_detail_tab_2` WHERE order_id = ? ORDER BY item_id LIMIT 1",
+					Args:       []any{125},
+					DB:         "order_detail_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name:    "delete limit across shards",
+			sql:     "DELETE FROM order_detail WHERE item_id = ? LIMIT 1",
+			args:    []any{1},
+			wantErr: errs.NewErrUnsupportedRawSQL("跨分片的 UPDATE 和 DELETE 使用 LIMIT"),
+		},
+		{
+			name:    "update order by across shards",
+			sql:     "UPDATE order_detail SET using_col1 = ? WHERE order_id IN (?, ?) ORDER BY item_id",
+			args:    []any{"a", 123, 234},
+			wantErr: errs.NewErrUnsupportedRawSQL("跨分片的 UPDATE 和 DELETE 使用 ORDER BY"),
+		},
+		{
+			name:    "update sharding key",
+			sql:     "UPDATE order_detail SET order_id = ? WHERE order_id = ?",
+			args:    []any{124, 125},
+			wantErr: errs.NewErrUpdateShardingKeyUnsupported("OrderId"),
+		},
+		{
+			name:    "insert without sharding key",
+			sql:     "INSERT INTO order_detail(item_id) VALUES (?)",
+			args:    []any{1},
+			wantErr: errs.ErrInsertShardingKeyNotFound,
+		},
+		{
+			name:    "aggregate across shards",
+			sql:     "SELECT COUNT(*) FROM order_detail",
+			wantErr: errs.NewErrUnsupportedRawSQL("跨分片的聚合函数"),
+		},
+		{
+			name:    "table mismatch",
+			sql:     "SELECT * FROM `order` WHERE order_id = ?",
+			args:    []any{123},
+			wantErr: errs.NewErrRawTableMismatch("order", "order_detail"),
+		},
+		{
+			name:    "args mismatch",
+			sql:     "SELECT * FROM order_detail WHERE order_id = ?",
+			wantErr: errs.NewErrRawArgsMismatch(1, 0),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := ShardingRawQuery[test.OrderDetail](shardingDB, tc.sql, tc.args...).
+				Build(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQs, qs)
+		})
+	}
+}
+
+func TestShardingRawQuerier_GetMulti(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	mockDB, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	mockDB2, mock2, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB2.Close() }()
+
+	clusterDB := cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+		"order_detail_db_0": masterslave.NewMasterSlavesDB(mockDB),
+		"order_detail_db_1": masterslave.NewMasterSlavesDB(mockDB2),
+	})
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("mysql",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	cols := []string{"order_id", "item_id", "using_col1", "using_col2"}
+	testCases := []struct {
+		name      string
+		sql       string
+		args      []any
+		mockOrder func(mock1, mock2 sqlmock.Sqlmock)
+		wantErr   error
+		wantRes   []*test.OrderDetail
+	}{
+		{
+			name: "order by and limit",
+			sql:  "SELECT * FROM order_detail WHERE order_id IN (?, ?) ORDER BY item_id DESC LIMIT 1, 2",
+			args: []any{123, 234},
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT * FROM `order_detail_db_0`.`order_detail_tab_0` WHERE order_id IN (?, ?) ORDER BY item_id DESC LIMIT 3").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows(cols).AddRow(234, 12, "Kevin", "Durant"))
+				mock2.ExpectQuery("SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id IN (?, ?) ORDER BY item_id DESC LIMIT 3").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows(cols).
+						AddRow(123, 13, "Stephen", "Curry").AddRow(123, 10, "LeBron", "James"))
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 234, ItemId: 12, UsingCol1: "Kevin", UsingCol2: "Durant"},
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+			},
+		},
+		{
+			name: "single shard",
+			sql:  "SELECT * FROM order_detail WHERE order_id = ?",
+			args: []any{123},
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock2.ExpectQuery("SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id = ?").
+					WithArgs(123).
+					WillReturnRows(mock2.NewRows(cols).AddRow(123, 10, "LeBron", "James"))
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+			},
+		},
+		{
+			name: "query err",
+			sql:  "SELECT * FROM order_detail WHERE order_id = ?",
+			args: []any{123},
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock2.ExpectQuery("SELECT * FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id = ?").
+					WithArgs(123).WillReturnError(newMockErr("db"))
+			},
+			wantErr: newMockErr("db"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockOrder(mock, mock2)
+			res, err := ShardingRawQuery[test.OrderDetail](shardingDB, tc.sql, tc.args...).
+				GetMulti(masterslave.UseMaster(context.Background()))
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestShardingRawQuerier_Exec(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	mockDB, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	mockDB2, mock2, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB2.Close() }()
+
+	clusterDB := cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+		"order_detail_db_0": masterslave.NewMasterSlavesDB(mockDB),
+		"order_detail_db_1": masterslave.NewMasterSlavesDB(mockDB2),
+	})
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("mysql",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	mock.ExpectExec("INSERT INTO `order_detail_db_0`.`order_detail_tab_0`(order_id, item_id) VALUES (?, ?)").
+		WithArgs(234, 2).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock2.ExpectExec("INSERT INTO `order_detail_db_1`.`order_detail_tab_0`(order_id, item_id) VALUES (?, ?)").
+		WithArgs(123, 1).WillReturnResult(sqlmock.NewResult(1, 1))
+	res := ShardingRawQuery[test.OrderDetail](shardingDB,
+		"INSERT INTO order_detail(order_id, item_id) VALUES (?, ?), (?, ?)", 123, 1, 234, 2).
+		Exec(context.Background())
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	res = ShardingRawQuery[test.OrderDetail](shardingDB,
+		"UPDATE order_detail SET order_id = ? WHERE order_id = ?", 234, 123).
+		Exec(context.Background())
+	assert.Equal(t, errs.NewErrUpdateShardingKeyUnsupported("OrderId"), res.Err())
+
+	// best effort 模式下一个目标表失败不影响其它目标表
+	mock.ExpectExec("DELETE FROM `order_detail_db_0`.`order_detail_tab_0` WHERE order_id IN (?, ?)").
+		WithArgs(123, 234).WillReturnError(newMockErr("db"))
+	mock2.ExpectExec("DELETE FROM `order_detail_db_1`.`order_detail_tab_0` WHERE order_id IN (?, ?)").
+		WithArgs(123, 234).WillReturnResult(sqlmock.NewResult(0, 1))
+	res = ShardingRawQuery[test.OrderDetail](shardingDB,
+		"DELETE FROM order_detail WHERE order_id IN (?, ?)", 123, 234).
+		BestEffort().Exec(context.Background())
+	assert.Equal(t, &sharding.PartialError{
+		Errs: []sharding.DstError{
+			{
+				Dst: sharding.Dst{Name: "0.db.cluster.company.com:3306", DB: "order_detail_db_0", Table: "order_detail_tab_0"},
+				Err: newMockErr("db"),
+			},
+		},
+	}, res.Err())
+	affected, err = res.RowsAffected()
+	assert.Equal(t, res.Err(), err)
+	assert.Equal(t, int64(1), affected)
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, mock2.ExpectationsWereMet())
+}
@@ -16,21 +16,38 @@ package eorm
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"math/rand"
 	"sync"
 
+	"github.com/ecodeclub/eorm/internal/dialect"
+	"github.com/ecodeclub/eorm/internal/merger"
+	"github.com/ecodeclub/eorm/internal/merger/aggregatemerger"
+	"github.com/ecodeclub/eorm/internal/merger/aggregatemerger/aggregator"
 	"github.com/ecodeclub/eorm/internal/merger/batchmerger"
+	"github.com/ecodeclub/eorm/internal/merger/distinctmerger"
+	"github.com/ecodeclub/eorm/internal/merger/groupby_merger"
+	"github.com/ecodeclub/eorm/internal/merger/havingmerger"
+	"github.com/ecodeclub/eorm/internal/merger/pagedmerger"
+	"github.com/ecodeclub/eorm/internal/merger/sortmerger"
 
 	"github.com/ecodeclub/eorm/internal/sharding"
 
 	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/rows"
 	"github.com/valyala/bytebufferpool"
 )
 
 type ShardingSelector[T any] struct {
 	shardingSelectorBuilder
-	table *T
+	table TableReference
 	db    Session
 	lock  sync.Mutex
+	// bestEffort 表示部分目标表查询失败的时候，仍然返回其它目标表的结果
+	bestEffort bool
+	// forUpdate 表示使用 SELECT ... FOR UPDATE 锁住查询到的数据
+	forUpdate bool
 }
 
 func NewShardingSelector[T any](db Session) *ShardingSelector[T] {
@@ -44,48 +61,92 @@ func NewShardingSelector[T any](db Session) *ShardingSelector[T] {
 }
 
 func (s *ShardingSelector[T]) Build(ctx context.Context) ([]sharding.Query, error) {
+	_, qs, err := s.build(ctx, false)
+	return qs, err
+}
+
+// Explain 返回查询命中的目标表、每个查询条件的路由结果以及每个目标表上的 SQL，但是不会执行查询。
+// 广播表只会从其中一个目标读取数据，Explain 固定返回第一个目标
+func (s *ShardingSelector[T]) Explain(ctx context.Context) (ShardingExplain, error) {
+	defer s.explainBuffer()()
+	dsts, qs, err := s.build(ctx, true)
+	if err != nil {
+		return ShardingExplain{}, err
+	}
+	return s.explain(ctx, dsts, qs, s.where)
+}
+
+// build 构造每个目标表上的查询，explain 为 true 的时候广播表固定使用第一个目标
+func (s *ShardingSelector[T]) build(ctx context.Context, explain bool) ([]sharding.Dst, []sharding.Query, error) {
 	var err error
 	if s.meta == nil {
 		s.meta, err = s.metaRegistry.Get(new(T))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
+	if err = s.checkTable(s.table); err != nil {
+		return nil, nil, err
+	}
 	shardingRes, err := s.findDst(ctx, s.where...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	// 广播表只需要从其中一个目标读取数据
+	if s.meta.BroadcastTable && len(shardingRes.Dsts) > 1 {
+		idx := 0
+		if !explain {
+			idx = rand.Intn(len(shardingRes.Dsts))
+		}
+		shardingRes.Dsts = shardingRes.Dsts[idx : idx+1]
 	}
 	res := make([]sharding.Query, 0, len(shardingRes.Dsts))
 	defer bytebufferpool.Put(s.buffer)
+	// 命中多个分片的时候，需要改写 SQL，再在内存中归并结果
+	multiShard := len(shardingRes.Dsts) > 1
 	for _, dst := range shardingRes.Dsts {
-		q, err := s.buildQuery(dst.DB, dst.Table, dst.Name)
+		q, err := s.buildQuery(ctx, dst, multiShard)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		res = append(res, q)
 		s.args = nil
 		s.buffer.Reset()
 	}
-	return res, nil
+	return shardingRes.Dsts, res, nil
 }
 
-func (s *ShardingSelector[T]) buildQuery(db, tbl, ds string) (sharding.Query, error) {
+// buildQuery 构造单个分片上的查询。
+// multiShard 为 true 的时候，会改写 AVG 和分页，以便在内存中归并
+func (s *ShardingSelector[T]) buildQuery(ctx context.Context, dst sharding.Dst, multiShard bool) (sharding.Query, error) {
 	var err error
 	s.writeString("SELECT ")
+	if s.distinct {
+		s.writeString("DISTINCT ")
+	}
 	if len(s.columns) == 0 {
+		if _, ok := s.table.(Join); ok {
+			return sharding.EmptyQuery, errs.NewMustSpecifyColumnsError()
+		}
 		if err = s.buildAllColumns(); err != nil {
 			return sharding.EmptyQuery, err
 		}
 	} else {
-		err = s.buildSelectedList()
+		err = s.buildSelectedList(multiShard)
 		if err != nil {
 			return sharding.EmptyQuery, err
 		}
 	}
+	// HAVING 需要在归并之后执行，所以要把 HAVING 用到的聚合函数也查询出来
+	if multiShard && s.isAggregateQuery() {
+		if err = s.buildHavingAggregates(); err != nil {
+			return sharding.EmptyQuery, err
+		}
+	}
 	s.writeString(" FROM ")
-	s.quote(db)
-	s.writeByte('.')
-	s.quote(tbl)
+	if err = s.buildTable(ctx, s.table, dst); err != nil {
+		return sharding.EmptyQuery, err
+	}
 
 	if len(s.where) > 0 {
 		s.writeString(" WHERE ")
@@ -106,6 +167,19 @@ func (s *ShardingSelector[T]) buildQuery(db, tbl, ds string) (sharding.Query, er
 		}
 	}
 
+	// having
+	// 跨分片的时候每个分片只有部分数据，HAVING 在归并之后执行
+	if len(s.having) > 0 && !(multiShard && s.isAggregateQuery()) {
+		s.writeString(" HAVING ")
+		p := s.having[0]
+		for i := 1; i < len(s.having); i++ {
+			p = p.And(s.having[i])
+		}
+		if err = s.buildExpr(p); err != nil {
+			return sharding.EmptyQuery, err
+		}
+	}
+
 	// order by
 	if len(s.orderBy) > 0 {
 		err = s.buildOrderBy()
@@ -114,29 +188,131 @@ func (s *ShardingSelector[T]) buildQuery(db, tbl, ds string) (sharding.Query, er
 		}
 	}
 
-	// having
-	if len(s.having) > 0 {
-		s.writeString(" HAVING ")
-		p := s.having[0]
-		for i := 1; i < len(s.having); i++ {
-			p = p.And(s.having[i])
+	// 各个分片上的 OFFSET 没有意义，需要改写成 LIMIT offset+limit
+	if multiShard {
+		s.buildRewrittenLimit()
+	} else {
+		s.buildLimit()
+	}
+	if s.forUpdate && s.dialect != dialect.SQLite {
+		s.writeString(" FOR UPDATE")
+	}
+	s.end()
+	return sharding.Query{SQL: s.buffer.String(), Args: s.args, Datasource: dst.Name, DB: dst.DB}, nil
+}
+
+// checkTable 检查 FROM 里面的表，JOIN 的表必须是主表的绑定表或者广播表
+func (s *ShardingSelector[T]) checkTable(table TableReference) error {
+	switch t := table.(type) {
+	case nil:
+		return nil
+	case Table:
+		m, err := s.metaRegistry.Get(t.entity)
+		if err != nil {
+			return err
 		}
-		if err = s.buildExpr(p); err != nil {
-			return sharding.EmptyQuery, err
+		return s.checkJoinTable(m)
+	case Join:
+		if err := s.checkTable(t.left); err != nil {
+			return err
+		}
+		return s.checkTable(t.right)
+	default:
+		return errs.NewUnsupportedTableReferenceError(table)
+	}
+}
+
+// buildTable 将 FROM 里面的表改写为 dst 上对应的物理表
+func (s *ShardingSelector[T]) buildTable(ctx context.Context, table TableReference, dst sharding.Dst) error {
+	switch t := table.(type) {
+	case nil:
+		s.quote(dst.DB)
+		s.point()
+		s.quote(dst.Table)
+	case Table:
+		m, err := s.metaRegistry.Get(t.entity)
+		if err != nil {
+			return err
+		}
+		d, err := s.bindingDst(ctx, m, dst)
+		if err != nil {
+			return err
+		}
+		s.quote(d.DB)
+		s.point()
+		s.quote(d.Table)
+		if t.alias != "" {
+			s.writeString(" AS ")
+			s.quote(t.alias)
+		}
+	case Join:
+		return s.buildJoin(ctx, t, dst)
+	default:
+		return errs.NewUnsupportedTableReferenceError(table)
+	}
+	return nil
+}
+
+func (s *ShardingSelector[T]) buildJoin(ctx context.Context, t Join, dst sharding.Dst) error {
+	s.writeByte('(')
+	if err := s.buildTable(ctx, t.left, dst); err != nil {
+		return err
+	}
+	s.space()
+	s.writeString(t.typ)
+	s.space()
+	if err := s.buildTable(ctx, t.right, dst); err != nil {
+		return err
+	}
+	if len(t.using) > 0 {
+		s.writeString(" USING (")
+		for i, col := range t.using {
+			if err := s.buildColumns(i, col); err != nil {
+				return err
+			}
+		}
+		s.writeByte(')')
+	}
+	if len(t.on) > 0 {
+		s.writeString(" ON ")
+		p := t.on[0]
+		for i := 1; i < len(t.on); i++ {
+			p = p.And(t.on[i])
+		}
+		if err := s.buildExpr(p); err != nil {
+			return err
 		}
 	}
+	s.writeByte(')')
+	return nil
+}
 
+// buildLimit 构造 LIMIT ? OFFSET ?。
+// MySQL 不支持只有 OFFSET 的语法，所以没有 LIMIT 的时候使用最大值
+func (s *ShardingSelector[T]) buildLimit() {
+	if s.limit <= 0 && s.offset <= 0 {
+		return
+	}
+	limit := s.limit
+	if limit <= 0 {
+		limit = math.MaxInt
+	}
+	s.writeString(" LIMIT ")
+	s.parameter(limit)
 	if s.offset > 0 {
 		s.writeString(" OFFSET ")
 		s.parameter(s.offset)
 	}
+}
 
-	if s.limit > 0 {
+// buildRewrittenLimit 将 OFFSET x LIMIT y 改写为 LIMIT x+y。
+// 只有 OFFSET 的时候，每个分片都需要返回全部数据。
+// 有 GROUP BY 的时候，同一个分组可能分散在多个分片上，所以不能在分片上做任何限制
+func (s *ShardingSelector[T]) buildRewrittenLimit() {
+	if s.limit > 0 && len(s.groupBy) == 0 {
 		s.writeString(" LIMIT ")
-		s.parameter(s.limit)
+		s.parameter(s.offset + s.limit)
 	}
-	s.end()
-	return sharding.Query{SQL: s.buffer.String(), Args: s.args, Datasource: ds, DB: db}, nil
 }
 
 func (s *ShardingSelector[T]) buildAllColumns() error {
@@ -146,7 +322,12 @@ func (s *ShardingSelector[T]) buildAllColumns() error {
 	return nil
 }
 
-func (s *ShardingSelector[T]) buildSelectedList() error {
+func (s *ShardingSelector[T]) buildSelectedList(multiShard bool) error {
+	if multiShard {
+		if err := s.checkAggregate(); err != nil {
+			return err
+		}
+	}
 	for i, selectable := range s.columns {
 		if i > 0 {
 			s.comma()
@@ -165,6 +346,12 @@ func (s *ShardingSelector[T]) buildSelectedList() error {
 				}
 			}
 		case Aggregate:
+			if multiShard && expr.fn == "AVG" {
+				if err := s.selectAvgAsSumCount(expr); err != nil {
+					return err
+				}
+				continue
+			}
 			if err := s.selectAggregate(expr); err != nil {
 				return err
 			}
@@ -175,6 +362,52 @@ func (s *ShardingSelector[T]) buildSelectedList() error {
 	return nil
 
 }
+
+// checkAggregate 检查跨分片的聚合查询能否在内存中归并
+func (s *ShardingSelector[T]) checkAggregate() error {
+	if !s.isAggregateQuery() {
+		return nil
+	}
+	for _, selectable := range s.columns {
+		agg, ok := selectable.(Aggregate)
+		if !ok {
+			if len(s.groupBy) == 0 {
+				return errs.ErrAggregateMixedWithColumns
+			}
+			continue
+		}
+		// MIN 和 MAX 不受 DISTINCT 影响
+		if agg.distinct && agg.fn != "MIN" && agg.fn != "MAX" {
+			return errs.NewErrUnsupportedDistinctAggregate(agg.fn)
+		}
+	}
+	return nil
+}
+
+// isAggregateQuery 判断跨分片的时候是否需要在内存中聚合
+func (s *ShardingSelector[T]) isAggregateQuery() bool {
+	return len(s.groupBy) > 0 || s.hasAggregate()
+}
+
+func (s *ShardingSelector[T]) hasAggregate() bool {
+	for _, selectable := range s.columns {
+		if _, ok := selectable.(Aggregate); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// selectAvgAsSumCount 将 AVG(col) 改写为 SUM(col),COUNT(col)
+// 跨分片的平均值只能通过全局的 SUM 和 COUNT 计算得到
+func (s *ShardingSelector[T]) selectAvgAsSumCount(aggregate Aggregate) error {
+	if err := s.selectAggregate(Aggregate{fn: "SUM", arg: aggregate.arg, table: aggregate.table}); err != nil {
+		return err
+	}
+	s.comma()
+	return s.selectAggregate(Aggregate{fn: "COUNT", arg: aggregate.arg, table: aggregate.table})
+}
+
 func (s *ShardingSelector[T]) selectAggregate(aggregate Aggregate) error {
 	s.writeString(aggregate.fn)
 
@@ -267,48 +500,69 @@ func (s *ShardingSelector[T]) buildGroupBy() error {
 	return nil
 }
 
+// Get 查询一行数据。
+// 命中多个分片的时候，每个分片都只查询一行，归并之后取第一行。
+// 如果指定了 ORDER BY，那么返回的是全局排序之后的第一行
 func (s *ShardingSelector[T]) Get(ctx context.Context) (*T, error) {
-	qs, err := s.Limit(1).Build(ctx)
+	dsts, qs, err := s.Limit(1).build(ctx, false)
 	if err != nil {
 		return nil, err
 	}
+	// 查询条件落在所有目标表之外，例如范围分片的空隙里面
 	if len(qs) == 0 {
-		return nil, errs.ErrNotGenShardingQuery
+		return nil, ErrNoRows
 	}
-	// TODO 要确保前面的改写 SQL 只能生成一个 SQL
-	if len(qs) > 1 {
-		return nil, errs.ErrOnlyResultOneQuery
+	mgr, err := s.getMerger(len(qs))
+	if err != nil {
+		return nil, err
+	}
+	rowsList, partialErr := s.queryMulti(ctx, dsts, qs)
+	if partialErr != nil && len(rowsList) == 0 {
+		return nil, partialErr
 	}
-	q := qs[0]
-	// TODO 利用 ctx 传递 DB name
-	row, err := s.db.queryContext(ctx, q)
+	rows, err := mgr.Merge(ctx, rowsList)
 	if err != nil {
+		closeRows(rowsList)
 		return nil, err
 	}
-	if !row.Next() {
+	defer rows.Close()
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		// 数据可能在查询失败的目标表上
+		if partialErr != nil {
+			return nil, partialErr
+		}
 		return nil, ErrNoRows
 	}
 	tp := new(T)
 	val := s.valCreator.NewPrimitiveValue(tp, s.meta)
-	if err = val.SetColumns(row); err != nil {
+	if err = val.SetColumns(rows); err != nil {
 		return nil, err
 	}
-	return tp, nil
+	return tp, partialErr
 }
 
 func (s *ShardingSelector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	qs, err := s.Build(ctx)
+	dsts, qs, err := s.build(ctx, false)
 	if err != nil {
 		return nil, err
 	}
-
-	mgr := batchmerger.NewMerger()
-	rowsList, err := s.db.queryMulti(ctx, qs)
+	if len(qs) == 0 {
+		return []*T{}, nil
+	}
+	mgr, err := s.getMerger(len(qs))
 	if err != nil {
 		return nil, err
 	}
-	rows, err := mgr.Merge(ctx, rowsList.AsSlice())
+	rowsList, partialErr := s.queryMulti(ctx, dsts, qs)
+	if partialErr != nil && len(rowsList) == 0 {
+		return nil, partialErr
+	}
+	rows, err := mgr.Merge(ctx, rowsList)
 	if err != nil {
+		closeRows(rowsList)
 		return nil, err
 	}
 	defer rows.Close()
@@ -321,9 +575,372 @@ func (s *ShardingSelector[T]) GetMulti(ctx context.Context) ([]*T, error) {
 		}
 		res = append(res, tp)
 	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, partialErr
+}
+
+// Iter 和 GetMulti 一样执行查询，但是返回的是游标，遍历的时候才从各个目标表的结果集里面归并下一行数据。
+// 注意 GROUP BY 和聚合函数依旧需要在内存里面读取全部分片的数据才能计算出结果。
+// best effort 模式下返回的 error 可能是 *sharding.PartialError，这个时候游标里面是查询成功的目标表的数据
+func (s *ShardingSelector[T]) Iter(ctx context.Context) (*Iterator[T], error) {
+	dsts, qs, err := s.build(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(qs) == 0 {
+		return newIterator[T](rows.NewDataRows(nil, nil, nil), s.core, s.meta), nil
+	}
+	mgr, err := s.getMerger(len(qs))
+	if err != nil {
+		return nil, err
+	}
+	rowsList, partialErr := s.queryMulti(ctx, dsts, qs)
+	if partialErr != nil && len(rowsList) == 0 {
+		return nil, partialErr
+	}
+	rs, err := mgr.Merge(ctx, rowsList)
+	if err != nil {
+		closeRows(rowsList)
+		return nil, err
+	}
+	return newIterator[T](rs, s.core, s.meta), partialErr
+}
+
+// BestEffort 开启 best effort 模式。
+// 默认情况下，只要有一个目标表查询失败，就会取消其它目标表上的查询并且返回错误；
+// 开启之后会合并查询成功的目标表的结果，同时返回 *sharding.PartialError。
+// 事务里面的查询不支持 best effort 模式，依旧是任何一个目标表失败都会返回错误
+func (s *ShardingSelector[T]) BestEffort() *ShardingSelector[T] {
+	s.bestEffort = true
+	return s
+}
+
+// ForUpdate 使用 SELECT ... FOR UPDATE 锁住查询到的数据，需要在事务里面执行。
+// SQLite 不支持 FOR UPDATE，它的写事务本身就是串行的，所以会忽略这个设置
+func (s *ShardingSelector[T]) ForUpdate() *ShardingSelector[T] {
+	s.forUpdate = true
+	return s
+}
+
+// closeRows 在 Merge 失败的时候关闭全部结果集，同时也会释放 queryAll 的 context
+func closeRows(rowsList []rows.Rows) {
+	for _, r := range rowsList {
+		_ = r.Close()
+	}
+}
+
+// queryMulti 在每个目标表上执行查询。
+// 非 best effort 模式下返回的 error 不为 nil 的时候结果集一定为空；
+// best effort 模式下只返回查询成功的结果集，失败的目标表记录在 *sharding.PartialError 里面
+func (s *ShardingSelector[T]) queryMulti(ctx context.Context, dsts []sharding.Dst, qs []Query) ([]rows.Rows, error) {
+	if _, ok := s.db.(*Tx); ok || !s.bestEffort {
+		rowsList, err := s.db.queryMulti(ctx, qs)
+		if err != nil {
+			return nil, err
+		}
+		return rowsList.AsSlice(), nil
+	}
+	rowsList, errList, err := queryAll(ctx, s.db, s.maxParallelism, qs, true)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]rows.Rows, 0, len(rowsList))
+	for _, rs := range rowsList {
+		if rs != nil {
+			res = append(res, rs)
+		}
+	}
+	return res, newPartialError(dsts, errList)
+}
+
+// getMerger 根据查询的特征选择合适的 merger
+// 只命中一个分片的时候，数据库已经完成了排序和分页，直接返回结果。
+// 跨分片的聚合查询使用 aggregatemerger 或者 groupby_merger 计算最终结果，
+// 如果用户指定了 ORDER BY，那么聚合之后再在内存里面排序；
+// 非聚合查询指定了 ORDER BY 的时候使用 sortmerger 归并各个分片有序的结果，否则直接拼接各个分片的结果。
+// DISTINCT 的时候再使用 distinctmerger 去掉不同分片之间重复的数据。
+// 命中多个分片并且有 OFFSET 或者 LIMIT 的时候，在上面再套一层 pagedmerger
+func (s *ShardingSelector[T]) getMerger(shardCnt int) (merger.Merger, error) {
+	var mgr merger.Merger = batchmerger.NewMerger()
+	if shardCnt <= 1 {
+		return mgr, nil
+	}
+	if s.isAggregateQuery() {
+		aggMgr, err := s.aggregateMerger()
+		if err != nil {
+			return nil, err
+		}
+		mgr = aggMgr
+		if len(s.orderBy) > 0 {
+			sortCols, err := s.sortColumns()
+			if err != nil {
+				return nil, err
+			}
+			if mgr, err = sortmerger.NewMemoryMerger(aggMgr, sortCols...); err != nil {
+				return nil, err
+			}
+		}
+	} else if len(s.orderBy) > 0 {
+		sortCols, err := s.sortColumns()
+		if err != nil {
+			return nil, err
+		}
+		if mgr, err = sortmerger.NewMerger(sortCols...); err != nil {
+			return nil, err
+		}
+	}
+	if s.distinct {
+		mgr = s.distinctMerger(mgr)
+	}
+	if s.offset > 0 || s.limit > 0 {
+		limit := s.limit
+		if limit <= 0 {
+			limit = math.MaxInt
+		}
+		return pagedmerger.NewMerger(mgr, s.offset, limit)
+	}
+	return mgr, nil
+}
+
+// distinctMerger 构造跨分片去重的 merger。
+// 如果 ORDER BY 覆盖了所有查询的列，那么重复的数据在排序之后一定是相邻的，可以流式去重；
+// 否则只能使用哈希表去重
+func (s *ShardingSelector[T]) distinctMerger(mgr merger.Merger) merger.Merger {
+	if !s.isAggregateQuery() && s.orderByAllColumns() {
+		return distinctmerger.NewSortedMerger(mgr)
+	}
+	return distinctmerger.NewMerger(mgr)
+}
+
+// orderByAllColumns 判断 ORDER BY 是否覆盖了所有查询的列
+func (s *ShardingSelector[T]) orderByAllColumns() bool {
+	if len(s.orderBy) == 0 {
+		return false
+	}
+	ordered := make(map[string]struct{}, len(s.orderBy))
+	for _, ob := range s.orderBy {
+		for _, c := range ob.fields {
+			ordered[c] = struct{}{}
+		}
+	}
+	selected := make([]string, 0, len(s.meta.Columns))
+	if len(s.columns) == 0 {
+		for _, c := range s.meta.Columns {
+			selected = append(selected, c.FieldName)
+		}
+	}
+	for _, selectable := range s.columns {
+		switch c := selectable.(type) {
+		case Column:
+			selected = append(selected, c.name)
+		case columns:
+			selected = append(selected, c.cs...)
+		default:
+			return false
+		}
+	}
+	for _, c := range selected {
+		if _, ok := ordered[c]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// aggregateMerger 构造跨分片聚合查询的 merger。
+// 没有 GROUP BY 的时候使用 aggregatemerger，否则使用 groupby_merger。
+// 如果有 HAVING，那么在最外层套一个 havingmerger
+func (s *ShardingSelector[T]) aggregateMerger() (merger.Merger, error) {
+	aggs, selectedAggs, err := s.aggregators()
+	if err != nil {
+		return nil, err
+	}
+	var mgr merger.Merger
+	var groupCols []merger.ColumnInfo
+	if len(s.groupBy) > 0 {
+		groupCols, err = s.groupColumns()
+		if err != nil {
+			return nil, err
+		}
+		mgr = groupby_merger.NewAggregatorMerger(aggs, groupCols)
+	} else {
+		mgr = aggregatemerger.NewMerger(aggs...)
+	}
+	if len(s.having) == 0 {
+		return mgr, nil
+	}
+	predicate, err := s.havingPredicate(groupCols, append(selectedAggs, s.havingAggregates()...))
+	if err != nil {
+		return nil, err
+	}
+	return havingmerger.NewMerger(mgr, predicate, len(groupCols)+len(selectedAggs)), nil
+}
+
+// aggregators 按照 SELECT 的列以及 HAVING 中额外查询的列构造聚合函数。
+// 下标是聚合函数在分片结果中的位置，AVG 在分片上被改写成了 SUM 和 COUNT 两列。
+// 第二个返回值是用户在 SELECT 中指定的聚合函数
+func (s *ShardingSelector[T]) aggregators() ([]aggregator.Aggregator, []Aggregate, error) {
+	indexes, idx := s.selectedIndexes()
+	selected := make([]Aggregate, 0, len(s.columns))
+	res := make([]aggregator.Aggregator, 0, len(s.columns))
+	for i, selectable := range s.columns {
+		agg, ok := selectable.(Aggregate)
+		if !ok {
+			continue
+		}
+		a, err := s.newAggregator(agg, indexes[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		selected = append(selected, agg)
+		res = append(res, a)
+	}
+	for _, agg := range s.havingAggregates() {
+		a, err := s.newAggregator(agg, idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, a)
+		idx += aggregateWidth(agg)
+	}
+	return res, selected, nil
+}
+
+func (s *ShardingSelector[T]) newAggregator(agg Aggregate, idx int) (aggregator.Aggregator, error) {
+	cMeta, ok := s.meta.FieldMap[agg.arg]
+	if !ok {
+		return nil, errs.NewInvalidFieldError(agg.arg)
+	}
+	name := agg.alias
+	if name == "" {
+		name = fmt.Sprintf("%s(%s)", agg.fn, cMeta.ColumnName)
+	}
+	info := merger.NewColumnInfo(idx, name)
+	switch agg.fn {
+	case "COUNT":
+		return aggregator.NewCount(info), nil
+	case "SUM":
+		return aggregator.NewSum(info), nil
+	case "MIN":
+		return aggregator.NewMin(info), nil
+	case "MAX":
+		return aggregator.NewMax(info), nil
+	case "AVG":
+		return aggregator.NewAVG(info, merger.NewColumnInfo(idx+1, name), name), nil
+	default:
+		return nil, errs.NewUnsupportedAggregateError(agg.fn)
+	}
+}
+
+// groupColumns 找到 GROUP BY 的列在分片结果中的位置。
+// GROUP BY 的列必须出现在 SELECT 里面
+func (s *ShardingSelector[T]) groupColumns() ([]merger.ColumnInfo, error) {
+	res := make([]merger.ColumnInfo, 0, len(s.groupBy))
+	indexes, _ := s.selectedIndexes()
+	for _, gb := range s.groupBy {
+		cMeta, ok := s.meta.FieldMap[gb]
+		if !ok {
+			return nil, errs.NewInvalidFieldError(gb)
+		}
+		info, ok := s.findSelectedColumn(indexes, gb)
+		if !ok {
+			return nil, errs.NewErrGroupByColumnNotSelected(gb)
+		}
+		if info.Name == "" {
+			info.Name = cMeta.ColumnName
+		}
+		res = append(res, info)
+	}
 	return res, nil
 }
 
+func (s *ShardingSelector[T]) findSelectedColumn(indexes []int, field string) (merger.ColumnInfo, bool) {
+	if len(s.columns) == 0 {
+		for i, c := range s.meta.Columns {
+			if c.FieldName == field {
+				return merger.NewColumnInfo(i, ""), true
+			}
+		}
+		return merger.ColumnInfo{}, false
+	}
+	for i, selectable := range s.columns {
+		switch c := selectable.(type) {
+		case Column:
+			if c.name == field {
+				return merger.NewColumnInfo(indexes[i], c.alias), true
+			}
+		case columns:
+			for j, name := range c.cs {
+				if name == field {
+					return merger.NewColumnInfo(indexes[i]+j, ""), true
+				}
+			}
+		}
+	}
+	return merger.ColumnInfo{}, false
+}
+
+// selectedIndexes 返回 SELECT 中每一项在分片结果中的起始下标，以及它们一共占据的列数
+func (s *ShardingSelector[T]) selectedIndexes() ([]int, int) {
+	if len(s.columns) == 0 {
+		return nil, len(s.meta.Columns)
+	}
+	res := make([]int, 0, len(s.columns))
+	idx := 0
+	for _, selectable := range s.columns {
+		res = append(res, idx)
+		switch c := selectable.(type) {
+		case columns:
+			idx += len(c.cs)
+		case Aggregate:
+			idx += aggregateWidth(c)
+		default:
+			idx++
+		}
+	}
+	return res, idx
+}
+
+// aggregateWidth 聚合函数在分片结果中占据的列数
+func aggregateWidth(agg Aggregate) int {
+	if agg.fn == "AVG" {
+		return 2
+	}
+	return 1
+}
+
+// sortMerger 将 OrderBy 转化为 sortmerger 的排序列。
+// 注意排序列必须出现在查询的列里面
+func (s *ShardingSelector[T]) sortColumns() ([]sortmerger.SortColumn, error) {
+	sortCols := make([]sortmerger.SortColumn, 0, len(s.orderBy))
+	indexes, _ := s.selectedIndexes()
+	for _, ob := range s.orderBy {
+		order := sortmerger.ASC
+		if ob.order == "DESC" {
+			order = sortmerger.DESC
+		}
+		for _, c := range ob.fields {
+			cMeta, ok := s.meta.FieldMap[c]
+			if !ok {
+				return nil, errs.NewInvalidFieldError(c)
+			}
+			// 跨分片归并的时候只能按照结果集里面的列排序，列设置了别名的时候结果集里面是别名
+			info, ok := s.findSelectedColumn(indexes, c)
+			if !ok {
+				return nil, errs.NewErrOrderByColumnNotSelected(c)
+			}
+			name := info.Name
+			if name == "" {
+				name = cMeta.ColumnName
+			}
+			sortCols = append(sortCols, sortmerger.NewSortColumn(name, order))
+		}
+	}
+	return sortCols, nil
+}
+
 // Select 指定查询的列。
 // 列可以是物理列，也可以是聚合函数，或者 RawExpr
 func (s *ShardingSelector[T]) Select(columns ...Selectable) *ShardingSelector[T] {
@@ -333,6 +950,13 @@ func (s *ShardingSelector[T]) Select(columns ...Selectable) *ShardingSelector[T]
 
 // From specifies the table which must be pointer of structure
 func (s *ShardingSelector[T]) From(tbl *T) *ShardingSelector[T] {
+	s.table = nil
+	return s
+}
+
+// FromJoin 指定查询的表，可以是 JOIN，也可以是带别名的表。
+// JOIN 的表必须和 T 对应的主表在同一个绑定表分组里面，或者是广播表
+func (s *ShardingSelector[T]) FromJoin(tbl TableReference) *ShardingSelector[T] {
 	s.table = tbl
 	return s
 }
@@ -343,6 +967,13 @@ func (s *ShardingSelector[T]) Where(predicates ...Predicate) *ShardingSelector[T
 	return s
 }
 
+// Distinct indicates using keyword DISTINCT
+// 命中多个分片的时候，会在归并之后再去重一次
+func (s *ShardingSelector[T]) Distinct() *ShardingSelector[T] {
+	s.distinct = true
+	return s
+}
+
 // Having accepts predicates
 func (s *ShardingSelector[T]) Having(predicates ...Predicate) *ShardingSelector[T] {
 	s.having = predicates
@@ -18,7 +18,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"testing"
+	"time"
 
 	"github.com/ecodeclub/eorm/internal/datasource/masterslave/slaves/roundrobin"
 
@@ -32,8 +34,12 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/ecodeclub/eorm/internal/datasource"
 	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/merger/sortmerger"
 	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/composite"
+	"github.com/ecodeclub/eorm/internal/sharding/datetime"
 	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/ecodeclub/eorm/internal/sharding/ranges"
 	"github.com/ecodeclub/eorm/internal/test"
 
 	"github.com/ecodeclub/eorm/internal/model"
@@ -4570,6 +4576,21 @@ func TestShardingSelector_Build(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "for update ignored by sqlite",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(C("UserId").EQ(123)).ForUpdate()
+				return s
+			}(),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `user_id`,`order_id`,`content`,`account` FROM `order_db_1`.`order_tab_0` WHERE `user_id`=?;",
+					Args:       []any{123},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
+		},
 		{
 			name: "only eq broadcast",
 			builder: func() sharding.QueryBuilder {
@@ -5764,177 +5785,530 @@ func TestShardingSelector_Build(t *testing.T) {
 			}(),
 			qs: []sharding.Query{},
 		},
-	}
-
-	for _, tc := range testCases {
-		c := tc
-		t.Run(c.name, func(t *testing.T) {
-			qs, err := c.builder.Build(context.Background())
-			assert.Equal(t, c.wantErr, err)
-			if err != nil {
-				return
-			}
-			assert.ElementsMatch(t, c.qs, qs)
-		})
-	}
-}
-
-func TestShardingSelector_Build_Error(t *testing.T) {
-	r := model.NewMetaRegistry()
-	dbBase, tableBase := 2, 3
-	dbPattern, tablePattern, dsPattern := "order_db_%d", "order_tab_%d", "0.db.cluster.company.com:3306"
-	_, err := r.Register(&Order{},
-		model.WithTableShardingAlgorithm(&hash.Hash{
-			ShardingKey:  "UserId",
-			DBPattern:    &hash.Pattern{Name: dbPattern, Base: dbBase},
-			TablePattern: &hash.Pattern{Name: tablePattern, Base: tableBase},
-			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
-		}))
-	require.NoError(t, err)
-
-	m := map[string]*masterslave.MasterSlavesDB{
-		"order_db_0": MasterSlavesMemoryDB(),
-		"order_db_1": MasterSlavesMemoryDB(),
-		"order_db_2": MasterSlavesMemoryDB(),
-	}
-	clusterDB := cluster.NewClusterDB(m)
-	ds := map[string]datasource.DataSource{
-		"0.db.cluster.company.com:3306": clusterDB,
-	}
-	shardingDB, err := OpenDS("sqlite3",
-		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
-	require.NoError(t, err)
-
-	testCases := []struct {
-		name    string
-		builder sharding.QueryBuilder
-		qs      []sharding.Query
-		wantErr error
-	}{
 		{
-			name: "not and left too complex operator",
+			name: "select from join alias",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Where(Not(C("Content").
-					Like("%kfc").And(C("OrderId").EQ(101))))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).FromJoin(TableOf(&Order{}, "o")).
+					Where(C("UserId").EQ(123))
 				return s
 			}(),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` AS `o` WHERE `user_id`=?;",
+					Args:       []any{123},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "not or left too complex operator",
+			name: "where multiple predicates",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Where(Not(C("Content").
-					Like("%kfc").Or(C("OrderId").EQ(101))))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("UserId").EQ(123), C("OrderId").EQ(5), C("UserId").EQ(234))
 				return s
 			}(),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+			qs: []sharding.Query{},
 		},
 		{
-			name: "not and right too complex operator",
+			name: "where multiple predicates single shard",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Where(Not(C("OrderId").
-					EQ(101).And(C("Content").Like("%kfc"))))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("OrderId").EQ(5), C("Content").EQ("1"), C("UserId").EQ(123))
 				return s
 			}(),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` WHERE ((`order_id`=?) AND (`content`=?)) AND (`user_id`=?);",
+					Args:       []any{5, "1", 123},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "not or right too complex operator",
+			name: "offset limit single shard",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Where(Not(C("OrderId").
-					EQ(101).Or(C("Content").Like("%kfc"))))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("UserId").EQ(123)).Offset(10).Limit(20)
 				return s
 			}(),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` WHERE `user_id`=? LIMIT ? OFFSET ?;",
+					Args:       []any{123, 20, 10},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "invalid field err",
+			name: "limit single shard",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Select(C("ccc"))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("UserId").EQ(123)).Limit(20)
 				return s
 			}(),
-			wantErr: errs.NewInvalidFieldError("ccc"),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` WHERE `user_id`=? LIMIT ?;",
+					Args:       []any{123, 20},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "group by invalid field err",
+			name: "offset single shard",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Select(C("UserId")).GroupBy("ccc")
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("UserId").EQ(123)).Offset(10)
 				return s
 			}(),
-			wantErr: errs.NewInvalidFieldError("ccc"),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` WHERE `user_id`=? LIMIT ? OFFSET ?;",
+					Args:       []any{123, math.MaxInt, 10},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "order by invalid field err",
+			name: "offset limit multi shards",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Select(C("UserId")).OrderBy(ASC("ccc"))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234))).
+					OrderBy(ASC("OrderId")).Offset(10).Limit(20)
 				return s
 			}(),
-			wantErr: errs.NewInvalidFieldError("ccc"),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?) ORDER BY `order_id` ASC LIMIT ?;",
+					Args:       []any{123, 234, 30},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?) ORDER BY `order_id` ASC LIMIT ?;",
+					Args:       []any{123, 234, 30},
+					DB:         "order_db_0",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "pointer only err",
+			name: "aggregate single shard",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[int64](shardingDB)
+				s := NewShardingSelector[Order](shardingDB).
+					Select(Avg("Account").As("account")).Where(C("UserId").EQ(123))
 				return s
 			}(),
-			wantErr: errs.ErrPointerOnly,
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT AVG(`account`) AS `account` FROM `order_db_1`.`order_tab_0` WHERE `user_id`=?;",
+					Args:       []any{123},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "too complex operator",
+			name: "aggregate multi shards",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Where(C("Content").Like("%kfc"))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(Count("OrderId"), Avg("Account").As("account")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234)))
 				return s
 			}(),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT COUNT(`order_id`),SUM(`account`),COUNT(`account`) FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);",
+					Args:       []any{123, 234},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+				{
+					SQL:        "SELECT COUNT(`order_id`),SUM(`account`),COUNT(`account`) FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);",
+					Args:       []any{123, 234},
+					DB:         "order_db_0",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 		{
-			name: "too complex expr",
+			name: "aggregate mixed with columns multi shards",
 			builder: func() sharding.QueryBuilder {
-				s := NewShardingSelector[Order](shardingDB).Where(Avg("UserId").EQ(1))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("Content"), Count("OrderId")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234)))
 				return s
 			}(),
-			wantErr: errs.ErrUnsupportedTooComplexQuery,
+			wantErr: errs.ErrAggregateMixedWithColumns,
 		},
 		{
-			name: "miss sharding key err",
+			name: "count distinct multi shards",
 			builder: func() sharding.QueryBuilder {
-				reg := model.NewMetaRegistry()
-				meta, err := reg.Register(&Order{},
-					model.WithTableShardingAlgorithm(&hash.Hash{}))
-				require.NoError(t, err)
-				require.NotNil(t, meta.ShardingAlgorithm)
-				db, err := OpenDS("sqlite3",
-					shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
-						"0.db.cluster.company.com:3306": MasterSlavesMemoryDB(),
-					}),
-					DBWithMetaRegistry(reg))
-				require.NoError(t, err)
-				s := NewShardingSelector[Order](db).Where(C("UserId").EQ(123))
+				s := NewShardingSelector[Order](shardingDB).
+					Select(CountDistinct("OrderId")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234)))
 				return s
 			}(),
-			wantErr: errs.ErrMissingShardingKey,
+			wantErr: errs.NewErrUnsupportedDistinctAggregate("COUNT"),
+		},
+		{
+			name: "group by having order by single shard",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("Content"), Sum("Account")).
+					Where(C("UserId").EQ(123)).
+					GroupBy("Content").Having(Sum("Account").GT(100)).
+					OrderBy(ASC("Content")).Limit(10)
+				return s
+			}(),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `content`,SUM(`account`) FROM `order_db_1`.`order_tab_0` WHERE `user_id`=? GROUP BY `content` HAVING SUM(`account`)>? ORDER BY `content` ASC LIMIT ?;",
+					Args:       []any{123, 100, 10},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
+		},
+		{
+			name: "group by having multi shards",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("Content"), Avg("Account").As("account")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234))).
+					GroupBy("Content").Having(Count("OrderId").GT(2)).Limit(10)
+				return s
+			}(),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `content`,SUM(`account`),COUNT(`account`),COUNT(`order_id`) FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?) GROUP BY `content`;",
+					Args:       []any{123, 234},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+				{
+					SQL:        "SELECT `content`,SUM(`account`),COUNT(`account`),COUNT(`order_id`) FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?) GROUP BY `content`;",
+					Args:       []any{123, 234},
+					DB:         "order_db_0",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
+		},
+		{
+			name: "only offset multi shards",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).
+					Select(C("OrderId"), C("Content")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234))).Offset(10)
+				return s
+			}(),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);",
+					Args:       []any{123, 234},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+				{
+					SQL:        "SELECT `order_id`,`content` FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?);",
+					Args:       []any{123, 234},
+					DB:         "order_db_0",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
+		}, {
+			name: "distinct multi shards",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Distinct().
+					Select(C("Content")).
+					Where(C("UserId").EQ(123).Or(C("UserId").EQ(234))).
+					OrderBy(ASC("Content")).Offset(5).Limit(10)
+				return s
+			}(),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT DISTINCT `content` FROM `order_db_1`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?) ORDER BY `content` ASC LIMIT ?;",
+					Args:       []any{123, 234, 15},
+					DB:         "order_db_1",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+				{
+					SQL:        "SELECT DISTINCT `content` FROM `order_db_0`.`order_tab_0` WHERE (`user_id`=?) OR (`user_id`=?) ORDER BY `content` ASC LIMIT ?;",
+					Args:       []any{123, 234, 15},
+					DB:         "order_db_0",
+					Datasource: "0.db.cluster.company.com:3306",
+				},
+			},
 		},
 	}
 
 	for _, tc := range testCases {
 		c := tc
 		t.Run(c.name, func(t *testing.T) {
-			_, err = c.builder.Build(context.Background())
+			qs, err := c.builder.Build(context.Background())
 			assert.Equal(t, c.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.ElementsMatch(t, c.qs, qs)
 		})
 	}
-
 }
 
-func TestShardingSelector_Get(t *testing.T) {
+func TestShardingSelector_Build_Composite(t *testing.T) {
 	r := model.NewMetaRegistry()
-	_, err := r.Register(&test.OrderDetail{},
-		model.WithTableShardingAlgorithm(&hash.Hash{
-			ShardingKey:  "OrderId",
-			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
-			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
-			DsPattern:    &hash.Pattern{Name: "0.db.slave.company.com:3306", NotSharding: true},
-		}))
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&composite.Composite{
+			Datasource: composite.Fixed{Dst: sharding.Dst{Name: dsPattern}},
+			DB: &hash.Hash{
+				ShardingKey:  "UserId",
+				DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+				TablePattern: &hash.Pattern{NotSharding: true},
+				DsPattern:    &hash.Pattern{NotSharding: true},
+			},
+			Table: &hash.Hash{
+				ShardingKey:  "OrderId",
+				DBPattern:    &hash.Pattern{NotSharding: true},
+				TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+				DsPattern:    &hash.Pattern{NotSharding: true},
+			},
+		}))
+	require.NoError(t, err)
+
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		builder sharding.QueryBuilder
+		qs      []sharding.Query
+		wantErr error
+	}{
+		{
+			name: "and",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(C("Content")).
+				Where(C("UserId").EQ(123).And(C("OrderId").EQ(int64(5)))),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `content` FROM `order_db_1`.`order_tab_2` WHERE (`user_id`=?) AND (`order_id`=?);",
+					Args:       []any{123, int64(5)},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "table key only",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(C("Content")).
+				Where(C("OrderId").EQ(int64(4))),
+			qs: []sharding.Query{
+				{
+					SQL:        "SELECT `content` FROM `order_db_0`.`order_tab_1` WHERE `order_id`=?;",
+					Args:       []any{int64(4)},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "SELECT `content` FROM `order_db_1`.`order_tab_1` WHERE `order_id`=?;",
+					Args:       []any{int64(4)},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.qs, qs)
+		})
+	}
+}
+
+func TestShardingSelector_Build_Error(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dbBase, tableBase := 2, 3
+	dbPattern, tablePattern, dsPattern := "order_db_%d", "order_tab_%d", "0.db.cluster.company.com:3306"
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: dbPattern, Base: dbBase},
+			TablePattern: &hash.Pattern{Name: tablePattern, Base: tableBase},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+		"order_db_2": MasterSlavesMemoryDB(),
+	}
+	clusterDB := cluster.NewClusterDB(m)
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		builder sharding.QueryBuilder
+		qs      []sharding.Query
+		wantErr error
+	}{
+		{
+			name: "not and left too complex operator",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(Not(Exist(NewSelector[Order](shardingDB).
+					AsSubquery("sub")).And(C("OrderId").EQ(101))))
+				return s
+			}(),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
+		},
+		{
+			name: "not or left too complex operator",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(Not(Exist(NewSelector[Order](shardingDB).
+					AsSubquery("sub")).Or(C("OrderId").EQ(101))))
+				return s
+			}(),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
+		},
+		{
+			name: "not and right too complex operator",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(Not(C("OrderId").
+					EQ(101).And(Exist(NewSelector[Order](shardingDB).AsSubquery("sub")))))
+				return s
+			}(),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
+		},
+		{
+			name: "not or right too complex operator",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(Not(C("OrderId").
+					EQ(101).Or(Exist(NewSelector[Order](shardingDB).AsSubquery("sub")))))
+				return s
+			}(),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
+		},
+		{
+			name: "invalid field err",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Select(C("ccc"))
+				return s
+			}(),
+			wantErr: errs.NewInvalidFieldError("ccc"),
+		},
+		{
+			name: "group by invalid field err",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Select(C("UserId")).GroupBy("ccc")
+				return s
+			}(),
+			wantErr: errs.NewInvalidFieldError("ccc"),
+		},
+		{
+			name: "order by invalid field err",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Select(C("UserId")).OrderBy(ASC("ccc"))
+				return s
+			}(),
+			wantErr: errs.NewInvalidFieldError("ccc"),
+		},
+		{
+			name: "pointer only err",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[int64](shardingDB)
+				return s
+			}(),
+			wantErr: errs.ErrPointerOnly,
+		},
+		{
+			name: "too complex operator",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(Exist(NewSelector[Order](shardingDB).AsSubquery("sub")))
+				return s
+			}(),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
+		},
+		{
+			name: "too complex expr",
+			builder: func() sharding.QueryBuilder {
+				s := NewShardingSelector[Order](shardingDB).Where(Avg("UserId").EQ(1))
+				return s
+			}(),
+			wantErr: errs.ErrUnsupportedTooComplexQuery,
+		},
+		{
+			name: "miss sharding key err",
+			builder: func() sharding.QueryBuilder {
+				reg := model.NewMetaRegistry()
+				meta, err := reg.Register(&Order{},
+					model.WithTableShardingAlgorithm(&hash.Hash{}))
+				require.NoError(t, err)
+				require.NotNil(t, meta.ShardingAlgorithm)
+				db, err := OpenDS("sqlite3",
+					shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+						"0.db.cluster.company.com:3306": MasterSlavesMemoryDB(),
+					}),
+					DBWithMetaRegistry(reg))
+				require.NoError(t, err)
+				s := NewShardingSelector[Order](db).Where(C("UserId").EQ(123))
+				return s
+			}(),
+			wantErr: errs.ErrMissingShardingKey,
+		},
+	}
+
+	for _, tc := range testCases {
+		c := tc
+		t.Run(c.name, func(t *testing.T) {
+			_, err = c.builder.Build(context.Background())
+			assert.Equal(t, c.wantErr, err)
+		})
+	}
+
+}
+
+func TestShardingSelector_Get(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.slave.company.com:3306", NotSharding: true},
+		}))
 	require.NoError(t, err)
 
 	mockDB, mock, err := sqlmock.New(
@@ -5974,14 +6348,14 @@ func TestShardingSelector_Get(t *testing.T) {
 			wantErr:   errs.NewInvalidFieldError("ccc"),
 		},
 		{
-			name: "not gen sharding query",
+			name: "no sharding query",
 			s: func() *ShardingSelector[test.OrderDetail] {
 				b := NewShardingSelector[test.OrderDetail](shardingDB).
 					Where(C("OrderId").EQ(12).And(C("OrderId").EQ(14)))
 				return b
 			}(),
 			mockOrder: func(mock sqlmock.Sqlmock) {},
-			wantErr:   errs.ErrNotGenShardingQuery,
+			wantErr:   ErrNoRows,
 		},
 		{
 			name: "no rows err",
@@ -6025,16 +6399,6 @@ func TestShardingSelector_Get(t *testing.T) {
 			},
 			wantErr: errs.ErrTooManyColumns,
 		},
-		{
-			name: "only result one query",
-			s: func() *ShardingSelector[test.OrderDetail] {
-				b := NewShardingSelector[test.OrderDetail](shardingDB).
-					Where(C("OrderId").EQ(123).Or(C("ItemId").EQ(12)))
-				return b
-			}(),
-			mockOrder: func(mock sqlmock.Sqlmock) {},
-			wantErr:   errs.ErrOnlyResultOneQuery,
-		},
 		{
 			name: "found tab 1",
 			s: func() *ShardingSelector[test.OrderDetail] {
@@ -6098,7 +6462,7 @@ func TestShardingSelector_Get(t *testing.T) {
 	}
 }
 
-func TestShardingSelector_GetMulti(t *testing.T) {
+func TestShardingSelector_Get_MultiShards(t *testing.T) {
 	r := model.NewMetaRegistry()
 	_, err := r.Register(&test.OrderDetail{},
 		model.WithTableShardingAlgorithm(&hash.Hash{
@@ -6120,7 +6484,6 @@ func TestShardingSelector_GetMulti(t *testing.T) {
 	require.NoError(t, err)
 	masterSlaveDB := masterslave.NewMasterSlavesDB(
 		mockDB, masterslave.MasterSlavesWithSlaves(newMockSlaveNameGet(rbSlaves)))
-	require.NoError(t, err)
 
 	mockDB2, mock2, err := sqlmock.New(
 		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -6133,7 +6496,6 @@ func TestShardingSelector_GetMulti(t *testing.T) {
 	require.NoError(t, err)
 	masterSlaveDB2 := masterslave.NewMasterSlavesDB(
 		mockDB2, masterslave.MasterSlavesWithSlaves(newMockSlaveNameGet(rbSlaves2)))
-	require.NoError(t, err)
 
 	clusterDB := cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
 		"order_detail_db_0": masterSlaveDB,
@@ -6146,77 +6508,612 @@ func TestShardingSelector_GetMulti(t *testing.T) {
 		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
 	require.NoError(t, err)
 
+	cols := []string{"order_id", "item_id", "using_col1", "using_col2"}
 	testCases := []struct {
 		name      string
 		s         *ShardingSelector[test.OrderDetail]
 		mockOrder func(mock1, mock2 sqlmock.Sqlmock)
 		wantErr   error
-		wantRes   []*test.OrderDetail
+		wantRes   *test.OrderDetail
 	}{
 		{
-			name: "invalid field err",
-			s: func() *ShardingSelector[test.OrderDetail] {
-				b := NewShardingSelector[test.OrderDetail](shardingDB).Select(C("ccc"))
-				return b
-			}(),
-			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {},
-			wantErr:   errs.NewInvalidFieldError("ccc"),
+			name: "order by desc",
+			s: NewShardingSelector[test.OrderDetail](shardingDB).
+				Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).
+				OrderBy(DESC("ItemId")),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` DESC LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock1.NewRows(cols).AddRow(234, 12, "Kevin", "Durant"))
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` DESC LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock2.NewRows(cols).AddRow(123, 15, "LeBron", "James"))
+			},
+			wantRes: &test.OrderDetail{OrderId: 123, ItemId: 15, UsingCol1: "LeBron", UsingCol2: "James"},
 		},
 		{
-			name: "multi row err",
-			s: func() *ShardingSelector[test.OrderDetail] {
-				b := NewShardingSelector[test.OrderDetail](shardingDB).
-					Where(C("OrderId").EQ(123))
-				return b
-			}(),
+			name: "one shard empty",
+			s: NewShardingSelector[test.OrderDetail](shardingDB).
+				Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))),
 			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
-				rows := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2", "using_col3"}).
-					AddRow(123, 10, "LeBron", "James", "de")
-				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE `order_id`=?;").
-					WithArgs(123).WillReturnRows(rows)
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock1.NewRows(cols))
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock2.NewRows(cols).AddRow(123, 10, "LeBron", "James"))
 			},
-			wantErr: errs.ErrTooManyColumns,
+			wantRes: &test.OrderDetail{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
 		},
 		{
-			name: "found tab or",
-			s: func() *ShardingSelector[test.OrderDetail] {
-				b := NewShardingSelector[test.OrderDetail](shardingDB).
-					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
-				return b
-			}(),
+			name: "all shards empty",
+			s: NewShardingSelector[test.OrderDetail](shardingDB).
+				Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))),
 			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
-				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
-				rows1.AddRow(234, 12, "Kevin", "Durant")
-				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
-					WithArgs(123, 234).WillReturnRows(rows1)
-				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
-				rows2.AddRow(123, 10, "LeBron", "James")
-				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
-					WithArgs(123, 234).WillReturnRows(rows2)
-			},
-			wantRes: []*test.OrderDetail{
-				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
-				{OrderId: 234, ItemId: 12, UsingCol1: "Kevin", UsingCol2: "Durant"},
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock1.NewRows(cols))
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock2.NewRows(cols))
 			},
+			wantErr: ErrNoRows,
 		},
 		{
-			name: "err merge rows diff",
-			s: func() *ShardingSelector[test.OrderDetail] {
-				b := NewShardingSelector[test.OrderDetail](shardingDB).
-					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
-				return b
-			}(),
+			name: "query err",
+			s: NewShardingSelector[test.OrderDetail](shardingDB).
+				Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnRows(mock1.NewRows(cols))
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) LIMIT ?;").
+					WithArgs(123, 234, 1).
+					WillReturnError(errors.New("query exception"))
+			},
+			wantErr: errors.New("query exception"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockOrder(mock, mock2)
+			res, err := tc.s.Get(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestShardingSelector_GetMulti(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	mockDB, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB.Close() }()
+
+	rbSlaves, err := roundrobin.NewSlaves(mockDB)
+	require.NoError(t, err)
+	masterSlaveDB := masterslave.NewMasterSlavesDB(
+		mockDB, masterslave.MasterSlavesWithSlaves(newMockSlaveNameGet(rbSlaves)))
+	require.NoError(t, err)
+
+	mockDB2, mock2, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = mockDB2.Close() }()
+
+	rbSlaves2, err := roundrobin.NewSlaves(mockDB2)
+	require.NoError(t, err)
+	masterSlaveDB2 := masterslave.NewMasterSlavesDB(
+		mockDB2, masterslave.MasterSlavesWithSlaves(newMockSlaveNameGet(rbSlaves2)))
+	require.NoError(t, err)
+
+	clusterDB := cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+		"order_detail_db_0": masterSlaveDB,
+		"order_detail_db_1": masterSlaveDB2,
+	})
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("mysql",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		s         *ShardingSelector[test.OrderDetail]
+		mockOrder func(mock1, mock2 sqlmock.Sqlmock)
+		wantErr   error
+		wantRes   []*test.OrderDetail
+		// ordered 表示结果必须严格按照 wantRes 的顺序
+		ordered bool
+	}{
+		{
+			name: "invalid field err",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).Select(C("ccc"))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {},
+			wantErr:   errs.NewInvalidFieldError("ccc"),
+		},
+		{
+			name: "multi row err",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2", "using_col3"}).
+					AddRow(123, 10, "LeBron", "James", "de")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE `order_id`=?;").
+					WithArgs(123).WillReturnRows(rows)
+			},
+			wantErr: errs.ErrTooManyColumns,
+		},
+		{
+			name: "found tab or",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows1.AddRow(234, 12, "Kevin", "Durant")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows2.AddRow(123, 10, "LeBron", "James")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows2)
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+				{OrderId: 234, ItemId: 12, UsingCol1: "Kevin", UsingCol2: "Durant"},
+			},
+		},
+		{
+			name: "err merge rows diff",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "ite_id", "using_col1", "using_col2"})
+				rows1.AddRow(234, 12, "Kevin", "Durant")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows2.AddRow(123, 10, "LeBron", "James")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows2)
+			},
+			wantErr: errors.New("merger: sql.Rows列表中的字段不同"),
+		},
+		{
+			name: "order by invalid sort column",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).Select(C("OrderId")).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).OrderBy(ASC("ItemId"))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {},
+			wantErr:   errs.NewErrOrderByColumnNotSelected("ItemId"),
+		},
+		{
+			name: "single shard order by column not selected",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).Select(C("OrderId"), C("UsingCol1")).
+					Where(C("OrderId").EQ(123)).OrderBy(DESC("ItemId"))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows := mock2.NewRows([]string{"order_id", "using_col1"})
+				rows.AddRow(123, "Kyrie").AddRow(123, "LeBron")
+				mock2.ExpectQuery("SELECT `order_id`,`using_col1` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE `order_id`=? ORDER BY `item_id` DESC;").
+					WithArgs(123).WillReturnRows(rows)
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, UsingCol1: "Kyrie"},
+				{OrderId: 123, UsingCol1: "LeBron"},
+			},
+			ordered: true,
+		},
+		{
+			name: "order by asc",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).OrderBy(ASC("ItemId"))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows1.AddRow(234, 11, "Kevin", "Durant").AddRow(234, 14, "Stephen", "Curry")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows2.AddRow(123, 10, "LeBron", "James").AddRow(123, 12, "Kyrie", "Irving")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnRows(rows2)
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+				{OrderId: 234, ItemId: 11, UsingCol1: "Kevin", UsingCol2: "Durant"},
+				{OrderId: 123, ItemId: 12, UsingCol1: "Kyrie", UsingCol2: "Irving"},
+				{OrderId: 234, ItemId: 14, UsingCol1: "Stephen", UsingCol2: "Curry"},
+			},
+			ordered: true,
+		},
+		{
+			name: "order by desc",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).OrderBy(DESC("ItemId"))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows1.AddRow(234, 14, "Stephen", "Curry").AddRow(234, 11, "Kevin", "Durant")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` DESC;").
+					WithArgs(123, 234).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows2.AddRow(123, 12, "Kyrie", "Irving").AddRow(123, 10, "LeBron", "James")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` DESC;").
+					WithArgs(123, 234).WillReturnRows(rows2)
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 234, ItemId: 14, UsingCol1: "Stephen", UsingCol2: "Curry"},
+				{OrderId: 123, ItemId: 12, UsingCol1: "Kyrie", UsingCol2: "Irving"},
+				{OrderId: 234, ItemId: 11, UsingCol1: "Kevin", UsingCol2: "Durant"},
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+			},
+			ordered: true,
+		},
+		{
+			name: "order by offset limit",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).
+					OrderBy(ASC("ItemId")).Offset(1).Limit(2)
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows1.AddRow(234, 11, "Kevin", "Durant").AddRow(234, 14, "Stephen", "Curry").
+					AddRow(234, 15, "Klay", "Thompson")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC LIMIT ?;").
+					WithArgs(123, 234, 3).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows2.AddRow(123, 10, "LeBron", "James").AddRow(123, 12, "Kyrie", "Irving").
+					AddRow(123, 13, "Anthony", "Davis")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC LIMIT ?;").
+					WithArgs(123, 234, 3).WillReturnRows(rows2)
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 234, ItemId: 11, UsingCol1: "Kevin", UsingCol2: "Durant"},
+				{OrderId: 123, ItemId: 12, UsingCol1: "Kyrie", UsingCol2: "Irving"},
+			},
+			ordered: true,
+		},
+		{
+			name: "distinct",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).Distinct().
+					Select(C("UsingCol1"), C("UsingCol2")).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"using_col1", "using_col2"})
+				rows1.AddRow("Kevin", "Durant").AddRow("Stephen", "Curry")
+				mock1.ExpectQuery("SELECT DISTINCT `using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"using_col1", "using_col2"})
+				rows2.AddRow("Stephen", "Curry").AddRow("Kevin", "Love")
+				mock2.ExpectQuery("SELECT DISTINCT `using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows2)
+			},
+			wantRes: []*test.OrderDetail{
+				{UsingCol1: "Kevin", UsingCol2: "Durant"},
+				{UsingCol1: "Stephen", UsingCol2: "Curry"},
+				{UsingCol1: "Kevin", UsingCol2: "Love"},
+			},
+		},
+		{
+			name: "distinct order by limit",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).Distinct().
+					Select(C("UsingCol1")).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).
+					OrderBy(ASC("UsingCol1")).Offset(1).Limit(2)
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"using_col1"})
+				rows1.AddRow("Anthony").AddRow("Kevin").AddRow("Stephen")
+				mock1.ExpectQuery("SELECT DISTINCT `using_col1` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `using_col1` ASC LIMIT ?;").
+					WithArgs(123, 234, 3).WillReturnRows(rows1)
+				rows2 := mock2.NewRows([]string{"using_col1"})
+				rows2.AddRow("Anthony").AddRow("Kevin").AddRow("LeBron")
+				mock2.ExpectQuery("SELECT DISTINCT `using_col1` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `using_col1` ASC LIMIT ?;").
+					WithArgs(123, 234, 3).WillReturnRows(rows2)
+			},
+			wantRes: []*test.OrderDetail{
+				{UsingCol1: "Kevin"},
+				{UsingCol1: "LeBron"},
+			},
+			ordered: true,
+		},
+		{
+			name: "best effort",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).BestEffort()
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnError(newMockErr("db0"))
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
+				rows2.AddRow(123, 10, "LeBron", "James")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnRows(rows2)
+			},
+			wantErr: &sharding.PartialError{
+				Errs: []sharding.DstError{
+					{
+						Dst: sharding.Dst{
+							Name:  "0.db.cluster.company.com:3306",
+							DB:    "order_detail_db_0",
+							Table: "order_detail_tab_0",
+						},
+						Err: newMockErr("db0"),
+					},
+				},
+			},
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+			},
+		},
+		{
+			name: "best effort all failed",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123)).BestEffort()
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE `order_id`=?;").
+					WithArgs(123).WillReturnError(newMockErr("db1"))
+			},
+			wantErr: &sharding.PartialError{
+				Errs: []sharding.DstError{
+					{
+						Dst: sharding.Dst{
+							Name:  "0.db.cluster.company.com:3306",
+							DB:    "order_detail_db_1",
+							Table: "order_detail_tab_0",
+						},
+						Err: newMockErr("db1"),
+					},
+				},
+			},
+		},
+		{
+			// 放在最后，因为另外一个查询可能被取消，导致 mock 里面残留没有执行的查询
+			name: "fail fast",
+			s: func() *ShardingSelector[test.OrderDetail] {
+				b := NewShardingSelector[test.OrderDetail](shardingDB).
+					Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
+				return b
+			}(),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnError(newMockErr("db"))
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
+					WithArgs(123, 234).WillReturnError(newMockErr("db"))
+			},
+			wantErr: newMockErr("db"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockOrder(mock, mock2)
+			res, err := tc.s.GetMulti(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil && tc.wantRes == nil {
+				return
+			}
+			if tc.ordered {
+				assert.Equal(t, tc.wantRes, res)
+				return
+			}
+			assert.ElementsMatch(t, tc.wantRes, res)
+		})
+	}
+}
+
+func TestShardingSelector_GetMulti_Aggregate(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	mockDB, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	mockDB2, mock2, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB2.Close() }()
+
+	rbSlaves, err := roundrobin.NewSlaves(mockDB)
+	require.NoError(t, err)
+	rbSlaves2, err := roundrobin.NewSlaves(mockDB2)
+	require.NoError(t, err)
+	clusterDB := cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": masterslave.NewMasterSlavesDB(mockDB, masterslave.MasterSlavesWithSlaves(rbSlaves)),
+		"order_db_1": masterslave.NewMasterSlavesDB(mockDB2, masterslave.MasterSlavesWithSlaves(rbSlaves2)),
+	})
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("mysql",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		s         *ShardingSelector[Order]
+		mockOrder func(mock1, mock2 sqlmock.Sqlmock)
+		wantErr   error
+		wantRes   []*Order
+	}{
+		{
+			name: "count sum min max avg",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(Count("UserId").As("user_id"), Max("OrderId").As("order_id"),
+					Avg("Account").As("account")).
+				Where(C("UserId").In(123, 234)),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT COUNT(`user_id`) AS `user_id`,MAX(`order_id`) AS `order_id`,SUM(`account`),COUNT(`account`) FROM `order_db_0`.`order_tab_0` WHERE `user_id` IN (?,?);").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows([]string{"user_id", "order_id", "SUM(`account`)", "COUNT(`account`)"}).
+						AddRow(2, int64(30), 10.0, 2))
+				mock2.ExpectQuery("SELECT COUNT(`user_id`) AS `user_id`,MAX(`order_id`) AS `order_id`,SUM(`account`),COUNT(`account`) FROM `order_db_1`.`order_tab_0` WHERE `user_id` IN (?,?);").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows([]string{"user_id", "order_id", "SUM(`account`)", "COUNT(`account`)"}).
+						AddRow(3, int64(12), 5.0, 3))
+			},
+			wantRes: []*Order{{UserId: 5, OrderId: 30, Account: 3.0}},
+		},
+		{
+			name: "empty rows",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(Count("UserId").As("user_id")).
+				Where(C("UserId").In(123, 234)),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT COUNT(`user_id`) AS `user_id` FROM `order_db_0`.`order_tab_0` WHERE `user_id` IN (?,?);").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows([]string{"user_id"}))
+				mock2.ExpectQuery("SELECT COUNT(`user_id`) AS `user_id` FROM `order_db_1`.`order_tab_0` WHERE `user_id` IN (?,?);").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows([]string{"user_id"}).AddRow(3))
+			},
+			wantErr: errors.New("merger: 聚合函数计算时rowsList有一个或多个为空"),
+		},
+		{
+			name: "group by",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(C("Content"), Sum("OrderId").As("order_id"), Avg("Account").As("account")).
+				Where(C("UserId").In(123, 234)).GroupBy("Content"),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				cols := []string{"content", "order_id", "SUM(`account`)", "COUNT(`account`)"}
+				mock1.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id`,SUM(`account`),COUNT(`account`) FROM `order_db_0`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content`;").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows(cols).AddRow("a", int64(10), 10.0, 2).AddRow("b", int64(3), 6.0, 1))
+				mock2.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id`,SUM(`account`),COUNT(`account`) FROM `order_db_1`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content`;").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows(cols).AddRow("a", int64(20), 5.0, 3))
+			},
+			wantRes: []*Order{
+				{Content: "a", OrderId: 30, Account: 3.0},
+				{Content: "b", OrderId: 3, Account: 6.0},
+			},
+		},
+		{
+			name: "group by having",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(C("Content"), Sum("OrderId").As("order_id")).
+				Where(C("UserId").In(123, 234)).GroupBy("Content").
+				Having(Count("UserId").GTEQ(3).And(Sum("OrderId").LT(100))),
 			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
-				rows1 := mock1.NewRows([]string{"order_id", "ite_id", "using_col1", "using_col2"})
-				rows1.AddRow(234, 12, "Kevin", "Durant")
-				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
-					WithArgs(123, 234).WillReturnRows(rows1)
-				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"})
-				rows2.AddRow(123, 10, "LeBron", "James")
-				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?);").
-					WithArgs(123, 234).WillReturnRows(rows2)
+				cols := []string{"content", "order_id", "COUNT(`user_id`)"}
+				mock1.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id`,COUNT(`user_id`) FROM `order_db_0`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content`;").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows(cols).AddRow("a", int64(10), 2).
+						AddRow("b", int64(3), 1).AddRow("c", int64(200), 5))
+				mock2.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id`,COUNT(`user_id`) FROM `order_db_1`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content`;").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows(cols).AddRow("a", int64(20), 1).AddRow("b", int64(7), 1))
 			},
-			wantErr: errors.New("merger: sql.Rows列表中的字段不同"),
+			wantRes: []*Order{
+				{Content: "a", OrderId: 30},
+			},
+		},
+		{
+			name: "group by order by",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(C("Content"), Sum("OrderId").As("order_id")).
+				Where(C("UserId").In(123, 234)).GroupBy("Content").OrderBy(DESC("Content")),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				cols := []string{"content", "order_id"}
+				mock1.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id` FROM `order_db_0`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content` ORDER BY `content` DESC;").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows(cols).AddRow("b", int64(1)).AddRow("a", int64(2)))
+				mock2.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id` FROM `order_db_1`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content` ORDER BY `content` DESC;").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows(cols).AddRow("z", int64(3)).AddRow("c", int64(4)).AddRow("a", int64(5)))
+			},
+			wantRes: []*Order{
+				{Content: "z", OrderId: 3},
+				{Content: "c", OrderId: 4},
+				{Content: "b", OrderId: 1},
+				{Content: "a", OrderId: 7},
+			},
+		},
+		{
+			name: "group by order by limit",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(C("Content"), Sum("OrderId").As("order_id")).
+				Where(C("UserId").In(123, 234)).GroupBy("Content").OrderBy(DESC("Content")).Limit(1),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				cols := []string{"content", "order_id"}
+				mock1.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id` FROM `order_db_0`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content` ORDER BY `content` DESC;").
+					WithArgs(123, 234).
+					WillReturnRows(mock1.NewRows(cols).AddRow("b", int64(1)))
+				mock2.ExpectQuery("SELECT `content`,SUM(`order_id`) AS `order_id` FROM `order_db_1`.`order_tab_0` WHERE `user_id` IN (?,?) GROUP BY `content` ORDER BY `content` DESC;").
+					WithArgs(123, 234).
+					WillReturnRows(mock2.NewRows(cols).AddRow("z", int64(3)))
+			},
+			wantRes: []*Order{
+				{Content: "z", OrderId: 3},
+			},
+		},
+		{
+			name: "group by column not selected",
+			s: NewShardingSelector[Order](shardingDB).
+				Select(Sum("OrderId").As("order_id")).
+				Where(C("UserId").In(123, 234)).GroupBy("Content"),
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {},
+			wantErr:   errs.NewErrGroupByColumnNotSelected("Content"),
 		},
 	}
 
@@ -6228,7 +7125,7 @@ func TestShardingSelector_GetMulti(t *testing.T) {
 			if err != nil {
 				return
 			}
-			assert.ElementsMatch(t, tc.wantRes, res)
+			assert.Equal(t, tc.wantRes, res)
 		})
 	}
 }
@@ -6257,3 +7154,481 @@ func (s *testSlaves) Next(ctx context.Context) (slaves.Slave, error) {
 	}
 	return slave, err
 }
+
+type OrderItem struct {
+	OrderId int64
+	Name    string
+}
+
+func TestShardingSelector_Build_Join(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dsPattern := "0.db.cluster.company.com:3306"
+	newHash := func(tbl string, base int) *hash.Hash {
+		return &hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: tbl, Base: base},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}
+	}
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(newHash("order_tab_%d", 3)),
+		model.WithBindingGroup("order"))
+	require.NoError(t, err)
+	_, err = r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(newHash("order_detail_tab_%d", 3)),
+		model.WithBindingGroup("order"))
+	require.NoError(t, err)
+	// 分表的数量和 order 不一样
+	_, err = r.Register(&OrderItem{},
+		model.WithTableShardingAlgorithm(newHash("order_item_tab_%d", 2)),
+		model.WithBindingGroup("order"))
+	require.NoError(t, err)
+	_, err = r.Register(&Currency{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "Id",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "currency", NotSharding: true},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}), model.WithBroadcastTable())
+	require.NoError(t, err)
+
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	shardingDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	o := TableOf(&Order{}, "o")
+	d := TableOf(&test.OrderDetail{}, "d")
+	testCases := []struct {
+		name    string
+		builder sharding.QueryBuilder
+		wantQs  []sharding.Query
+		wantErr error
+	}{
+		{
+			name: "join on",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(o.C("OrderId"), d.C("ItemId")).
+				FromJoin(o.Join(d).On(o.C("OrderId").EQ(d.C("OrderId")))).
+				Where(o.C("OrderId").EQ(7)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT `o`.`order_id`,`d`.`item_id` FROM (`order_db_1`.`order_tab_1` AS `o` JOIN `order_db_1`.`order_detail_tab_1` AS `d` ON `o`.`order_id`=`d`.`order_id`) WHERE `o`.`order_id`=?;",
+					Args:       []any{7},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "route by binding table",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(o.C("OrderId"), d.C("ItemId")).
+				FromJoin(o.LeftJoin(d).Using("OrderId")).
+				Where(d.C("OrderId").In(7, 2)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT `o`.`order_id`,`d`.`item_id` FROM (`order_db_0`.`order_tab_2` AS `o` LEFT JOIN `order_db_0`.`order_detail_tab_2` AS `d` USING (`order_id`)) WHERE `d`.`order_id` IN (?,?);",
+					Args:       []any{7, 2},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "SELECT `o`.`order_id`,`d`.`item_id` FROM (`order_db_1`.`order_tab_1` AS `o` LEFT JOIN `order_db_1`.`order_detail_tab_1` AS `d` USING (`order_id`)) WHERE `d`.`order_id` IN (?,?);",
+					Args:       []any{7, 2},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "join broadcast table",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(o.C("OrderId"), TableOf(&Currency{}, "c").C("Name")).
+				FromJoin(o.Join(TableOf(&Currency{}, "c")).On(o.C("Account").EQ(TableOf(&Currency{}, "c").C("Id")))).
+				Where(o.C("OrderId").EQ(7), TableOf(&Currency{}, "c").C("Id").EQ(3)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "SELECT `o`.`order_id`,`c`.`name` FROM (`order_db_1`.`order_tab_1` AS `o` JOIN `order_db_1`.`currency` AS `c` ON `o`.`account`=`c`.`id`) WHERE (`o`.`order_id`=?) AND (`c`.`id`=?);",
+					Args:       []any{7, 3},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "unbound table",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(o.C("OrderId")).
+				FromJoin(o.Join(TableOf(&TestModel{}, "t")).Using("Id")),
+			wantErr: errs.NewErrUnboundJoinTable("order", "test_model"),
+		},
+		{
+			name: "binding table mismatch",
+			builder: NewShardingSelector[Order](shardingDB).
+				Select(o.C("OrderId")).
+				FromJoin(o.Join(TableOf(&OrderItem{}, "i")).Using("OrderId")).
+				Where(o.C("OrderId").EQ(7)),
+			wantErr: errs.NewErrBindingTableMismatch("order_item", "order_db_1", "order_tab_1"),
+		},
+		{
+			name: "join without columns",
+			builder: NewShardingSelector[Order](shardingDB).
+				FromJoin(o.Join(d).Using("OrderId")).Where(o.C("OrderId").EQ(7)),
+			wantErr: errs.NewMustSpecifyColumnsError(),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQs, qs)
+		})
+	}
+}
+
+func TestShardingSelector_Iter(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	mockDB, mock, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	mockDB2, mock2, err := sqlmock.New(
+		sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+	require.NoError(t, err)
+	defer func() { _ = mockDB2.Close() }()
+
+	clusterDB := cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+		"order_detail_db_0": masterslave.NewMasterSlavesDB(mockDB),
+		"order_detail_db_1": masterslave.NewMasterSlavesDB(mockDB2),
+	})
+	ds := map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": clusterDB,
+	}
+	shardingDB, err := OpenDS("mysql",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		mockOrder func(mock1, mock2 sqlmock.Sqlmock)
+		// limit 是读取的行数，小于 0 表示全部读完
+		limit   int
+		wantErr error
+		wantRes []*test.OrderDetail
+	}{
+		{
+			name: "all",
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"}).
+					AddRow(234, 12, "Kevin", "Durant")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnRows(rows1).RowsWillBeClosed()
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"}).
+					AddRow(123, 10, "LeBron", "James").AddRow(123, 13, "Stephen", "Curry")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnRows(rows2).RowsWillBeClosed()
+			},
+			limit: -1,
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+				{OrderId: 234, ItemId: 12, UsingCol1: "Kevin", UsingCol2: "Durant"},
+				{OrderId: 123, ItemId: 13, UsingCol1: "Stephen", UsingCol2: "Curry"},
+			},
+		},
+		{
+			name: "break",
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				rows1 := mock1.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"}).
+					AddRow(234, 12, "Kevin", "Durant")
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnRows(rows1).RowsWillBeClosed()
+				rows2 := mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"}).
+					AddRow(123, 10, "LeBron", "James").AddRow(123, 13, "Stephen", "Curry")
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnRows(rows2).RowsWillBeClosed()
+			},
+			limit: 1,
+			wantRes: []*test.OrderDetail{
+				{OrderId: 123, ItemId: 10, UsingCol1: "LeBron", UsingCol2: "James"},
+			},
+		},
+		{
+			name: "query err",
+			mockOrder: func(mock1, mock2 sqlmock.Sqlmock) {
+				mock1.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_0`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnError(newMockErr("db"))
+				mock2.ExpectQuery("SELECT `order_id`,`item_id`,`using_col1`,`using_col2` FROM `order_detail_db_1`.`order_detail_tab_0` WHERE (`order_id`=?) OR (`order_id`=?) ORDER BY `item_id` ASC;").
+					WithArgs(123, 234).WillReturnError(newMockErr("db"))
+			},
+			wantErr: newMockErr("db"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.mockOrder(mock, mock2)
+			it, err := NewShardingSelector[test.OrderDetail](shardingDB).
+				Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234))).
+				OrderBy(ASC("ItemId")).Iter(masterslave.UseMaster(context.Background()))
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			var res []*test.OrderDetail
+			for (tc.limit < 0 || len(res) < tc.limit) && it.Next() {
+				res = append(res, it.Value())
+			}
+			require.NoError(t, it.Close())
+			assert.NoError(t, it.Err())
+			assert.Equal(t, tc.wantRes, res)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.NoError(t, mock2.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShardingSelector_sortColumns(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+	db, err := OpenDS("mysql", shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+		"0.db.cluster.company.com:3306": MasterSlavesMemoryDB(),
+	}), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		s            *ShardingSelector[test.OrderDetail]
+		wantSortCols []sortmerger.SortColumn
+		wantErr      error
+	}{
+		{
+			name: "all columns",
+			s:    NewShardingSelector[test.OrderDetail](db).OrderBy(ASC("ItemId"), DESC("UsingCol1")),
+			wantSortCols: []sortmerger.SortColumn{
+				sortmerger.NewSortColumn("item_id", sortmerger.ASC),
+				sortmerger.NewSortColumn("using_col1", sortmerger.DESC),
+			},
+		},
+		{
+			name: "alias",
+			s: NewShardingSelector[test.OrderDetail](db).
+				Select(C("OrderId"), C("ItemId").As("item")).OrderBy(DESC("ItemId")),
+			wantSortCols: []sortmerger.SortColumn{
+				sortmerger.NewSortColumn("item", sortmerger.DESC),
+			},
+		},
+		{
+			name: "columns",
+			s: NewShardingSelector[test.OrderDetail](db).
+				Select(Columns("OrderId", "ItemId")).OrderBy(ASC("ItemId")),
+			wantSortCols: []sortmerger.SortColumn{
+				sortmerger.NewSortColumn("item_id", sortmerger.ASC),
+			},
+		},
+		{
+			name: "not selected",
+			s: NewShardingSelector[test.OrderDetail](db).
+				Select(C("OrderId")).OrderBy(ASC("ItemId")),
+			wantErr: errs.NewErrOrderByColumnNotSelected("ItemId"),
+		},
+		{
+			name:    "invalid field",
+			s:       NewShardingSelector[test.OrderDetail](db).OrderBy(ASC("Invalid")),
+			wantErr: errs.NewInvalidFieldError("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			meta, err := r.Get(&test.OrderDetail{})
+			require.NoError(t, err)
+			tc.s.meta = meta
+			sortCols, err := tc.s.sortColumns()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantSortCols, sortCols)
+		})
+	}
+}
+
+func TestShardingSelector_MergeErrCloseRows(t *testing.T) {
+	r := model.NewMetaRegistry()
+	_, err := r.Register(&test.OrderDetail{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "OrderId",
+			DBPattern:    &hash.Pattern{Name: "order_detail_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_detail_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: "0.db.cluster.company.com:3306", NotSharding: true},
+		}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		get  func(ctx context.Context, s *ShardingSelector[test.OrderDetail]) error
+	}{
+		{
+			name: "get",
+			get: func(ctx context.Context, s *ShardingSelector[test.OrderDetail]) error {
+				_, err := s.Get(ctx)
+				return err
+			},
+		},
+		{
+			name: "get multi",
+			get: func(ctx context.Context, s *ShardingSelector[test.OrderDetail]) error {
+				_, err := s.GetMulti(ctx)
+				return err
+			},
+		},
+		{
+			name: "best effort",
+			get: func(ctx context.Context, s *ShardingSelector[test.OrderDetail]) error {
+				_, err := s.BestEffort().GetMulti(ctx)
+				return err
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			mockDB2, mock2, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+			require.NoError(t, err)
+			defer func() { _ = mockDB2.Close() }()
+			shardingDB, err := OpenDS("mysql", shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+				"0.db.cluster.company.com:3306": cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+					"order_detail_db_0": masterslave.NewMasterSlavesDB(mockDB),
+					"order_detail_db_1": masterslave.NewMasterSlavesDB(mockDB2),
+				}),
+			}), DBWithMetaRegistry(r))
+			require.NoError(t, err)
+
+			// 两个分片返回的列不同，Merge 的时候会失败
+			mock.ExpectQuery("SELECT .*").
+				WillReturnRows(mock.NewRows([]string{"order_id", "ite_id", "using_col1", "using_col2"}).
+					AddRow(234, 12, "Kevin", "Durant")).RowsWillBeClosed()
+			mock2.ExpectQuery("SELECT .*").
+				WillReturnRows(mock2.NewRows([]string{"order_id", "item_id", "using_col1", "using_col2"}).
+					AddRow(123, 10, "LeBron", "James")).RowsWillBeClosed()
+
+			s := NewShardingSelector[test.OrderDetail](shardingDB).
+				Where(C("OrderId").EQ(123).Or(C("OrderId").EQ(234)))
+			err = tc.get(masterslave.UseMaster(context.Background()), s)
+			assert.Equal(t, errors.New("merger: sql.Rows列表中的字段不同"), err)
+			assert.NoError(t, mock.ExpectationsWereMet())
+			assert.NoError(t, mock2.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestShardingSelector_NoDst(t *testing.T) {
+	dsName := "0.db.cluster.company.com:3306"
+	rg, err := ranges.NewRange("OrderId",
+		ranges.Interval{Start: 0, End: 100, Dst: sharding.Dst{Name: dsName, DB: "order_db", Table: "order_tab_0"}},
+		ranges.Interval{Start: 200, End: 300, Dst: sharding.Dst{Name: dsName, DB: "order_db", Table: "order_tab_1"}},
+	)
+	require.NoError(t, err)
+	rangeReg := model.NewMetaRegistry()
+	_, err = rangeReg.Register(&Order{}, model.WithTableShardingAlgorithm(rg))
+	require.NoError(t, err)
+
+	// OrderId 作为以秒为单位的时间戳，只创建了 2024 年的分片
+	dt, err := datetime.NewDateTime("OrderId", datetime.Month,
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	dt.DsPattern = &datetime.Pattern{Name: dsName, NotSharding: true}
+	dt.DBPattern = &datetime.Pattern{Name: "order_db", NotSharding: true}
+	dt.TablePattern = &datetime.Pattern{Name: "order_tab_%s", Layout: "200601"}
+	dt.Location = time.UTC
+	dtReg := model.NewMetaRegistry()
+	_, err = dtReg.Register(&Order{}, model.WithTableShardingAlgorithm(dt))
+	require.NoError(t, err)
+	before := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+	after := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+	testCases := []struct {
+		name  string
+		reg   model.MetaRegistry
+		where []Predicate
+	}{
+		{
+			name:  "range out of intervals",
+			reg:   rangeReg,
+			where: []Predicate{C("OrderId").GT(500)},
+		},
+		{
+			name:  "range gap",
+			reg:   rangeReg,
+			where: []Predicate{C("OrderId").Between(120, 180)},
+		},
+		{
+			name:  "datetime eq out of window",
+			reg:   dtReg,
+			where: []Predicate{C("OrderId").EQ(before)},
+		},
+		{
+			name:  "datetime in out of window",
+			reg:   dtReg,
+			where: []Predicate{C("OrderId").In(before, after)},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			shardingDB, err := OpenDS("mysql", shardingsource.NewShardingDataSource(map[string]datasource.DataSource{
+				dsName: cluster.NewClusterDB(map[string]*masterslave.MasterSlavesDB{
+					"order_db": masterslave.NewMasterSlavesDB(mockDB),
+				}),
+			}), DBWithMetaRegistry(tc.reg))
+			require.NoError(t, err)
+			ctx := context.Background()
+
+			_, err = NewShardingSelector[Order](shardingDB).Where(tc.where...).Get(ctx)
+			assert.Equal(t, ErrNoRows, err)
+
+			res, err := NewShardingSelector[Order](shardingDB).Where(tc.where...).GetMulti(ctx)
+			require.NoError(t, err)
+			assert.Empty(t, res)
+
+			it, err := NewShardingSelector[Order](shardingDB).Where(tc.where...).Iter(ctx)
+			require.NoError(t, err)
+			assert.False(t, it.Next())
+			assert.NoError(t, it.Err())
+			assert.NoError(t, it.Close())
+
+			// 不会向数据库发送任何查询
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
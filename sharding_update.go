@@ -16,11 +16,8 @@ package eorm
 
 import (
 	"context"
-	"database/sql"
-	"sync"
-
-	"go.uber.org/multierr"
 
+	"github.com/ecodeclub/ekit/slice"
 	"github.com/ecodeclub/eorm/internal/errs"
 	"github.com/ecodeclub/eorm/internal/sharding"
 	"github.com/valyala/bytebufferpool"
@@ -30,8 +27,11 @@ var _ sharding.Executor = &ShardingUpdater[any]{}
 
 type ShardingUpdater[T any] struct {
 	table *T
-	lock  sync.Mutex
 	db    Session
+	// bestEffort 表示部分目标表更新失败的时候，不影响其它目标表
+	bestEffort bool
+	// allowShardingKeyUpdate 表示允许更新 sharding key，参考 AllowShardingKeyUpdate
+	allowShardingKeyUpdate bool
 	shardingUpdaterBuilder
 }
 
@@ -63,19 +63,27 @@ func (s *ShardingUpdater[T]) Where(predicates ...Predicate) *ShardingUpdater[T]
 
 // Build returns UPDATE []sharding.Query
 func (s *ShardingUpdater[T]) Build(ctx context.Context) ([]sharding.Query, error) {
-	if s.table == nil {
-		s.table = new(T)
+	_, qs, err := s.build(ctx)
+	return qs, err
+}
+
+// Explain 返回更新命中的目标表、每个查询条件的路由结果以及每个目标表上的 SQL，但是不会执行更新
+func (s *ShardingUpdater[T]) Explain(ctx context.Context) (ShardingExplain, error) {
+	defer s.explainBuffer()()
+	dsts, qs, err := s.build(ctx)
+	if err != nil {
+		return ShardingExplain{}, err
 	}
-	var err error
-	if s.meta == nil {
-		s.meta, err = s.metaRegistry.Get(s.table)
-		if err != nil {
-			return nil, err
-		}
+	return s.explain(ctx, dsts, qs, s.where)
+}
+
+func (s *ShardingUpdater[T]) build(ctx context.Context) ([]sharding.Dst, []sharding.Query, error) {
+	if err := s.initMeta(); err != nil {
+		return nil, nil, err
 	}
 	shardingRes, err := s.findDst(ctx, s.where...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	res := make([]sharding.Query, 0, len(shardingRes.Dsts))
@@ -83,13 +91,24 @@ func (s *ShardingUpdater[T]) Build(ctx context.Context) ([]sharding.Query, error
 	for _, dst := range shardingRes.Dsts {
 		q, err := s.buildQuery(dst.DB, dst.Table, dst.Name)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		res = append(res, q)
 		s.args = nil
 		s.buffer.Reset()
 	}
-	return res, nil
+	return shardingRes.Dsts, res, nil
+}
+
+func (s *ShardingUpdater[T]) initMeta() error {
+	if s.table == nil {
+		s.table = new(T)
+	}
+	var err error
+	if s.meta == nil {
+		s.meta, err = s.metaRegistry.Get(s.table)
+	}
+	return err
 }
 
 func (s *ShardingUpdater[T]) buildQuery(db, tbl, ds string) (sharding.Query, error) {
@@ -125,14 +144,14 @@ func (s *ShardingUpdater[T]) buildQuery(db, tbl, ds string) (sharding.Query, err
 
 func (s *ShardingUpdater[T]) buildAssigns() error {
 	has := false
-	shardingKey := s.meta.ShardingAlgorithm.ShardingKeys()[0]
+	sks := s.meta.ShardingAlgorithm.ShardingKeys()
 	for _, assign := range s.assigns {
 		if has {
 			s.comma()
 		}
 		switch a := assign.(type) {
 		case Column:
-			if a.name == shardingKey {
+			if slice.Contains[string](sks, a.name) {
 				return errs.NewErrUpdateShardingKeyUnsupported(a.name)
 			}
 			c, ok := s.meta.FieldMap[a.name]
@@ -149,7 +168,7 @@ func (s *ShardingUpdater[T]) buildAssigns() error {
 			has = true
 		case columns:
 			for _, name := range a.cs {
-				if name == shardingKey {
+				if slice.Contains[string](sks, name) {
 					return errs.NewErrUpdateShardingKeyUnsupported(name)
 				}
 				c, ok := s.meta.FieldMap[name]
@@ -169,6 +188,9 @@ func (s *ShardingUpdater[T]) buildAssigns() error {
 				has = true
 			}
 		case Assignment:
+			if c, ok := a.left.(Column); ok && slice.Contains[string](sks, c.name) {
+				return errs.NewErrUpdateShardingKeyUnsupported(c.name)
+			}
 			if err := s.buildExpr(binaryExpr(a)); err != nil {
 				return err
 			}
@@ -185,10 +207,10 @@ func (s *ShardingUpdater[T]) buildAssigns() error {
 
 func (s *ShardingUpdater[T]) buildDefaultColumns() error {
 	has := false
-	shardingKey := s.meta.ShardingAlgorithm.ShardingKeys()[0]
+	sks := s.meta.ShardingAlgorithm.ShardingKeys()
 	for _, c := range s.meta.Columns {
 		fieldName := c.FieldName
-		if fieldName == shardingKey {
+		if slice.Contains[string](sks, fieldName) {
 			continue
 		}
 		refVal, _ := s.val.Field(fieldName)
@@ -222,26 +244,29 @@ func (s *ShardingUpdater[T]) SkipZeroValue() *ShardingUpdater[T] {
 	return s
 }
 
+// BestEffort 开启 best effort 模式。
+// 默认情况下，只要有一个目标表更新失败，就会取消其它目标表上的更新；
+// 开启之后会在全部目标表上执行，Result 里面包含成功的结果和 *sharding.PartialError
+func (s *ShardingUpdater[T]) BestEffort() *ShardingUpdater[T] {
+	s.bestEffort = true
+	return s
+}
+
 func (s *ShardingUpdater[T]) Exec(ctx context.Context) sharding.Result {
-	qs, err := s.Build(ctx)
+	if s.allowShardingKeyUpdate {
+		if res, ok := s.tryMove(ctx); ok {
+			return res
+		}
+	}
+	dsts, qs, err := s.build(ctx)
 	if err != nil {
 		return sharding.NewResult(nil, err)
 	}
-	errList := make([]error, len(qs))
-	resList := make([]sql.Result, len(qs))
-	var wg sync.WaitGroup
-	wg.Add(len(qs))
-	for idx, q := range qs {
-		go func(idx int, q Query) {
-			defer wg.Done()
-			res, err := s.db.execContext(ctx, q)
-			s.lock.Lock()
-			errList[idx] = err
-			resList[idx] = res
-			s.lock.Unlock()
-		}(idx, q)
-	}
-	wg.Wait()
-	shardingRes := sharding.NewResult(resList, multierr.Combine(errList...))
-	return shardingRes
+	if err = s.checkShadowWrite(ctx, dsts); err != nil {
+		return sharding.NewResult(nil, err)
+	}
+	if s.meta.BroadcastTable {
+		return execBroadcast(ctx, s.db, qs)
+	}
+	return execAll(ctx, s.db, s.maxParallelism, dsts, qs, s.bestEffort)
 }
@@ -667,24 +667,24 @@ func TestShardingUpdater_Build_Error(t *testing.T) {
 			builder: NewShardingUpdater[Order](shardingDB).
 				Update(&Order{Content: "1", Account: 1.0}).
 				Set(Columns("Content", "Account")).
-				Where(Not(C("Content").Like("%kfc").Or(C("OrderId").EQ(101)))),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+				Where(Not(Exist(NewSelector[Order](shardingDB).AsSubquery("sub")).Or(C("OrderId").EQ(101)))),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
 		},
 		{
 			name: "not and right too complex operator",
 			builder: NewShardingUpdater[Order](shardingDB).
 				Update(&Order{Content: "1", Account: 1.0}).
 				Set(Columns("Content", "Account")).
-				Where(Not(C("OrderId").EQ(101).And(C("Content").Like("%kfc")))),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+				Where(Not(C("OrderId").EQ(101).And(Exist(NewSelector[Order](shardingDB).AsSubquery("sub"))))),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
 		},
 		{
 			name: "not or right too complex operator",
 			builder: NewShardingUpdater[Order](shardingDB).
 				Update(&Order{Content: "1", Account: 1.0}).
 				Set(Columns("Content", "Account")).
-				Where(Not(C("OrderId").EQ(101).Or(C("Content").Like("%kfc")))),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+				Where(Not(C("OrderId").EQ(101).Or(Exist(NewSelector[Order](shardingDB).AsSubquery("sub"))))),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
 		},
 		{
 			name: "invalid field err",
@@ -696,15 +696,15 @@ func TestShardingUpdater_Build_Error(t *testing.T) {
 			name: "pointer only err",
 			builder: NewShardingUpdater[int64](shardingDB).
 				Set(Columns("Content", "Account")).
-				Where(Not(C("OrderId").EQ(101).And(C("Content").Like("%kfc")))),
+				Where(Not(C("OrderId").EQ(101).And(Exist(NewSelector[Order](shardingDB).AsSubquery("sub"))))),
 			wantErr: errs.ErrPointerOnly,
 		},
 		{
 			name: "too complex operator",
 			builder: NewShardingUpdater[Order](shardingDB).
 				Update(&Order{Content: "1", Account: 1.0}).
-				Set(Columns("Content", "Account")).Where(C("Content").Like("%kfc")),
-			wantErr: errs.NewUnsupportedOperatorError(opLike.Text),
+				Set(Columns("Content", "Account")).Where(Exist(NewSelector[Order](shardingDB).AsSubquery("sub"))),
+			wantErr: errs.NewUnsupportedOperatorError(opExist.Text),
 		},
 		{
 			name: "too complex expr",
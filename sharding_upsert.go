@@ -0,0 +1,161 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"github.com/ecodeclub/ekit/slice"
+	"github.com/ecodeclub/eorm/internal/dialect"
+	"github.com/ecodeclub/eorm/internal/errs"
+)
+
+// upsert 是 INSERT 遇到冲突之后的更新
+type upsert struct {
+	assigns []Assignable
+	// conflictColumns 是 SQLite 判断冲突的列，MySQL 使用主键和唯一索引判断冲突
+	conflictColumns []string
+}
+
+// ShardingUpsertBuilder 构造 ShardingInserter 遇到冲突之后的更新
+type ShardingUpsertBuilder[T any] struct {
+	i               *ShardingInserter[T]
+	conflictColumns []string
+}
+
+// OnDuplicateKey 开始构造冲突之后的更新，需要调用 Update 才会生效
+func (si *ShardingInserter[T]) OnDuplicateKey() *ShardingUpsertBuilder[T] {
+	return &ShardingUpsertBuilder[T]{i: si}
+}
+
+// ConflictColumns 指定判断冲突的列，只有 SQLite 需要
+func (o *ShardingUpsertBuilder[T]) ConflictColumns(cols ...string) *ShardingUpsertBuilder[T] {
+	o.conflictColumns = cols
+	return o
+}
+
+// Update 指定冲突之后更新的列。
+// 使用 C 或者 Columns 表示更新为插入的值，使用 Assign 表示更新为指定的值或者表达式。
+// sharding key 决定了数据所在的目标表，所以不能被更新
+func (o *ShardingUpsertBuilder[T]) Update(assigns ...Assignable) *ShardingInserter[T] {
+	o.i.upsert = &upsert{assigns: assigns, conflictColumns: o.conflictColumns}
+	return o.i
+}
+
+// checkUpsert 校验冲突之后更新的列，在查找目标表之前调用
+func (si *ShardingInserter[T]) checkUpsert() error {
+	if si.upsert == nil {
+		return nil
+	}
+	if len(si.upsert.assigns) == 0 {
+		return errs.NewValueNotSetError()
+	}
+	if si.dialect == dialect.SQLite && len(si.upsert.conflictColumns) == 0 {
+		return errs.ErrMissingConflictColumns
+	}
+	sks := si.meta.ShardingAlgorithm.ShardingKeys()
+	check := func(name string) error {
+		if _, ok := si.meta.FieldMap[name]; !ok {
+			return errs.NewInvalidFieldError(name)
+		}
+		if slice.Contains[string](sks, name) {
+			return errs.NewErrUpdateShardingKeyUnsupported(name)
+		}
+		return nil
+	}
+	for _, assign := range si.upsert.assigns {
+		var err error
+		switch a := assign.(type) {
+		case Column:
+			err = check(a.name)
+		case columns:
+			for _, name := range a.cs {
+				if err = check(name); err != nil {
+					break
+				}
+			}
+		case Assignment:
+			c, ok := a.left.(Column)
+			if !ok {
+				return errs.ErrUnsupportedAssignment
+			}
+			err = check(c.name)
+		default:
+			return errs.ErrUnsupportedAssignment
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range si.upsert.conflictColumns {
+		if _, ok := si.meta.FieldMap[name]; !ok {
+			return errs.NewInvalidFieldError(name)
+		}
+	}
+	return nil
+}
+
+// buildUpsert 按照方言构造冲突之后的更新。
+// MySQL 是 ON DUPLICATE KEY UPDATE，SQLite 是 ON CONFLICT(xxx) DO UPDATE SET
+func (si *ShardingInserter[T]) buildUpsert() error {
+	// insertedValue 写入插入的值
+	var insertedValue func(col string)
+	if si.dialect == dialect.SQLite {
+		si.writeString(" ON CONFLICT(")
+		for i, name := range si.upsert.conflictColumns {
+			if i > 0 {
+				si.comma()
+			}
+			si.quote(si.meta.FieldMap[name].ColumnName)
+		}
+		si.writeString(") DO UPDATE SET ")
+		insertedValue = func(col string) {
+			si.writeString("excluded.")
+			si.quote(col)
+		}
+	} else {
+		si.writeString(" ON DUPLICATE KEY UPDATE ")
+		insertedValue = func(col string) {
+			si.writeString("VALUES(")
+			si.quote(col)
+			si.writeByte(')')
+		}
+	}
+	assignColumn := func(name string) {
+		col := si.meta.FieldMap[name].ColumnName
+		si.quote(col)
+		si.writeByte('=')
+		insertedValue(col)
+	}
+	for i, assign := range si.upsert.assigns {
+		if i > 0 {
+			si.comma()
+		}
+		switch a := assign.(type) {
+		case Column:
+			assignColumn(a.name)
+		case columns:
+			for j, name := range a.cs {
+				if j > 0 {
+					si.comma()
+				}
+				assignColumn(name)
+			}
+		case Assignment:
+			if err := si.buildExpr(binaryExpr(a)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 ecodeclub
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ecodeclub/eorm/internal/datasource"
+	"github.com/ecodeclub/eorm/internal/datasource/cluster"
+	"github.com/ecodeclub/eorm/internal/datasource/masterslave"
+	"github.com/ecodeclub/eorm/internal/datasource/shardingsource"
+	"github.com/ecodeclub/eorm/internal/errs"
+	"github.com/ecodeclub/eorm/internal/model"
+	"github.com/ecodeclub/eorm/internal/sharding"
+	"github.com/ecodeclub/eorm/internal/sharding/hash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardingInserter_OnDuplicateKey(t *testing.T) {
+	r := model.NewMetaRegistry()
+	dsPattern := "0.db.cluster.company.com:3306"
+	_, err := r.Register(&Order{},
+		model.WithTableShardingAlgorithm(&hash.Hash{
+			ShardingKey:  "UserId",
+			DBPattern:    &hash.Pattern{Name: "order_db_%d", Base: 2},
+			TablePattern: &hash.Pattern{Name: "order_tab_%d", Base: 3},
+			DsPattern:    &hash.Pattern{Name: dsPattern, NotSharding: true},
+		}))
+	require.NoError(t, err)
+	m := map[string]*masterslave.MasterSlavesDB{
+		"order_db_0": MasterSlavesMemoryDB(),
+		"order_db_1": MasterSlavesMemoryDB(),
+	}
+	ds := map[string]datasource.DataSource{
+		dsPattern: cluster.NewClusterDB(m),
+	}
+	mysqlDB, err := OpenDS("mysql",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+	sqliteDB, err := OpenDS("sqlite3",
+		shardingsource.NewShardingDataSource(ds), DBWithMetaRegistry(r))
+	require.NoError(t, err)
+
+	orders := []*Order{
+		{UserId: 1, OrderId: 1, Content: "1", Account: 1.0},
+		{UserId: 2, OrderId: 2, Content: "2", Account: 2.0},
+		{UserId: 7, OrderId: 7, Content: "7", Account: 7.0},
+	}
+	testCases := []struct {
+		name    string
+		builder sharding.QueryBuilder
+		wantQs  []sharding.Query
+		wantErr error
+	}{
+		{
+			name: "mysql",
+			builder: NewShardingInsert[Order](mysqlDB).Values(orders).
+				OnDuplicateKey().Update(Columns("Content", "Account")),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_db_0`.`order_tab_2`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE `content`=VALUES(`content`),`account`=VALUES(`account`);",
+					Args:       []any{2, int64(2), "2", 2.0},
+					DB:         "order_db_0",
+					Datasource: dsPattern,
+				},
+				{
+					SQL:        "INSERT INTO `order_db_1`.`order_tab_1`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?),(?,?,?,?) ON DUPLICATE KEY UPDATE `content`=VALUES(`content`),`account`=VALUES(`account`);",
+					Args:       []any{1, int64(1), "1", 1.0, 7, int64(7), "7", 7.0},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "mysql assign",
+			builder: NewShardingInsert[Order](mysqlDB).Values(orders[:1]).
+				OnDuplicateKey().Update(C("Content"), Assign("Account", 10.0)),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_db_1`.`order_tab_1`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?) ON DUPLICATE KEY UPDATE `content`=VALUES(`content`),`account`=?;",
+					Args:       []any{1, int64(1), "1", 1.0, 10.0},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "sqlite",
+			builder: NewShardingInsert[Order](sqliteDB).Values(orders[:1]).
+				OnDuplicateKey().ConflictColumns("OrderId").Update(C("Content")),
+			wantQs: []sharding.Query{
+				{
+					SQL:        "INSERT INTO `order_db_1`.`order_tab_1`(`user_id`,`order_id`,`content`,`account`) VALUES(?,?,?,?) ON CONFLICT(`order_id`) DO UPDATE SET `content`=excluded.`content`;",
+					Args:       []any{1, int64(1), "1", 1.0},
+					DB:         "order_db_1",
+					Datasource: dsPattern,
+				},
+			},
+		},
+		{
+			name: "sqlite without conflict columns",
+			builder: NewShardingInsert[Order](sqliteDB).Values(orders[:1]).
+				OnDuplicateKey().Update(C("Content")),
+			wantErr: errs.ErrMissingConflictColumns,
+		},
+		{
+			name: "update sharding key",
+			builder: NewShardingInsert[Order](mysqlDB).Values(orders).
+				OnDuplicateKey().Update(Columns("Content", "UserId")),
+			wantErr: errs.NewErrUpdateShardingKeyUnsupported("UserId"),
+		},
+		{
+			name: "assign sharding key",
+			builder: NewShardingInsert[Order](mysqlDB).Values(orders).
+				OnDuplicateKey().Update(Assign("UserId", 3)),
+			wantErr: errs.NewErrUpdateShardingKeyUnsupported("UserId"),
+		},
+		{
+			name: "invalid column",
+			builder: NewShardingInsert[Order](mysqlDB).Values(orders).
+				OnDuplicateKey().Update(C("Invalid")),
+			wantErr: errs.NewInvalidFieldError("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			qs, err := tc.builder.Build(context.Background())
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQs, qs)
+		})
+	}
+}
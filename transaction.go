@@ -47,6 +47,9 @@ func (t *Tx) queryMulti(ctx context.Context, qs []Query) (list.List[rows.Rows],
 		List: list.NewArrayList[rows.Rows](len(keys)),
 	}
 	var eg errgroup.Group
+	if t.maxParallelism > 0 {
+		eg.SetLimit(t.maxParallelism)
+	}
 	for _, key := range keys {
 		dbQs, _ := mp.Get(key)
 		eg.Go(func() error {
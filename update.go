@@ -66,7 +66,7 @@ func (u *Updater[T]) Build() (Query, error) {
 	u.args = make([]interface{}, 0, len(u.meta.Columns))
 
 	u.writeString("UPDATE ")
-	u.quote(u.meta.TableName)
+	u.quote(u.tableName(u.meta))
 	u.writeString(" SET ")
 	if len(u.assigns) == 0 {
 		err = u.buildDefaultColumns()
@@ -201,9 +201,13 @@ func isZeroValue(val reflect.Value) bool {
 
 // Exec sql
 func (u *Updater[T]) Exec(ctx context.Context) Result {
+	u.useShadow(ctx)
 	query, err := u.Build()
 	if err != nil {
 		return Result{err: err}
 	}
+	if err = u.checkShadowTable(ctx); err != nil {
+		return Result{err: err}
+	}
 	return newQuerier[T](u.Session, query, u.meta, UPDATE).Exec(ctx)
 }